@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+
+	"audioml/internal/dataset"
+	ilog "audioml/internal/logger"
+)
+
+// archiveEntryError rejects an archive, or a single entry of it, with a
+// code for the upload report
+type archiveEntryError struct {
+	code   dataset.ValidationCode
+	reason string
+}
+
+func (e *archiveEntryError) Error() string {
+	return e.reason
+}
+
+// isArchive reports whether an uploaded file is an archive to expand
+func isArchive(filename string) bool {
+	return archiveFormat(filename) != ""
+}
+
+func archiveFormat(filename string) string {
+	name := strings.ToLower(filename)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	}
+	return ""
+}
+
+// expandArchive extracts the regular files of a staged archive next to
+// it, each becoming a received file named after its path in the archive,
+// so that class folders give labels. A root folder holding every entry is
+// left out of the names. Entries are never written under
+// their own name, and the bytes actually extracted are counted against
+// ArchiveMaxBytes and MaxFileBytes whatever the headers claim.
+//
+// An archive that cannot be read or exceeds the limits comes back as a
+// single rejected file.
+func (h *DatasetUploadHandler) expandArchive(staged, filename string) []receivedFile {
+	x := &archiveExtractor{
+		staged:     staged,
+		maxFile:    h.MaxFileBytes,
+		maxBytes:   h.ArchiveMaxBytes,
+		maxEntries: h.ArchiveMaxEntries,
+	}
+
+	var err error
+	switch archiveFormat(filename) {
+	case "zip":
+		err = x.zip()
+	case "tar":
+		err = x.tar(false)
+	case "tar.gz":
+		err = x.tar(true)
+	}
+	if err == nil {
+		stripRoot(x.files)
+		return x.files
+	}
+
+	for _, rf := range x.files {
+		os.Remove(rf.staged)
+	}
+	var entryErr *archiveEntryError
+	if !errors.As(err, &entryErr) {
+		ilog.L.Printf("expand archive %s: %v", filename, err)
+		err = &archiveEntryError{code: dataset.CodeInvalidArchive, reason: "archive cannot be read"}
+	}
+	return []receivedFile{{filename: filename, err: err}}
+}
+
+type archiveExtractor struct {
+	staged     string
+	maxFile    int64
+	maxBytes   int64
+	maxEntries int
+
+	entries int
+	total   int64
+	// exceeded is set once more than maxBytes were extracted
+	exceeded bool
+	files    []receivedFile
+}
+
+func (x *archiveExtractor) zip() error {
+	// Unsafe names are reported per entry below
+	zr, err := zip.OpenReader(x.staged)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return err
+	}
+	defer zr.Close()
+
+	for _, zf := range zr.File {
+		err := x.add(zf.Name, zf.Mode(), func() (io.ReadCloser, error) {
+			return zf.Open()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *archiveExtractor) tar(gzipped bool) error {
+	f, err := os.Open(x.staged)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		err = x.add(hdr.Name, hdr.FileInfo().Mode(), func() (io.ReadCloser, error) {
+			return io.NopCloser(tr), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// add extracts one entry. Only limits on the whole archive and
+// unreadable data fail the archive, anything wrong with the entry itself
+// is reported with it.
+func (x *archiveExtractor) add(name string, mode fs.FileMode, open func() (io.ReadCloser, error)) error {
+	if mode.IsDir() || strings.HasPrefix(name, "__MACOSX/") {
+		return nil
+	}
+
+	x.entries++
+	if x.maxEntries > 0 && x.entries > x.maxEntries {
+		return &archiveEntryError{
+			code:   dataset.CodeArchiveTooLarge,
+			reason: fmt.Sprintf("archive has more than %d files", x.maxEntries),
+		}
+	}
+
+	rf := receivedFile{
+		filename: name,
+		staged:   fmt.Sprintf("%s-%d", x.staged, x.entries),
+	}
+	defer func() { x.files = append(x.files, rf) }()
+
+	if !safeEntryName(name) {
+		rf.err = &archiveEntryError{code: dataset.CodeUnsafePath, reason: "path escapes the archive"}
+		return nil
+	}
+	if !mode.IsRegular() {
+		rf.err = &archiveEntryError{code: dataset.CodeNotRegularFile, reason: "links and special files are not allowed"}
+		return nil
+	}
+
+	src, err := open()
+	if err != nil {
+		rf.err = &archiveEntryError{code: dataset.CodeCorrupt, reason: err.Error()}
+		return nil
+	}
+	defer src.Close()
+
+	budget := &archiveBudget{x: x, r: src}
+	rf.size, rf.sum, rf.err = receiveFile(budget, rf.staged, x.maxFile)
+	if x.exceeded {
+		return &archiveEntryError{
+			code:   dataset.CodeArchiveTooLarge,
+			reason: fmt.Sprintf("archive expands to more than %d bytes", x.maxBytes),
+		}
+	}
+	// A corrupt tar stream fails the archive on the next entry, a zip
+	// archive goes on with the other entries
+	if rf.err != nil && !errors.Is(rf.err, errFileTooLarge) {
+		rf.err = &archiveEntryError{code: dataset.CodeCorrupt, reason: rf.err.Error()}
+	}
+	x.total += budget.n
+	return nil
+}
+
+// archiveBudget fails once the entries read so far exceed the maximum
+// size of an expanded archive
+type archiveBudget struct {
+	x *archiveExtractor
+	r io.Reader
+	n int64
+}
+
+func (b *archiveBudget) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.n += int64(n)
+	if b.x.maxBytes > 0 && b.x.total+b.n > b.x.maxBytes {
+		b.x.exceeded = true
+		return n, errors.New("archive too large")
+	}
+	return n, err
+}
+
+// stripRoot drops the folder every entry is in when the entries are in
+// class folders below it: archives are often made of a single folder whose
+// name is not a label. A single folder of files is kept, its name being
+// the label of an archive of one class.
+func stripRoot(files []receivedFile) {
+	root := ""
+	for i, rf := range files {
+		first, rest, ok := strings.Cut(strings.TrimPrefix(cleanUploadName(rf.filename), "/"), "/")
+		if !ok || !strings.Contains(rest, "/") || (i > 0 && first != root) {
+			return
+		}
+		root = first
+	}
+	for i := range files {
+		files[i].filename = strings.TrimPrefix(cleanUploadName(files[i].filename), "/"+root+"/")
+	}
+}
+
+// safeEntryName rejects absolute paths and paths with ".." elements,
+// which would land outside the dataset if extracted as named
+func safeEntryName(name string) bool {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if name == "" || strings.HasPrefix(name, "/") || strings.ContainsRune(name, 0) {
+		return false
+	}
+	if len(name) > 1 && name[1] == ':' {
+		return false
+	}
+	for _, elem := range strings.Split(name, "/") {
+		if elem == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -1,93 +1,805 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"audioml/internal/dataset"
+	"audioml/internal/lineage"
+	ilog "audioml/internal/logger"
 
 	"github.com/gorilla/mux"
 )
 
-type DatasetUploadHandler struct{}
+type DatasetUploadHandler struct {
+	Lineage  *lineage.Service
+	Datasets *dataset.Service
+	// MaxFileBytes bounds each uploaded file and MaxRequestBytes a whole
+	// upload request, 0 for no limit
+	MaxFileBytes    int64
+	MaxRequestBytes int64
+	// Archives are bounded by the size of the archive and of its extracted
+	// files, and by their number, 0 for no limit
+	ArchiveMaxBytes   int64
+	ArchiveMaxEntries int
+
+	// rules replaces Datasets.Rules in tests
+	rules func(ctx context.Context, name string) (dataset.Rules, error)
+}
 
 func (h *DatasetUploadHandler) Register(r *mux.Router) {
 	r.HandleFunc("/datasets/upload", h.Upload).Methods(http.MethodPost)
+	r.HandleFunc("/datasets/{name}", h.Get).Methods(http.MethodGet)
+	r.HandleFunc("/datasets/{name}/versions", h.Versions).Methods(http.MethodGet)
+	r.HandleFunc("/datasets/{name}/versions/{version:[0-9]+}", h.Version).Methods(http.MethodGet)
+	r.HandleFunc("/datasets/{name}/rules", h.GetRules).Methods(http.MethodGet)
+	r.HandleFunc("/datasets/{name}/rules", h.SetRules).Methods(http.MethodPut)
+	r.HandleFunc("/datasets/{name}/splits", h.GetSplits).Methods(http.MethodGet)
+	r.HandleFunc("/datasets/{name}/splits", h.SetSplits).Methods(http.MethodPut)
+}
+
+// Outcome of a single uploaded file
+const (
+	uploadAccepted  = "accepted"
+	uploadRenamed   = "renamed"   // stored under another name, the original was taken
+	uploadDuplicate = "duplicate" // identical to the file already stored under that name
+	uploadRejected  = "rejected"
+)
+
+type uploadFileReport struct {
+	Filename string                 `json:"filename"`
+	StoredAs string                 `json:"stored_as,omitempty"`
+	Label    string                 `json:"label,omitempty"`
+	Split    dataset.Split          `json:"split,omitempty"`
+	Status   string                 `json:"status"`
+	Code     dataset.ValidationCode `json:"code,omitempty"`
+	Reason   string                 `json:"reason,omitempty"`
+	Audio    *dataset.AudioInfo     `json:"audio,omitempty"`
+
+	metadata map[string]any
+	size     int64
+	staged   string
+	sum      string
 }
 
 type datasetUploadResponse struct {
 	Dataset string `json:"dataset"`
-	Files   int    `json:"files"`
+	// Files is the number of files added to the dataset
+	Files int `json:"files"`
+	// Version is the dataset version holding the upload, pin it with
+	// "dataset": "<ref>" when training
+	Version int    `json:"version,omitempty"`
+	Ref     string `json:"ref,omitempty"`
+	// Committed is false when an atomic upload was rolled back
+	Committed bool               `json:"committed"`
+	Report    []uploadFileReport `json:"report"`
+	// Manifest entries that matched no uploaded file
+	ManifestUnmatched []string `json:"manifest_unmatched,omitempty"`
 }
 
+// maxManifestSize bounds the manifest part of a dataset upload
+const maxManifestSize = 16 << 20
+
+// uploadForm is a dataset upload read from a multipart stream, with its
+// files already on disk
+type uploadForm struct {
+	dataset  string
+	atomic   bool
+	label    string
+	labels   []string
+	split    dataset.Split
+	manifest dataset.Manifest
+	files    []receivedFile
+	// archives is the number of archives expanded into files
+	archives int
+
+	// quota is what the dataset may still take, checked while files are
+	// received so that an upload cannot fill the disk
+	quota    dataset.Quota
+	usage    dataset.Usage
+	received dataset.Usage
+}
+
+type receivedFile struct {
+	filename string
+	staged   string
+	size     int64
+	sum      string
+	err      error
+}
+
+// POST /datasets/upload
+// multipart: dataset, files (repeated), atomic (optional, "true" rolls back
+// the whole upload if any file is rejected).
+//
+// The label of a file is, by precedence, its manifest entry (a CSV or JSON
+// "manifest" file), the "labels" field at the same position (repeated, one
+// per file), the folder of its file name ("happy/a.wav") or the "label"
+// field. Labeled files are stored in their class folder.
+//
+// A .zip, .tar or .tar.gz file is expanded, each entry being reported as
+// a file named after its path in the archive.
+//
+// Parts are streamed to disk as they arrive, each file being bounded by
+// MaxFileBytes and the whole request by MaxRequestBytes. The dataset field
+// must come before the files: the upload is aborted as soon as the files
+// received exceed the quota of the dataset.
 func (h *DatasetUploadHandler) Upload(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseMultipartForm(1 << 30); err != nil {
-		http.Error(w, "failed to parse multipart form", http.StatusBadRequest)
+	if h.MaxRequestBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.MaxRequestBytes)
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "expected a multipart upload", http.StatusBadRequest)
 		return
 	}
 
-	datasetName := r.FormValue("dataset")
-	if datasetName == "" {
-		http.Error(w, "dataset field is required", http.StatusBadRequest)
+	staging, err := h.Datasets.Staging()
+	if err != nil {
+		ilog.L.Printf("dataset upload staging: %v", err)
+		http.Error(w, "failed to create staging directory", http.StatusInternalServerError)
 		return
 	}
+	defer os.RemoveAll(staging)
 
-	basePath := filepath.Join("datasets", "local-audio", datasetName)
-
-	if err := os.MkdirAll(basePath, 0755); err != nil {
-		http.Error(w, "failed to create dataset directory", http.StatusInternalServerError)
+	form, status, err := h.readUploadForm(r.Context(), mr, staging)
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
 
-	files := r.MultipartForm.File["files"]
-	if len(files) == 0 {
-		http.Error(w, "no files uploaded (use field name 'files')", http.StatusBadRequest)
+	resp, status, err := h.ingest(r.Context(), form)
+	if err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
+	writeUploadReport(w, status, *resp)
+}
 
-	saved := 0
+// ingest validates the received files of an upload and adds the accepted
+// ones to the dataset. The error, if any, is meant for the client and
+// comes with its status.
+func (h *DatasetUploadHandler) ingest(ctx context.Context, form *uploadForm) (*datasetUploadResponse, int, error) {
+	datasetName := form.dataset
+	if datasetName == "" {
+		return nil, http.StatusBadRequest, errors.New("dataset field is required")
+	}
+	if err := dataset.ValidateName(datasetName); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
 
-	for _, fh := range files {
-		src, err := fh.Open()
-		if err != nil {
+	if len(form.files) == 0 {
+		return nil, http.StatusBadRequest, errors.New("no files uploaded (use field name 'files')")
+	}
+
+	if form.label != "" {
+		if err := dataset.ValidateLabel(form.label); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
+	if !form.split.Valid() {
+		return nil, http.StatusBadRequest, errors.New("split must be train, validation or test")
+	}
+	if len(form.labels) > 0 && form.archives > 0 {
+		return nil, http.StatusBadRequest, errors.New("labels cannot be given with an archive, use class folders or a manifest")
+	}
+	if len(form.labels) > 0 && len(form.labels) != len(form.files) {
+		return nil, http.StatusBadRequest, errors.New("labels must be given once per file")
+	}
+	for _, l := range form.labels {
+		if l == "" {
 			continue
 		}
+		if err := dataset.ValidateLabel(l); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+	}
 
-		dstPath := filepath.Join(basePath, fh.Filename)
-		dst, err := os.Create(dstPath)
-		if err != nil {
-			src.Close()
+	rules, err := h.Datasets.Rules(ctx, datasetName)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("failed to load dataset rules")
+	}
+
+	if _, err := h.Datasets.Prepare(datasetName); err != nil {
+		ilog.L.Printf("prepare dataset %s: %v", datasetName, err)
+		return nil, http.StatusInternalServerError, errors.New("failed to create dataset directory")
+	}
+
+	quota := h.Datasets.Quota(rules)
+	usage, err := h.Datasets.Usage(datasetName)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("failed to measure dataset usage")
+	}
+
+	report := make([]uploadFileReport, len(form.files))
+	matched := map[string]bool{}
+	rejected := 0
+	for i, rf := range form.files {
+		f := uploadFileReport{Filename: rf.filename, Label: form.label, Split: form.split}
+		if dir := path.Base(path.Dir(cleanUploadName(rf.filename))); dir != "." && dir != "/" {
+			f.Label = dir
+		}
+		if i < len(form.labels) && form.labels[i] != "" {
+			f.Label = form.labels[i]
+		}
+		if e, ok := form.manifest.Lookup(rf.filename); ok {
+			matched[e.Path] = true
+			if e.Label != "" {
+				f.Label = e.Label
+			}
+			if e.Split != "" {
+				f.Split = e.Split
+			}
+			f.metadata = e.Metadata
+		}
+
+		var entryErr *archiveEntryError
+		switch {
+		case errors.As(rf.err, &entryErr):
+			f.Status = uploadRejected
+			f.Code = entryErr.code
+			f.Reason = entryErr.reason
+		case errors.Is(rf.err, errFileTooLarge):
+			f.Status = uploadRejected
+			f.Code = dataset.CodeFileTooLarge
+			f.Reason = fmt.Sprintf("file is larger than %d bytes", h.MaxFileBytes)
+		case rf.err != nil:
+			f.Status = uploadRejected
+			f.Reason = "failed to store file"
+
+		// Files are counted against the quota as they are accepted,
+		// duplicates included
+		case !quota.Allows(usage, 1, rf.size):
+			f.Status = uploadRejected
+			f.Code = dataset.CodeQuotaExceeded
+			f.Reason = fmt.Sprintf("dataset would exceed its quota of %d files, %d bytes", quota.MaxFiles, quota.MaxBytes)
+
+		default:
+			f = checkUpload(f, rf, rules)
+		}
+
+		report[i] = f
+		if f.Status == uploadRejected {
+			rejected++
+			continue
+		}
+		usage.Files++
+		usage.Bytes += rf.size
+	}
+
+	resp := datasetUploadResponse{
+		Dataset: dataset.Source(datasetName),
+		Report:  report,
+	}
+	for _, e := range form.manifest {
+		if !matched[e.Path] {
+			resp.ManifestUnmatched = append(resp.ManifestUnmatched, e.Path)
+		}
+	}
+	sort.Strings(resp.ManifestUnmatched)
+
+	if form.atomic && rejected > 0 {
+		for i := range report {
+			report[i].StoredAs = ""
+		}
+		return &resp, http.StatusUnprocessableEntity, nil
+	}
+
+	var stored []dataset.File
+	datasetNode := lineage.DatasetNode(resp.Dataset)
+	for i := range report {
+		f := &report[i]
+		if f.Status == uploadRejected {
+			continue
+		}
+
+		if err := h.commitUpload(datasetName, f); err != nil {
+			ilog.L.Printf("store %s in dataset %s: %v", f.Filename, datasetName, err)
+			f.Status = uploadRejected
+			f.StoredAs = ""
+			f.Reason = "failed to store file"
+			rejected++
+			continue
+		}
+		// A duplicate is recorded again, its split or metadata may have changed
+		stored = append(stored, dataset.File{
+			Path:      f.StoredAs,
+			Label:     f.labelOrUnlabeled(),
+			Split:     f.Split,
+			Metadata:  f.metadata,
+			SHA256:    f.sum,
+			SizeBytes: f.size,
+			Audio:     f.Audio,
+		})
+		if f.Status == uploadDuplicate {
 			continue
 		}
 
-		if _, err := io.Copy(dst, src); err == nil {
-			// 🔍 FFmpeg validation
-			if err := dataset.ValidateAudio(dstPath); err == nil {
-				saved++
-			} else {
-				// Invalid audio → delete file
-				os.Remove(dstPath)
+		resp.Files++
+		fileNode := lineage.AudioFileNode(fmt.Sprintf("local-audio/%s/%s", datasetName, f.StoredAs))
+		if err := h.Lineage.Link(ctx, fileNode, datasetNode, lineage.RelMemberOf); err != nil {
+			ilog.L.Printf("lineage for %s: %v", f.StoredAs, err)
+		}
+	}
+	resp.Committed = true
+
+	if len(stored) > 0 {
+		version, err := h.Datasets.Commit(ctx, datasetName, stored)
+		if err != nil {
+			ilog.L.Printf("manifest of dataset %s: %v", datasetName, err)
+			return nil, http.StatusInternalServerError, errors.New("files were stored but the dataset manifest could not be updated")
+		}
+		resp.Version = version.Version
+		resp.Ref = version.Ref
+
+		snapshotNode := lineage.DatasetSnapshotNode(version.Ref, version.Digest)
+		if err := h.Lineage.Link(ctx, datasetNode, snapshotNode, lineage.RelVersionedAs); err != nil {
+			ilog.L.Printf("lineage for %s: %v", version.Ref, err)
+		}
+	}
+
+	status := http.StatusOK
+	if rejected == len(report) {
+		status = http.StatusUnprocessableEntity
+	}
+	return &resp, status, nil
+}
+
+func (f uploadFileReport) labelOrUnlabeled() string {
+	if f.Label == "" {
+		return dataset.UnlabeledClass
+	}
+	return f.Label
+}
+
+// cleanUploadName turns an uploaded file name into a slash separated path
+// without any ".." element
+func cleanUploadName(name string) string {
+	return path.Clean("/" + strings.ReplaceAll(name, `\`, "/"))
+}
+
+// readUploadForm reads every part of a dataset upload, files being
+// streamed into staging as they come. The error, if any, is meant for the
+// client and comes with its status.
+func (h *DatasetUploadHandler) readUploadForm(ctx context.Context, mr *multipart.Reader, staging string) (*uploadForm, int, error) {
+	form := &uploadForm{manifest: dataset.Manifest{}}
+	hasManifest := false
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return form, http.StatusOK, nil
+		}
+		if err != nil {
+			if status, err := h.requestError(err); status != 0 {
+				return nil, status, err
 			}
+			return nil, http.StatusBadRequest, errors.New("failed to parse multipart form")
 		}
 
-		dst.Close()
-		src.Close()
+		name := part.FormName()
+		if (name == "files" || name == "manifest") && form.dataset == "" {
+			return nil, http.StatusBadRequest, errors.New("dataset field is required before the files")
+		}
+
+		switch name {
+		case "files":
+			rf := receivedFile{
+				filename: partFilename(part),
+				staged:   filepath.Join(staging, strconv.Itoa(len(form.files))),
+			}
+			if isArchive(rf.filename) {
+				if _, _, err := receiveFile(part, rf.staged, h.ArchiveMaxBytes); err != nil {
+					if status, err := h.requestError(err); status != 0 {
+						return nil, status, err
+					}
+					if errors.Is(err, errFileTooLarge) {
+						return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("archive %s is larger than %d bytes", rf.filename, h.ArchiveMaxBytes)
+					}
+					ilog.L.Printf("receive %s: %v", rf.filename, err)
+					return nil, http.StatusBadRequest, fmt.Errorf("failed to receive archive %s", rf.filename)
+				}
+				entries := h.expandArchive(rf.staged, rf.filename)
+				os.Remove(rf.staged)
+				form.archives++
+				for _, e := range entries {
+					form.files = append(form.files, e)
+					if err := form.receive(e); err != nil {
+						return nil, http.StatusRequestEntityTooLarge, err
+					}
+				}
+				break
+			}
+
+			// A file is cut where it would exceed the quota
+			limit, byQuota := h.MaxFileBytes, false
+			if left := form.bytesLeft(); left >= 0 && (limit == 0 || left < limit) {
+				if left == 0 {
+					return nil, http.StatusRequestEntityTooLarge, form.quotaError()
+				}
+				limit, byQuota = left, true
+			}
+			rf.size, rf.sum, rf.err = receiveFile(part, rf.staged, limit)
+			if status, err := h.requestError(rf.err); status != 0 {
+				return nil, status, err
+			}
+			if byQuota && errors.Is(rf.err, errFileTooLarge) {
+				return nil, http.StatusRequestEntityTooLarge, form.quotaError()
+			}
+			if rf.err != nil && !errors.Is(rf.err, errFileTooLarge) {
+				ilog.L.Printf("receive %s: %v", rf.filename, rf.err)
+			}
+			form.files = append(form.files, rf)
+			if err := form.receive(rf); err != nil {
+				return nil, http.StatusRequestEntityTooLarge, err
+			}
+
+		case "manifest":
+			if hasManifest {
+				return nil, http.StatusBadRequest, errors.New("only one manifest can be uploaded")
+			}
+			hasManifest = true
+
+			data, err := io.ReadAll(limitSize(part, maxManifestSize))
+			if status, err := h.requestError(err); status != 0 {
+				return nil, status, err
+			}
+			if errors.Is(err, errFileTooLarge) {
+				return nil, http.StatusBadRequest, fmt.Errorf("manifest is larger than %d bytes", maxManifestSize)
+			}
+			if err != nil {
+				return nil, http.StatusBadRequest, errors.New("failed to read manifest")
+			}
+			if form.manifest, err = dataset.ParseManifest(bytes.NewReader(data), part.FileName()); err != nil {
+				return nil, http.StatusBadRequest, err
+			}
+
+		case "dataset", "atomic", "label", "labels", "split":
+			v, err := readField(part)
+			if err != nil {
+				if status, err := h.requestError(err); status != 0 {
+					return nil, status, err
+				}
+				return nil, http.StatusBadRequest, fmt.Errorf("failed to read field %s", name)
+			}
+			switch name {
+			case "dataset":
+				if form.dataset != "" {
+					return nil, http.StatusBadRequest, errors.New("only one dataset can be given")
+				}
+				if err := h.openQuota(ctx, form, v); err != nil {
+					return nil, http.StatusBadRequest, err
+				}
+			case "label":
+				form.label = v
+			case "labels":
+				form.labels = append(form.labels, v)
+			case "split":
+				form.split = dataset.Split(v)
+			case "atomic":
+				if form.atomic, err = strconv.ParseBool(v); err != nil {
+					return nil, http.StatusBadRequest, errors.New("atomic must be true or false")
+				}
+			}
+		}
+		part.Close()
 	}
+}
 
-	if saved == 0 {
-		http.Error(w, "no valid audio files were saved", http.StatusBadRequest)
-		return
+// openQuota validates the dataset of an upload and loads what it may still
+// take
+func (h *DatasetUploadHandler) openQuota(ctx context.Context, form *uploadForm, name string) error {
+	if err := dataset.ValidateName(name); err != nil {
+		return err
+	}
+	loadRules := h.Datasets.Rules
+	if h.rules != nil {
+		loadRules = h.rules
+	}
+	rules, err := loadRules(ctx, name)
+	if err != nil {
+		ilog.L.Printf("rules of dataset %s: %v", name, err)
+		return errors.New("failed to load dataset rules")
+	}
+	usage, err := h.Datasets.Usage(name)
+	if err != nil {
+		ilog.L.Printf("usage of dataset %s: %v", name, err)
+		return errors.New("failed to measure dataset usage")
 	}
 
-	resp := datasetUploadResponse{
-		Dataset: fmt.Sprintf("local-audio/%s", datasetName),
-		Files:   saved,
+	form.dataset = name
+	form.quota = h.Datasets.Quota(rules)
+	form.usage = usage
+	return nil
+}
+
+// bytesLeft is how many more bytes the dataset may take, -1 for no limit
+func (f *uploadForm) bytesLeft() int64 {
+	if f.quota.MaxBytes <= 0 {
+		return -1
+	}
+	return max(0, f.quota.MaxBytes-f.usage.Bytes-f.received.Bytes)
+}
+
+// receive counts a file staged on disk against the quota, whether or not
+// it is accepted later
+func (f *uploadForm) receive(rf receivedFile) error {
+	if rf.err != nil {
+		return nil
+	}
+	f.received.Files++
+	f.received.Bytes += rf.size
+	if !f.quota.Allows(f.usage, f.received.Files, f.received.Bytes) {
+		return f.quotaError()
+	}
+	return nil
+}
+
+func (f *uploadForm) quotaError() error {
+	return fmt.Errorf("%w: dataset %s is limited to %d files, %d bytes", dataset.ErrQuotaExceeded, f.dataset, f.quota.MaxFiles, f.quota.MaxBytes)
+}
+
+// requestError reports a request over MaxRequestBytes, status 0 for any
+// other error
+func (h *DatasetUploadHandler) requestError(err error) (int, error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("upload is larger than %d bytes", maxErr.Limit)
+	}
+	return 0, nil
+}
+
+// checkUpload validates a received file. f holds the placement of the
+// file in the dataset.
+func checkUpload(f uploadFileReport, rf receivedFile, rules dataset.Rules) uploadFileReport {
+	f.Status = uploadAccepted
+
+	name, err := dataset.SanitizeFilename(rf.filename)
+	if err != nil {
+		f.Status = uploadRejected
+		f.Code = dataset.CodeInvalidFilename
+		f.Reason = err.Error()
+		return f
+	}
+	if f.Label != "" {
+		if err := dataset.ValidateLabel(f.Label); err != nil {
+			f.Status = uploadRejected
+			f.Code = dataset.CodeInvalidLabel
+			f.Reason = err.Error()
+			return f
+		}
+		name = f.Label + "/" + name
+	}
+
+	f.staged = rf.staged
+	f.size = rf.size
+	f.sum = rf.sum
+
+	info, err := dataset.ValidateAudio(rf.staged, rules)
+	f.Audio = info
+	if err != nil {
+		f.Status = uploadRejected
+		var verr *dataset.ValidationError
+		if errors.As(err, &verr) {
+			f.Code = verr.Code
+			f.Reason = verr.Message
+		} else {
+			f.Reason = err.Error()
+		}
+		return f
+	}
+
+	f.StoredAs = name
+	return f
+}
+
+// commitUpload moves a staged file into the dataset, see dataset.Service.Place
+func (h *DatasetUploadHandler) commitUpload(datasetName string, f *uploadFileReport) error {
+	stored, duplicate, err := h.Datasets.Place(datasetName, f.staged, f.StoredAs, f.sum)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case duplicate:
+		f.Status = uploadDuplicate
+	case stored != f.StoredAs:
+		f.Status = uploadRenamed
 	}
+	f.StoredAs = stored
+	return nil
+}
 
+func writeUploadReport(w http.ResponseWriter, status int, resp datasetUploadResponse) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(resp)
 }
+
+// GET /datasets/{name}?files=true
+func (h *DatasetUploadHandler) Get(w http.ResponseWriter, r *http.Request) {
+	withFiles := r.URL.Query().Get("files") == "true"
+
+	d, err := h.Datasets.Get(r.Context(), mux.Vars(r)["name"], withFiles)
+	if errors.Is(err, dataset.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d)
+}
+
+// GET /datasets/{name}/versions
+func (h *DatasetUploadHandler) Versions(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.Datasets.Versions(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if versions == nil {
+		versions = []dataset.Version{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+// GET /datasets/{name}/versions/{version}
+func (h *DatasetUploadHandler) Version(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil || version < 1 {
+		http.Error(w, "invalid version", http.StatusBadRequest)
+		return
+	}
+
+	v, err := h.Datasets.Version(r.Context(), mux.Vars(r)["name"], version)
+	if errors.Is(err, dataset.ErrVersionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// GET /datasets/{name}/rules
+func (h *DatasetUploadHandler) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.Datasets.Rules(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// PUT /datasets/{name}/rules
+// {"formats": ["wav"], "sample_rates": [16000], "channels": [1], "max_duration_seconds": 30}
+func (h *DatasetUploadHandler) SetRules(w http.ResponseWriter, r *http.Request) {
+	var rules dataset.Rules
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := rules.Check(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if err := dataset.ValidateName(name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Datasets.SetRules(r.Context(), name, rules); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+type splitsResponse struct {
+	// Policy is null until one is set, files then only have the splits
+	// given at upload
+	Policy *dataset.SplitPolicy  `json:"policy"`
+	Splits map[dataset.Split]int `json:"splits"`
+	// Version holds the splits after a change of policy
+	Version int    `json:"version,omitempty"`
+	Ref     string `json:"ref,omitempty"`
+}
+
+// GET /datasets/{name}/splits
+func (h *DatasetUploadHandler) GetSplits(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	policy, err := h.Datasets.SplitPolicy(r.Context(), name)
+	if errors.Is(err, dataset.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	d, err := h.Datasets.Get(r.Context(), name, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(splitsResponse{Policy: policy, Splits: d.Splits})
+}
+
+// PUT /datasets/{name}/splits
+// {"seed": 42, "train": 0.8, "validation": 0.1, "test": 0.1, "group_by": "speaker", "reassign": false}
+func (h *DatasetUploadHandler) SetSplits(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		dataset.SplitPolicy
+		// Reassign drops the current splits, test sets of earlier
+		// versions are no longer held out
+		Reassign bool `json:"reassign"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	v, err := h.Datasets.SetSplitPolicy(r.Context(), name, req.SplitPolicy, req.Reassign)
+	if errors.Is(err, dataset.ErrInvalidSplitPolicy) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errors.Is(err, dataset.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	d, err := h.Datasets.Get(r.Context(), name, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(splitsResponse{
+		Policy:  &req.SplitPolicy,
+		Splits:  d.Splits,
+		Version: v.Version,
+		Ref:     v.Ref,
+	})
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"audioml/internal/dataset"
+)
+
+func TestUploadStopsAtQuota(t *testing.T) {
+	root := t.TempDir()
+	h := &DatasetUploadHandler{
+		Datasets: dataset.NewService(dataset.PostgresRepository{}, root, dataset.Quota{MaxBytes: 1000}),
+		rules: func(context.Context, string) (dataset.Rules, error) {
+			return dataset.DefaultRules, nil
+		},
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("dataset", "emotion")
+	files := []struct {
+		name string
+		size int
+	}{
+		{"a.wav", 600},
+		{"b.wav", 8 << 20},
+	}
+	for _, f := range files {
+		fw, err := mw.CreateFormFile("files", f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(make([]byte, f.size))
+	}
+	mw.Close()
+
+	sent := &countingReader{r: bytes.NewReader(body.Bytes())}
+	req := httptest.NewRequest(http.MethodPost, "/datasets/upload", sent)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+
+	h.Upload(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d (%s), want 413", rec.Code, rec.Body)
+	}
+	if sent.n >= int64(body.Len())/2 {
+		t.Errorf("read %d of %d bytes, the upload must stop at the quota", sent.n, body.Len())
+	}
+
+	// Nothing staged or placed may be left behind
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			t.Errorf("%s left behind", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
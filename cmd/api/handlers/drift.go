@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"audioml/internal/drift"
+
+	"github.com/gorilla/mux"
+)
+
+const defaultDriftReports = 10
+
+type DriftHandler struct {
+	Service *drift.Service
+}
+
+func (h *DriftHandler) Register(r *mux.Router) {
+	r.HandleFunc("/ml/models/{name}/drift", h.Get).Methods(http.MethodGet)
+}
+
+// GET /ml/models/{name}/drift?limit=10
+// GET /ml/models/{name}/drift?refresh=true
+// Returns the latest stored reports, or computes a fresh one with refresh.
+func (h *DriftHandler) Get(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if boolQuery(r, "refresh") {
+		rep, err := h.Service.Check(r.Context(), name)
+		if errors.Is(err, drift.ErrNoActiveVersion) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rep)
+		return
+	}
+
+	limit, err := intQuery(r, "limit", defaultDriftReports)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		http.Error(w, "invalid limit", http.StatusBadRequest)
+		return
+	}
+
+	reports, err := h.Service.Reports(r.Context(), name, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reports)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"audioml/internal/evaluation"
+	"audioml/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+type EvaluationHandler struct {
+	Service *evaluation.Service
+}
+
+type startEvaluationRequest struct {
+	ModelVersionID string `json:"model_version_id"`
+	Dataset        string `json:"dataset"`
+}
+
+func (h *EvaluationHandler) Register(r *mux.Router) {
+	r.HandleFunc("/evaluations", h.Start).Methods(http.MethodPost)
+	r.HandleFunc("/evaluations/compare", h.Compare).Methods(http.MethodGet)
+	r.HandleFunc("/evaluations/{id}", h.Get).Methods(http.MethodGet)
+	r.HandleFunc("/ml/models/{id}/evaluations", h.ListByVersion).Methods(http.MethodGet)
+}
+
+func writeEvaluationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, evaluation.ErrEvaluationNotFound),
+		errors.Is(err, models.ErrModelNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, evaluation.ErrInvalidEvaluation):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrVersionArchived):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// POST /evaluations
+// {"model_version_id": "...", "dataset": "local-audio/emotion-test"}
+func (h *EvaluationHandler) Start(w http.ResponseWriter, r *http.Request) {
+	var req startEvaluationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	e, err := h.Service.StartJob(r.Context(), evaluation.StartRequest{
+		ModelVersionID: req.ModelVersionID,
+		DatasetSource:  req.Dataset,
+	})
+	if err != nil {
+		writeEvaluationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// GET /evaluations/{id}
+func (h *EvaluationHandler) Get(w http.ResponseWriter, r *http.Request) {
+	e, err := h.Service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeEvaluationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(e)
+}
+
+// GET /ml/models/{id}/evaluations
+func (h *EvaluationHandler) ListByVersion(w http.ResponseWriter, r *http.Request) {
+	evaluations, err := h.Service.ListByVersion(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeEvaluationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(evaluations)
+}
+
+// GET /evaluations/compare?dataset=local-audio/x&model=emotion
+// GET /evaluations/compare?dataset=local-audio/x&version_id=a&version_id=b&metric=loss&order=asc
+func (h *EvaluationHandler) Compare(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	order := q.Get("order")
+	if order != "" && order != "asc" && order != "desc" {
+		http.Error(w, "order must be asc or desc", http.StatusBadRequest)
+		return
+	}
+
+	cmp, err := h.Service.Compare(
+		r.Context(),
+		q.Get("dataset"),
+		q.Get("model"),
+		q["version_id"],
+		q.Get("metric"),
+		order == "asc",
+	)
+	if err != nil {
+		writeEvaluationError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(cmp)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"audioml/internal/feedback"
+
+	"github.com/gorilla/mux"
+)
+
+type FeedbackHandler struct {
+	Service *feedback.Service
+}
+
+type feedbackRequest struct {
+	Label   string `json:"label"`
+	Comment string `json:"comment"`
+}
+
+type exportFeedbackRequest struct {
+	Dataset        string `json:"dataset"`
+	ModelVersionID string `json:"model_version_id"`
+	All            bool   `json:"all"`
+}
+
+func (h *FeedbackHandler) Register(r *mux.Router) {
+	r.HandleFunc("/predictions/{id}/feedback", h.Submit).Methods(http.MethodPost)
+	r.HandleFunc("/ml/models/{name}/accuracy", h.Accuracy).Methods(http.MethodGet)
+	r.HandleFunc("/ml/models/{name}/feedback/export", h.Export).Methods(http.MethodPost)
+}
+
+func writeFeedbackError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, feedback.ErrPredictionNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, feedback.ErrInvalidFeedback):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, feedback.ErrDatasetExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, feedback.ErrNothingToExport):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// POST /predictions/{id}/feedback
+// {"label": "happy", "comment": "optional"}
+func (h *FeedbackHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "invalid prediction id", http.StatusBadRequest)
+		return
+	}
+
+	var req feedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	fb, err := h.Service.Submit(r.Context(), id, req.Label, req.Comment)
+	if err != nil {
+		writeFeedbackError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(fb)
+}
+
+// GET /ml/models/{name}/accuracy
+func (h *FeedbackHandler) Accuracy(w http.ResponseWriter, r *http.Request) {
+	accuracies, err := h.Service.Accuracy(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		writeFeedbackError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(accuracies)
+}
+
+// POST /ml/models/{name}/feedback/export
+// {"dataset": "emotion-corrections", "model_version_id": "optional", "all": false}
+// Only corrected predictions are exported unless all is set.
+func (h *FeedbackHandler) Export(w http.ResponseWriter, r *http.Request) {
+	var req exportFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	res, err := h.Service.Export(r.Context(), feedback.ExportRequest{
+		ModelName:      mux.Vars(r)["name"],
+		ModelVersionID: req.ModelVersionID,
+		Dataset:        req.Dataset,
+		All:            req.All,
+	})
+	if err != nil {
+		writeFeedbackError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"audioml/internal/inference"
+	ilog "audioml/internal/logger"
+	"audioml/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// maxPredictUpload bounds audio sent inline to the predict endpoint
+const maxPredictUpload = 200 << 20
+
+type InferenceHandler struct {
+	Service *inference.Service
+	Batch   *inference.BatchService
+	TmpDir  string
+}
+
+type predictRequest struct {
+	AudioID int64 `json:"audio_id"`
+}
+
+type startBatchRequest struct {
+	ModelVersionID string  `json:"model_version_id"`
+	Dataset        string  `json:"dataset"`
+	AudioIDs       []int64 `json:"audio_ids"`
+}
+
+func (h *InferenceHandler) Register(r *mux.Router) {
+	r.HandleFunc("/ml/models/{name}/predict", h.Predict).Methods(http.MethodPost)
+	r.HandleFunc("/ml/models/{name}/deployment/report", h.DeploymentReport).Methods(http.MethodGet)
+	r.HandleFunc("/inference/batch", h.StartBatch).Methods(http.MethodPost)
+	r.HandleFunc("/inference/batch/{id}", h.GetBatch).Methods(http.MethodGet)
+	r.HandleFunc("/inference/batch/{id}/results", h.BatchResults).Methods(http.MethodGet)
+}
+
+// writeInferenceError maps inference errors to HTTP statuses
+func writeInferenceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, inference.ErrNoActiveModel),
+		errors.Is(err, inference.ErrAudioNotFound),
+		errors.Is(err, inference.ErrBatchNotFound),
+		errors.Is(err, models.ErrNoDeployment),
+		errors.Is(err, models.ErrModelNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, inference.ErrInvalidBatch):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrVersionArchived):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// POST /ml/models/{name}/predict
+// Multipart with the audio in field "file" (or an "audio_id" field),
+// or JSON {"audio_id": 42} for audio uploaded through /upload.
+func (h *InferenceHandler) Predict(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	in, cleanup, err := h.readInput(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cleanup()
+
+	res, err := h.Service.Predict(r.Context(), name, in)
+	if err != nil {
+		writeInferenceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// GET /ml/models/{name}/deployment/report
+func (h *InferenceHandler) DeploymentReport(w http.ResponseWriter, r *http.Request) {
+	report, err := h.Service.DeploymentReport(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		writeInferenceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// POST /inference/batch
+// {"model_version_id": "...", "dataset": "local-audio/x"} or {"model_version_id": "...", "audio_ids": [1, 2]}
+func (h *InferenceHandler) StartBatch(w http.ResponseWriter, r *http.Request) {
+	var req startBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ModelVersionID == "" {
+		http.Error(w, "model_version_id is required", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.Batch.Start(r.Context(), inference.BatchRequest{
+		ModelVersionID: req.ModelVersionID,
+		DatasetSource:  req.Dataset,
+		AudioIDs:       req.AudioIDs,
+	})
+	if err != nil {
+		writeInferenceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// GET /inference/batch/{id}
+func (h *InferenceHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	job, err := h.Batch.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeInferenceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(job)
+}
+
+// GET /inference/batch/{id}/results?format=csv|jsonl
+// Results written so far are returned while the job is still running.
+func (h *InferenceHandler) BatchResults(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = inference.FormatCSV
+	}
+
+	var contentType string
+	switch format {
+	case inference.FormatCSV:
+		contentType = "text/csv"
+	case inference.FormatJSONL:
+		contentType = "application/x-ndjson"
+	default:
+		http.Error(w, "format must be csv or jsonl", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.Batch.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeInferenceError(w, err)
+		return
+	}
+
+	rw := &deferredWriter{w: w, header: func() {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("predictions-%s.%s", job.ID, format)))
+	}}
+
+	if err := h.Batch.WriteResults(r.Context(), job, format, rw); err != nil {
+		if !rw.started {
+			writeInferenceError(w, err)
+			return
+		}
+		ilog.L.Printf("write results of inference job %s: %v", job.ID, err)
+	}
+}
+
+// readInput extracts the audio to score. Inline audio is streamed to a
+// temp file which cleanup removes.
+func (h *InferenceHandler) readInput(r *http.Request) (inference.Input, func(), error) {
+	noop := func() {}
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		var req predictRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.AudioID <= 0 {
+			return inference.Input{}, noop, errors.New("expected multipart 'file' or JSON {\"audio_id\": ...}")
+		}
+		return inference.Input{AudioID: req.AudioID}, noop, nil
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return inference.Input{}, noop, errors.New("cannot parse multipart")
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return inference.Input{}, noop, errors.New("form field 'file' or 'audio_id' required")
+		}
+		if err != nil {
+			return inference.Input{}, noop, errors.New("cannot parse multipart")
+		}
+
+		switch part.FormName() {
+		case "audio_id":
+			v, _ := io.ReadAll(io.LimitReader(part, 32))
+			id, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+			if err != nil || id <= 0 {
+				return inference.Input{}, noop, errors.New("audio_id must be a positive integer")
+			}
+			return inference.Input{AudioID: id}, noop, nil
+
+		case "file":
+			path, err := h.spool(part, filepath.Ext(part.FileName()))
+			if err != nil {
+				return inference.Input{}, noop, err
+			}
+			return inference.Input{AudioPath: path}, func() { os.Remove(path) }, nil
+		}
+	}
+}
+
+func (h *InferenceHandler) spool(src io.Reader, ext string) (string, error) {
+	if err := os.MkdirAll(h.TmpDir, 0755); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(h.TmpDir, "predict-*"+ext)
+	if err != nil {
+		return "", err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(src, maxPredictUpload+1))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && n > maxPredictUpload {
+		err = errors.New("audio file too large")
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"audioml/internal/lineage"
+
+	"github.com/gorilla/mux"
+)
+
+type LineageHandler struct {
+	Service *lineage.Service
+}
+
+func (h *LineageHandler) Register(r *mux.Router) {
+	r.HandleFunc("/ml/models/{id}/lineage", h.ModelLineage).Methods(http.MethodGet)
+	r.HandleFunc("/datasets/{name}/lineage", h.DatasetLineage).Methods(http.MethodGet)
+}
+
+// GET /ml/models/{id}/lineage?format=dot&depth=N
+// Upstream graph: training job, datasets, audio files and base models.
+func (h *LineageHandler) ModelLineage(w http.ResponseWriter, r *http.Request) {
+	root := lineage.NodeRef{Type: lineage.NodeModelVersion, ID: mux.Vars(r)["id"]}
+	h.writeGraph(w, r, root, true)
+}
+
+// GET /datasets/{name}/lineage?format=dot&depth=N
+// Downstream graph: training jobs and the model versions they produced.
+func (h *LineageHandler) DatasetLineage(w http.ResponseWriter, r *http.Request) {
+	root := lineage.NodeRef{Type: lineage.NodeDataset, ID: "local-audio/" + mux.Vars(r)["name"]}
+	h.writeGraph(w, r, root, false)
+}
+
+func (h *LineageHandler) writeGraph(w http.ResponseWriter, r *http.Request, root lineage.NodeRef, upstream bool) {
+	depth, err := intQuery(r, "depth", lineage.DefaultMaxDepth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var g *lineage.Graph
+	if upstream {
+		g, err = h.Service.Upstream(r.Context(), root, depth)
+	} else {
+		g, err = h.Service.Downstream(r.Context(), root, depth)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+		_, _ = io.WriteString(w, g.DOT())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(g)
+}
@@ -2,8 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
+	ilog "audioml/internal/logger"
+	"audioml/internal/metrics"
 	"audioml/internal/models"
 
 	"github.com/gorilla/mux"
@@ -11,24 +17,430 @@ import (
 
 type ModelHandler struct {
 	Service *models.Service
+	// MaxImportBytes bounds an imported bundle and its extracted artifact,
+	// 0 for no limit
+	MaxImportBytes int64
 }
 
 func (h *ModelHandler) Register(r *mux.Router) {
+	r.HandleFunc("/ml/models", h.List).Methods("GET")
+	r.HandleFunc("/ml/models/import", h.Import).Methods("POST")
+	r.HandleFunc("/ml/models/{name}", h.GetRegisteredModel).Methods("GET")
+	r.HandleFunc("/ml/models/{name}", h.UpdateRegisteredModel).Methods("PATCH")
+	r.HandleFunc("/ml/models/{id}/metadata", h.UpdateVersionMetadata).Methods("PATCH")
+	r.HandleFunc("/ml/models/{id}", h.Archive).Methods("DELETE")
+	r.HandleFunc("/ml/models/{id}/artifact", h.DownloadArtifact).Methods("GET", "HEAD")
+	r.HandleFunc("/ml/models/{id}/verify", h.Verify).Methods("POST")
+	r.HandleFunc("/ml/models/{id}/card", h.GetCard).Methods("GET")
+	r.HandleFunc("/ml/models/{id}/metrics", h.GetMetrics).Methods("GET")
+	r.HandleFunc("/ml/models/{id}/export", h.Export).Methods("GET")
 	r.HandleFunc("/ml/models/{name}/versions", h.ListVersions).Methods("GET")
 	r.HandleFunc("/ml/models/{name}/active", h.GetActive).Methods("GET")
 	r.HandleFunc("/ml/models/{id}/activate", h.Activate).Methods("POST")
+	r.HandleFunc("/ml/models/{name}/rollback", h.Rollback).Methods("POST")
+	r.HandleFunc("/ml/models/{name}/activations", h.ListActivations).Methods("GET")
+	r.HandleFunc("/ml/models/{name}/deployment", h.GetDeployment).Methods("GET")
+	r.HandleFunc("/ml/models/{name}/deployment", h.SetDeployment).Methods("PUT")
+	r.HandleFunc("/ml/models/{name}/deployment", h.DeleteDeployment).Methods("DELETE")
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+type listModelsResponse struct {
+	Models []models.ModelSummary `json:"models"`
+	Total  int                   `json:"total"`
+	Limit  int                   `json:"limit"`
+	Offset int                   `json:"offset"`
+}
+
+type modelMetricsResponse struct {
+	ModelVersionID       string                          `json:"model_version_id"`
+	Metrics              map[string]float64              `json:"metrics"`
+	ClassMetrics         map[string]metrics.ClassMetrics `json:"class_metrics,omitempty"`
+	ConfusionMatrix      *metrics.ConfusionMatrix        `json:"confusion_matrix,omitempty"`
+	ConfusionMatrixTable string                          `json:"confusion_matrix_table,omitempty"`
+}
+
+type updateRegisteredModelRequest struct {
+	Description *string   `json:"description"`
+	Owner       *string   `json:"owner"`
+	TaskType    *string   `json:"task_type"`
+	ClassLabels *[]string `json:"class_labels"`
+}
+
+type updateVersionMetadataRequest struct {
+	Description *string            `json:"description"`
+	Tags        *[]string          `json:"tags"`
+	AddTags     []string           `json:"add_tags"`
+	RemoveTags  []string           `json:"remove_tags"`
+	Annotations map[string]*string `json:"annotations"`
+}
+
+type rollbackRequest struct {
+	Steps int `json:"steps"`
+}
+
+type deploymentRequest struct {
+	CandidateVersionID string `json:"candidate_version_id"`
+	Mode               string `json:"mode"`
+	Percent            *int   `json:"percent"`
+}
+
+// writeModelError maps registry errors to HTTP statuses
+func writeModelError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrModelNotFound),
+		errors.Is(err, models.ErrNoDeployment):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, models.ErrInvalidBundle),
+		errors.Is(err, models.ErrInvalidDeployment):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, models.ErrNoPreviousActivation),
+		errors.Is(err, models.ErrActiveVersion),
+		errors.Is(err, models.ErrVersionArchived):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GET /ml/models?limit=&offset=
+// GET /ml/models?tag=...
+// Without tags it pages through registered model names with their latest
+// and active versions. With tags it returns the matching versions instead.
+func (h *ModelHandler) List(w http.ResponseWriter, r *http.Request) {
+	if tags := r.URL.Query()["tag"]; len(tags) > 0 {
+		h.search(w, r, tags)
+		return
+	}
+
+	limit, err := intQuery(r, "limit", defaultPageSize)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	offset, err := intQuery(r, "offset", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if limit < 1 || limit > maxPageSize || offset < 0 {
+		http.Error(w, "limit must be between 1 and 200 and offset >= 0", http.StatusBadRequest)
+		return
+	}
+
+	summaries, total, err := h.Service.ListModels(r.Context(), limit, offset)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(listModelsResponse{
+		Models: summaries,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+func (h *ModelHandler) search(w http.ResponseWriter, r *http.Request, tags []string) {
+	versions, err := h.Service.FindByTags(r.Context(), tags)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(versions)
+}
+
+// GET /ml/models/{name}
+func (h *ModelHandler) GetRegisteredModel(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	rm, err := h.Service.GetRegisteredModel(r.Context(), name)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rm)
+}
+
+// PATCH /ml/models/{name}
+func (h *ModelHandler) UpdateRegisteredModel(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req updateRegisteredModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rm, err := h.Service.UpdateRegisteredModel(r.Context(), name, models.RegisteredModelUpdate{
+		Description: req.Description,
+		Owner:       req.Owner,
+		TaskType:    req.TaskType,
+		ClassLabels: req.ClassLabels,
+	})
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rm)
+}
+
+// PATCH /ml/models/{id}/metadata
+func (h *ModelHandler) UpdateVersionMetadata(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	var req updateVersionMetadataRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	model, err := h.Service.UpdateVersionMetadata(r.Context(), id, models.VersionMetadataUpdate{
+		Description: req.Description,
+		Tags:        req.Tags,
+		AddTags:     req.AddTags,
+		RemoveTags:  req.RemoveTags,
+		Annotations: req.Annotations,
+	})
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(model)
+}
+
+// DELETE /ml/models/{id}?purge_artifact=true
+// Archives the version; the active version is refused with 409.
+func (h *ModelHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	model, err := h.Service.Archive(r.Context(), id, boolQuery(r, "purge_artifact"))
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(model)
+}
+
+// GET /ml/models/{id}/artifact
+// Streams the artifact with Content-Length, an ETag holding the recorded
+// SHA-256 and Range support. A size that no longer matches the recorded
+// one is refused instead of serving a corrupt file.
+func (h *ModelHandler) DownloadArtifact(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	model, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	obj, err := h.Service.OpenArtifact(r.Context(), model)
+	if err != nil {
+		http.Error(w, "artifact unavailable: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	defer obj.Close()
+
+	if model.ArtifactSize > 0 && obj.Size != model.ArtifactSize {
+		http.Error(w, "artifact integrity check failed: size mismatch", http.StatusInternalServerError)
+		return
+	}
+
+	if model.ArtifactSHA256 != "" {
+		w.Header().Set("ETag", `"`+model.ArtifactSHA256+`"`)
+		w.Header().Set("X-Checksum-Sha256", model.ArtifactSHA256)
+	}
+	filename := fmt.Sprintf("%s-v%d-%s", model.Name, model.Version, obj.Name)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	http.ServeContent(w, r, filename, obj.ModTime, obj)
+}
+
+// POST /ml/models/{id}/verify?backfill=true
+// Re-hashes the artifact; backfill records a digest for legacy versions.
+func (h *ModelHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	res, err := h.Service.Verify(r.Context(), id, boolQuery(r, "backfill"))
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !res.OK {
+		w.WriteHeader(http.StatusConflict)
+	}
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// GET /ml/models/{id}/card?format=md
+// JSON by default, Markdown with format=md or an Accept: text/markdown header.
+func (h *ModelHandler) GetCard(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	card, err := h.Service.Card(r.Context(), id)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "md" || format == "markdown" || strings.Contains(r.Header.Get("Accept"), "text/markdown") {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		_, _ = io.WriteString(w, card.Markdown())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(card)
+}
+
+// GET /ml/models/{id}/metrics?format=table
+// format=table returns only the rendered per-class and confusion tables.
+func (h *ModelHandler) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	model, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "table" {
+		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+		if len(model.ClassMetrics) > 0 {
+			_, _ = io.WriteString(w, metrics.ClassTable(model.ClassMetrics)+"\n")
+		}
+		if model.ConfusionMatrix != nil {
+			_, _ = io.WriteString(w, model.ConfusionMatrix.Table())
+		}
+		return
+	}
+
+	resp := modelMetricsResponse{
+		ModelVersionID:  model.ID,
+		Metrics:         model.Metrics,
+		ClassMetrics:    model.ClassMetrics,
+		ConfusionMatrix: model.ConfusionMatrix,
+	}
+	if model.ConfusionMatrix != nil {
+		resp.ConfusionMatrixTable = model.ConfusionMatrix.Table()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// GET /ml/models/{id}/export
+// Streams a tar.gz bundle that POST /ml/models/import accepts.
+func (h *ModelHandler) Export(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	model, err := h.Service.Get(r.Context(), id)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	// Headers go out with the first bundle byte, earlier failures get a real status
+	bw := &deferredWriter{w: w, header: func() {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-v%d.tar.gz", model.Name, model.Version)))
+	}}
+
+	if err := h.Service.ExportBundle(r.Context(), id, bw); err != nil {
+		if !bw.started {
+			writeModelError(w, err)
+			return
+		}
+		ilog.L.Printf("export %s: %v", id, err)
+	}
+}
+
+// POST /ml/models/import?name=...
+// Accepts the bundle as the raw body or as the "bundle" multipart field.
+func (h *ModelHandler) Import(w http.ResponseWriter, r *http.Request) {
+	if h.MaxImportBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.MaxImportBytes)
+	}
+	body := io.Reader(r.Body)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, "cannot parse multipart", http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				http.Error(w, "form field 'bundle' required", http.StatusBadRequest)
+				return
+			}
+			if part.FormName() == "bundle" {
+				body = part
+				break
+			}
+		}
+	}
+
+	model, err := h.Service.ImportBundle(r.Context(), body, r.URL.Query().Get("name"), h.MaxImportBytes)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		http.Error(w, fmt.Sprintf("bundle is larger than %d bytes", maxErr.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(model)
 }
 
 // GET /ml/models/{name}/versions
+// Optional ?tag=... filters keep only versions carrying all given tags,
+// archived versions are hidden unless ?include_archived=true.
 func (h *ModelHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
 	name := mux.Vars(r)["name"]
 
-	versions, err := h.Service.ListVersions(r.Context(), name)
+	versions, err := h.Service.ListVersions(r.Context(), name, boolQuery(r, "include_archived"))
 	if err != nil {
 		http.Error(w, err.Error(), 500)
 		return
 	}
 
+	if tags := r.URL.Query()["tag"]; len(tags) > 0 {
+		filtered := []models.ModelVersion{}
+		for _, v := range versions {
+			if v.HasTags(tags...) {
+				filtered = append(filtered, v)
+			}
+		}
+		versions = filtered
+	}
+
 	json.NewEncoder(w).Encode(versions)
 }
 
@@ -55,9 +467,100 @@ func (h *ModelHandler) Activate(w http.ResponseWriter, r *http.Request) {
 	id := mux.Vars(r)["id"]
 
 	if err := h.Service.Activate(r.Context(), id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeModelError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /ml/models/{name}/rollback
+// Body is optional: {"steps": 2} goes two activations back, default is one.
+func (h *ModelHandler) Rollback(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	req := rollbackRequest{Steps: 1}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Steps < 1 {
+		http.Error(w, "steps must be >= 1", http.StatusBadRequest)
+		return
+	}
+
+	model, err := h.Service.Rollback(r.Context(), name, req.Steps)
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(model)
+}
+
+// GET /ml/models/{name}/activations
+func (h *ModelHandler) ListActivations(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	history, err := h.Service.ActivationHistory(r.Context(), name)
+	if err != nil {
+		writeModelError(w, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(history)
+}
+
+// GET /ml/models/{name}/deployment
+func (h *ModelHandler) GetDeployment(w http.ResponseWriter, r *http.Request) {
+	d, err := h.Service.GetDeployment(r.Context(), mux.Vars(r)["name"])
+	if err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(d)
+}
+
+// PUT /ml/models/{name}/deployment
+// {"candidate_version_id": "...", "mode": "canary", "percent": 10}
+// Shadow deployments mirror all traffic unless percent is given.
+func (h *ModelHandler) SetDeployment(w http.ResponseWriter, r *http.Request) {
+	var req deploymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	d := &models.Deployment{
+		ModelName:          mux.Vars(r)["name"],
+		CandidateVersionID: req.CandidateVersionID,
+		Mode:               models.DeploymentMode(req.Mode),
+	}
+	switch {
+	case req.Percent != nil:
+		d.Percent = *req.Percent
+	case d.Mode == models.DeploymentShadow:
+		d.Percent = 100
+	}
+
+	if err := h.Service.Deploy(r.Context(), d); err != nil {
+		writeModelError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(d)
+}
+
+// DELETE /ml/models/{name}/deployment
+func (h *ModelHandler) DeleteDeployment(w http.ResponseWriter, r *http.Request) {
+	if err := h.Service.Undeploy(r.Context(), mux.Vars(r)["name"]); err != nil {
+		writeModelError(w, err)
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"strings"
+)
+
+// maxFieldSize bounds the plain form fields of a multipart upload
+const maxFieldSize = 4 << 10
+
+var errFileTooLarge = errors.New("file too large")
+
+// sizeLimiter counts the bytes read and fails with errFileTooLarge once
+// more than limit bytes are read, a limit of 0 meaning none. Unlike
+// io.LimitReader the caller can tell a file that fits from one that was
+// cut, even when the error is wrapped on the way.
+type sizeLimiter struct {
+	r        io.Reader
+	limit    int64
+	n        int64
+	exceeded bool
+}
+
+func limitSize(r io.Reader, limit int64) *sizeLimiter {
+	return &sizeLimiter{r: r, limit: limit}
+}
+
+func (l *sizeLimiter) Read(p []byte) (int, error) {
+	if l.limit > 0 && l.n >= l.limit {
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			l.exceeded = true
+			return 0, errFileTooLarge
+		}
+		return 0, err
+	}
+	if l.limit > 0 && int64(len(p)) > l.limit-l.n {
+		p = p[:l.limit-l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// receiveFile streams src to a new file at path, hashing it on the way.
+// Nothing is left at path on error.
+func receiveFile(src io.Reader, path string, limit int64) (size int64, sum string, err error) {
+	dst, err := os.Create(path)
+	if err != nil {
+		return 0, "", err
+	}
+
+	hash := sha256.New()
+	size, err = io.Copy(io.MultiWriter(dst, hash), limitSize(src, limit))
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return 0, "", err
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// partFilename is the file name of a part as sent by the client, folders
+// included. multipart.Part.FileName keeps the base name only; the result
+// must be sanitized before use.
+func partFilename(part *multipart.Part) string {
+	_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	if err != nil || params["filename"] == "" {
+		return part.FileName()
+	}
+	return params["filename"]
+}
+
+// readField reads a plain multipart form value
+func readField(part io.Reader) (string, error) {
+	v, err := io.ReadAll(limitSize(part, maxFieldSize))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(v)), nil
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// intQuery reads an integer query parameter, falling back to def when absent
+func intQuery(r *http.Request, key string, def int) (int, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer", key)
+	}
+	return n, nil
+}
+
+// boolQuery reads a boolean query parameter, absent means false
+func boolQuery(r *http.Request, key string) bool {
+	b, _ := strconv.ParseBool(r.URL.Query().Get(key))
+	return b
+}
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	natslib "github.com/nats-io/nats.go"
+
+	"audioml/internal/audio"
+	"audioml/internal/dataset"
+	ilog "audioml/internal/logger"
+	"audioml/internal/s3"
+	"audioml/internal/uploads"
+)
+
+// tusVersion is the version of the tus resumable upload protocol spoken
+// by /uploads, so that existing tus clients can be used
+const tusVersion = "1.0.0"
+
+// ResumableUploadHandler receives large files in chunks. Once complete, an
+// upload is added to a dataset when its metadata names one, and stored as
+// a raw audio file otherwise.
+type ResumableUploadHandler struct {
+	Service  *uploads.Service
+	Datasets *DatasetUploadHandler
+	S3Client *s3.MinioClient
+	Audio    *audio.Service
+	// JS is optional, without it raw audio files are queued but their
+	// ingestion is not published
+	JS natslib.JetStreamContext
+}
+
+func (h *ResumableUploadHandler) Register(r *mux.Router) {
+	r.HandleFunc("/uploads", h.Options).Methods(http.MethodOptions)
+	r.HandleFunc("/uploads", h.Create).Methods(http.MethodPost)
+	r.HandleFunc("/uploads/{id}", h.Head).Methods(http.MethodHead)
+	r.HandleFunc("/uploads/{id}", h.Get).Methods(http.MethodGet)
+	r.HandleFunc("/uploads/{id}", h.Patch).Methods(http.MethodPatch)
+	r.HandleFunc("/uploads/{id}", h.Delete).Methods(http.MethodDelete)
+}
+
+// OPTIONS /uploads
+func (h *ResumableUploadHandler) Options(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", "creation,termination,expiration")
+	if max := h.Service.MaxBytes(); max > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(max, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /uploads
+// Upload-Length: total size in bytes
+// Upload-Metadata: comma separated "key base64(value)" pairs, filename
+// required; dataset, label and split for a dataset upload
+func (h *ResumableUploadHandler) Create(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if !checkTusVersion(w, r) {
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		http.Error(w, "Upload-Length header is required", http.StatusBadRequest)
+		return
+	}
+	meta, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := uploads.CreateRequest{
+		Target:   uploads.TargetAudio,
+		Filename: meta["filename"],
+		Dataset:  meta["dataset"],
+		Label:    meta["label"],
+		Split:    meta["split"],
+		Length:   length,
+	}
+	if req.Dataset != "" {
+		req.Target = uploads.TargetDataset
+	}
+
+	u, err := h.Service.Create(r.Context(), req)
+	if err != nil {
+		writeResumableError(w, "create upload", err)
+		return
+	}
+
+	w.Header().Set("Location", "/uploads/"+u.ID)
+	w.Header().Set("Upload-Offset", "0")
+	w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(u)
+}
+
+// HEAD /uploads/{id}
+// The offset to resume from
+func (h *ResumableUploadHandler) Head(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Cache-Control", "no-store")
+
+	u, err := h.Service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, uploads.ErrNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		ilog.L.Printf("get upload: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	if u.Status == uploads.StatusUploading {
+		w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GET /uploads/{id}
+// Progress and, once finalized, the outcome of the upload
+func (h *ResumableUploadHandler) Get(w http.ResponseWriter, r *http.Request) {
+	u, err := h.Service.Get(r.Context(), mux.Vars(r)["id"])
+	if err != nil {
+		writeResumableError(w, "get upload", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(u)
+}
+
+// PATCH /uploads/{id}
+// Content-Type: application/offset+octet-stream
+// Upload-Offset: bytes already received, as returned by HEAD
+func (h *ResumableUploadHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if !checkTusVersion(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type must be application/offset+octet-stream", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Upload-Offset header is required", http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.Service.Append(r.Context(), mux.Vars(r)["id"], offset, r.Body)
+	if u == nil {
+		writeResumableError(w, "append to upload", err)
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	if err != nil {
+		// The bytes received are kept, the client resumes from the offset
+		ilog.L.Printf("upload %s interrupted at %d bytes: %v", u.ID, u.Offset, err)
+		http.Error(w, "upload interrupted", http.StatusInternalServerError)
+		return
+	}
+
+	if u.Complete() {
+		claimed, err := h.Service.Finalize(r.Context(), u)
+		if err != nil {
+			writeResumableError(w, "finalize upload", err)
+			return
+		}
+		// Finalizing may take a while for a large file, its outcome is
+		// reported by GET /uploads/{id}
+		if claimed {
+			go h.finalize(context.Background(), *u)
+		}
+	} else {
+		w.Header().Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /uploads/{id}
+func (h *ResumableUploadHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if err := h.Service.Terminate(r.Context(), mux.Vars(r)["id"]); err != nil {
+		writeResumableError(w, "terminate upload", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finalize hands a complete upload over to its target and records the
+// outcome
+func (h *ResumableUploadHandler) finalize(ctx context.Context, u uploads.Upload) {
+	var result any
+	var err error
+	switch u.Target {
+	case uploads.TargetDataset:
+		result, err = h.finalizeDataset(ctx, u)
+	case uploads.TargetAudio:
+		result, err = h.finalizeAudio(ctx, u)
+	default:
+		err = fmt.Errorf("unknown target %q", u.Target)
+	}
+	if err != nil {
+		ilog.L.Printf("finalize upload %s: %v", u.ID, err)
+	}
+
+	if err := h.Service.Finish(ctx, u.ID, result, err); err != nil {
+		ilog.L.Printf("record outcome of upload %s: %v", u.ID, err)
+	}
+}
+
+// finalizeDataset adds the file to its dataset exactly like a multipart
+// dataset upload of that single file, an archive being expanded
+func (h *ResumableUploadHandler) finalizeDataset(ctx context.Context, u uploads.Upload) (any, error) {
+	staged := h.Service.Path(u.ID)
+	form := &uploadForm{
+		dataset: u.Dataset,
+		label:   u.Label,
+		split:   dataset.Split(u.Split),
+	}
+
+	if isArchive(u.Filename) {
+		if max := h.Datasets.ArchiveMaxBytes; max > 0 && u.Length > max {
+			return nil, fmt.Errorf("archive is larger than %d bytes", max)
+		}
+		form.files = h.Datasets.expandArchive(staged, u.Filename)
+		// Entries are extracted next to the upload, drop those left over
+		defer func() {
+			for _, rf := range form.files {
+				os.Remove(rf.staged)
+			}
+		}()
+	} else {
+		sum, err := dataset.FileSHA256(staged)
+		if err != nil {
+			return nil, err
+		}
+		form.files = []receivedFile{{
+			filename: u.Filename,
+			staged:   staged,
+			size:     u.Length,
+			sum:      sum,
+		}}
+	}
+
+	resp, status, err := h.Datasets.ingest(ctx, form)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		if len(resp.Report) == 1 {
+			return resp, fmt.Errorf("file rejected: %s", resp.Report[0].Reason)
+		}
+		return resp, fmt.Errorf("all %d files rejected", len(resp.Report))
+	}
+	return resp, nil
+}
+
+// finalizeAudio stores the file in object storage and queues its
+// ingestion like POST /upload does. Content uploaded before is not
+// stored again.
+func (h *ResumableUploadHandler) finalizeAudio(ctx context.Context, u uploads.Upload) (any, error) {
+	path := h.Service.Path(u.ID)
+	sum, err := dataset.FileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+	known, err := knownAudio(ctx, h.Audio, h.JS, sum)
+	if err != nil {
+		return nil, err
+	}
+	if known != nil {
+		return *known, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ext := filepath.Ext(u.Filename)
+	if ext == "" {
+		ext = ".wav"
+	}
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	objectName := fmt.Sprintf("raw/%s%s", uuid.New().String(), ext)
+	if err := h.S3Client.UploadMultipart(ctx, h.S3Client.Bucket, objectName, f, u.Length, uploadPartSize, contentType); err != nil {
+		return nil, fmt.Errorf("store in object storage: %w", err)
+	}
+
+	file := &audio.File{S3Path: objectName, Filename: u.Filename, SHA256: sum, SizeBytes: u.Length}
+	resp, err := recordAudio(ctx, h.Audio, h.S3Client, h.JS, file, path)
+	if err != nil {
+		return nil, fmt.Errorf("queue ingestion: %w", err)
+	}
+	return resp, nil
+}
+
+func checkTusVersion(w http.ResponseWriter, r *http.Request) bool {
+	if v := r.Header.Get("Tus-Resumable"); v != "" && v != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "unsupported tus version", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// parseUploadMetadata decodes the Upload-Metadata header, "key value"
+// pairs separated by commas with base64 encoded values
+func parseUploadMetadata(header string) (map[string]string, error) {
+	meta := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return meta, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("invalid Upload-Metadata")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %s", key)
+		}
+		meta[key] = string(decoded)
+	}
+	return meta, nil
+}
+
+func writeResumableError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, uploads.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, uploads.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, uploads.ErrInvalidUpload):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, uploads.ErrOffsetMismatch), errors.Is(err, uploads.ErrNotWritable):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, uploads.ErrLocked):
+		http.Error(w, err.Error(), http.StatusLocked)
+	default:
+		ilog.L.Printf("%s: %v", action, err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+	}
+}
@@ -14,8 +14,9 @@ type TrainingHandler struct {
 }
 
 type startTrainingRequest struct {
-	Dataset string `json:"dataset"`
-	Model   string `json:"model"`
+	Dataset   string `json:"dataset"`
+	Model     string `json:"model"`
+	BaseModel string `json:"base_model"`
 }
 
 func (h *TrainingHandler) Register(r *mux.Router) {
@@ -30,11 +31,11 @@ func (h *TrainingHandler) StartTraining(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	job, err := h.TrainingService.StartJob(
-		r.Context(),
-		req.Dataset,
-		req.Model,
-	)
+	job, err := h.TrainingService.StartJob(r.Context(), training.StartRequest{
+		DatasetSource: req.Dataset,
+		ModelName:     req.Model,
+		BaseModelID:   req.BaseModel,
+	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
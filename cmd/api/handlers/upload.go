@@ -1,18 +1,24 @@
 package handlers
 
 import (
-	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"os"
 	"path/filepath"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/jackc/pgx/v5"
 	natslib "github.com/nats-io/nats.go"
 
+	"audioml/internal/audio"
 	"audioml/internal/db"
 	ilog "audioml/internal/logger"
 	"audioml/internal/nats"
@@ -22,8 +28,17 @@ import (
 type UploadHandler struct {
 	S3Client *s3.MinioClient
 	JS       natslib.JetStreamContext
+	Audio    *audio.Service
+	// TmpDir holds a local copy of uploads while they are fingerprinted
+	TmpDir string
+	// MaxFileBytes bounds the uploaded file, 0 for no limit
+	MaxFileBytes int64
 }
 
+// uploadPartSize is the multipart chunk used when streaming an upload of
+// unknown length to MinIO
+const uploadPartSize = 16 << 20
+
 func (h *UploadHandler) Register(r *mux.Router) {
 	r.HandleFunc("/upload", h.HandleUpload).Methods(http.MethodPost)
 }
@@ -32,6 +47,28 @@ type registerS3Req struct {
 	S3URL string `json:"s3_url"`
 }
 
+// Outcome of an audio upload
+const (
+	audioQueued    = "queued"
+	audioDuplicate = "duplicate" // the same content was uploaded before, nothing is stored
+)
+
+// ingestPublishFailed marks an ingestion job whose event never reached
+// JetStream, it is queued again on the next upload of the file
+const ingestPublishFailed = "publish_failed"
+
+type audioUploadResponse struct {
+	Status string `json:"status"`
+	ID     int64  `json:"id"`
+	S3Path string `json:"s3_path,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Known files that sound the same, when fingerprinting is on
+	NearDuplicates []audio.NearDuplicate `json:"near_duplicates,omitempty"`
+	// NearDuplicatesSkipped tells why the file could not be compared with
+	// the known ones, fingerprinting being on
+	NearDuplicatesSkipped string `json:"near_duplicates_skipped,omitempty"`
+}
+
 func (h *UploadHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	contentType := r.Header.Get("Content-Type")
@@ -46,29 +83,43 @@ func (h *UploadHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "s3_url required", http.StatusBadRequest)
 			return
 		}
-		if err := insertAudioAndPublish(ctx, req.S3URL, "", h.S3Client, h.JS); err != nil {
+		f := &audio.File{S3Path: req.S3URL}
+		if _, _, err := insertAudioAndPublish(ctx, h.Audio, f, h.JS); err != nil {
 			ilog.L.Printf("register s3 error: %v", err)
 			http.Error(w, "internal error", http.StatusInternalServerError)
 			return
 		}
-		w.WriteHeader(http.StatusAccepted)
-		w.Write([]byte(`{"status":"queued"}`))
+		writeAudioUpload(w, audioUploadResponse{Status: audioQueued, ID: f.ID, S3Path: f.S3Path})
 		return
 	}
 
-	// Expect multipart file upload under field name "file"
-	if err := r.ParseMultipartForm(500 << 20); err != nil {
+	// Expect multipart file upload under field name "file", streamed to
+	// MinIO without knowing its size up front
+	mr, err := r.MultipartReader()
+	if err != nil {
 		http.Error(w, "cannot parse multipart", http.StatusBadRequest)
 		return
 	}
-	file, fh, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "file form field 'file' required", http.StatusBadRequest)
-		return
+	var part *multipart.Part
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			http.Error(w, "file form field 'file' required", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "cannot parse multipart", http.StatusBadRequest)
+			return
+		}
+		if p.FormName() == "file" {
+			part = p
+			break
+		}
+		p.Close()
 	}
-	defer file.Close()
+	defer part.Close()
 
-	filename := fh.Filename
+	filename := part.FileName()
 	if filename == "" {
 		filename = uuid.New().String() + ".wav"
 	}
@@ -79,54 +130,212 @@ func (h *UploadHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
 
 	objectName := fmt.Sprintf("raw/%s%s", uuid.New().String(), ext)
 
-	// Stream upload directly to MinIO using PutObject
-	var buf bytes.Buffer
-	size, err := io.Copy(&buf, file)
-	if err != nil {
-		ilog.L.Printf("read multipart file: %v", err)
-		http.Error(w, "read error", http.StatusInternalServerError)
-		return
+	// The content hash is only known once the file is stored, a duplicate
+	// object is removed afterwards. Fingerprinting needs a local copy.
+	hash := sha256.New()
+	var sink io.Writer = hash
+	var local *os.File
+	if h.Audio.Fingerprints() {
+		err = os.MkdirAll(h.TmpDir, 0755)
+		if err == nil {
+			local, err = os.CreateTemp(h.TmpDir, "upload-*"+ext)
+		}
+		if err != nil {
+			ilog.L.Printf("local copy of upload: %v", err)
+			http.Error(w, "upload error", http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(local.Name())
+		defer local.Close()
+		sink = io.MultiWriter(hash, local)
 	}
 
-	if err := h.S3Client.UploadFromReader(ctx, objectName, bytes.NewReader(buf.Bytes()), size, fh.Header.Get("Content-Type")); err != nil {
+	body := limitSize(part, h.MaxFileBytes)
+	err = h.S3Client.UploadMultipart(
+		ctx,
+		h.S3Client.Bucket,
+		objectName,
+		io.TeeReader(body, sink),
+		-1,
+		uploadPartSize,
+		part.Header.Get("Content-Type"),
+	)
+	if body.exceeded {
+		http.Error(w, fmt.Sprintf("file is larger than %d bytes", h.MaxFileBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
 		ilog.L.Printf("s3 upload: %v", err)
 		http.Error(w, "upload error", http.StatusInternalServerError)
 		return
 	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	ilog.L.Printf("uploaded %s as %s: %d bytes, sha256 %s", filename, objectName, body.n, sum)
 
-	// Insert DB row and publish ingest event
-	if err := insertAudioAndPublish(ctx, objectName, filename, h.S3Client, h.JS); err != nil {
+	f := &audio.File{S3Path: objectName, Filename: filename, SHA256: sum, SizeBytes: body.n}
+	localPath := ""
+	if local != nil {
+		localPath = local.Name()
+	}
+	resp, err := recordAudio(ctx, h.Audio, h.S3Client, h.JS, f, localPath)
+	if err != nil {
 		ilog.L.Printf("insert/publish: %v", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	writeAudioUpload(w, resp)
+}
+
+// recordAudio records an audio file stored at f.S3Path and queues its
+// ingestion, looking for near duplicates in the local copy at path if
+// any. When the same content was uploaded before, the stored object is
+// removed and the existing file is returned.
+func recordAudio(ctx context.Context, audios *audio.Service, s3client *s3.MinioClient, js natslib.JetStreamContext, f *audio.File, path string) (audioUploadResponse, error) {
+	stored := f.S3Path
+
+	known, err := knownAudio(ctx, audios, js, f.SHA256)
+	if err != nil {
+		return audioUploadResponse{}, err
+	}
+	if known != nil {
+		removeDuplicateObject(ctx, s3client, stored)
+		return *known, nil
+	}
+
+	var nearDups []audio.NearDuplicate
+	skipped := ""
+	if path != "" {
+		nearDups, err = audios.NearDuplicates(ctx, f, path)
+		if errors.Is(err, audio.ErrNoFingerprint) {
+			skipped = err.Error()
+		} else if err != nil {
+			ilog.L.Printf("near duplicates of %s: %v", f.Filename, err)
+		}
+	}
+
+	duplicate, requeued, err := insertAudioAndPublish(ctx, audios, f, js)
+	if duplicate {
+		// Another upload of the same content won the race
+		removeDuplicateObject(ctx, s3client, stored)
+	}
+	if err != nil {
+		return audioUploadResponse{}, err
+	}
+
+	resp := audioUploadResponse{
+		Status:                audioQueued,
+		ID:                    f.ID,
+		S3Path:                f.S3Path,
+		SHA256:                f.SHA256,
+		NearDuplicates:        nearDups,
+		NearDuplicatesSkipped: skipped,
+	}
+	if duplicate && !requeued {
+		resp.Status = audioDuplicate
+	}
+	return resp, nil
+}
+
+// knownAudio answers an upload of content already recorded, nil when it
+// is new. The ingestion of the recorded file is queued again if it never
+// reached JetStream.
+func knownAudio(ctx context.Context, audios *audio.Service, js natslib.JetStreamContext, sum string) (*audioUploadResponse, error) {
+	existing, err := audios.Lookup(ctx, sum)
+	if errors.Is(err, audio.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &audioUploadResponse{Status: audioDuplicate, ID: existing.ID, S3Path: existing.S3Path, SHA256: existing.SHA256}
+	requeued, err := requeueUnpublished(ctx, existing, js)
+	if err != nil {
+		return nil, err
+	}
+	if requeued {
+		resp.Status = audioQueued
+	}
+	return resp, nil
+}
+
+func removeDuplicateObject(ctx context.Context, s3client *s3.MinioClient, object string) {
+	if err := s3client.RemoveObject(ctx, s3client.Bucket, object); err != nil {
+		ilog.L.Printf("remove duplicate object %s: %v", object, err)
+	}
+}
 
-	w.WriteHeader(http.StatusAccepted)
-	w.Write([]byte(`{"status":"queued"}`))
+func writeAudioUpload(w http.ResponseWriter, resp audioUploadResponse) {
+	status := http.StatusAccepted
+	if resp.Status == audioDuplicate {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
 }
 
-func insertAudioAndPublish(ctx context.Context, s3Path, filename string, s3client *s3.MinioClient, js natslib.JetStreamContext) error {
+// insertAudioAndPublish records an uploaded audio file and queues its
+// ingestion. Without JetStream the job stays queued. A file whose content
+// is already recorded is only queued again when its ingestion could not
+// be published, f becomes the recorded file.
+func insertAudioAndPublish(ctx context.Context, audios *audio.Service, f *audio.File, js natslib.JetStreamContext) (duplicate, requeued bool, err error) {
 	// insert audio_files
-	var id int64
-	sql := `INSERT INTO audio_files (s3_path_raw, filename, status, created_at) VALUES ($1, $2, 'uploaded', now()) RETURNING id`
-	err := db.Pool.QueryRow(ctx, sql, s3Path, filename).Scan(&id)
+	duplicate, err = audios.Create(ctx, f)
 	if err != nil {
-		return err
+		return false, false, err
+	}
+	if duplicate {
+		requeued, err = requeueUnpublished(ctx, f, js)
+		return true, requeued, err
 	}
+	return false, false, queueIngestion(ctx, f, js)
+}
+
+// requeueUnpublished queues the ingestion of a recorded file again when
+// its last ingestion job was never published, or it has none
+func requeueUnpublished(ctx context.Context, f *audio.File, js natslib.JetStreamContext) (bool, error) {
+	var status string
+	err := db.Pool.QueryRow(ctx, `
+		SELECT status FROM ingestion_jobs WHERE audio_file_id = $1 ORDER BY id DESC LIMIT 1
+	`, f.ID).Scan(&status)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+	if err == nil && status != ingestPublishFailed {
+		return false, nil
+	}
+	return true, queueIngestion(ctx, f, js)
+}
+
+// queueIngestion creates the ingestion job of a recorded file and
+// publishes it. A job that could not be published is marked so, the
+// error is returned for the upload to be retried.
+func queueIngestion(ctx context.Context, f *audio.File, js natslib.JetStreamContext) error {
 	// create ingestion job
-	ins := `INSERT INTO ingestion_jobs (audio_file_id, subject, status, created_at) VALUES ($1, $2, 'queued', now())`
-	if _, err := db.Pool.Exec(ctx, ins, id, "audio.ingest.raw"); err != nil {
+	var jobID int64
+	ins := `INSERT INTO ingestion_jobs (audio_file_id, subject, status, created_at) VALUES ($1, $2, 'queued', now()) RETURNING id`
+	if err := db.Pool.QueryRow(ctx, ins, f.ID, "audio.ingest.raw").Scan(&jobID); err != nil {
 		return err
 	}
+	if js == nil {
+		return nil
+	}
 
 	// publish event to JetStream
 	ev := nats.IngestEvent{
-		AudioID:  id,
-		S3Path:   s3Path,
-		Filename: filename,
+		AudioID:  f.ID,
+		S3Path:   f.S3Path,
+		Filename: f.Filename,
 	}
 	if err := nats.PublishIngestEvent(js, "audio.ingest.raw", ev); err != nil {
-		// Later not Now lol, update ingestion_jobs.status = 'publish_failed'
+		// The upload is answered with an error all the same
+		_, uerr := db.Pool.Exec(context.WithoutCancel(ctx), `
+			UPDATE ingestion_jobs SET status = $2, updated_at = now() WHERE id = $1
+		`, jobID, ingestPublishFailed)
+		if uerr != nil {
+			ilog.L.Printf("mark ingestion job %d: %v", jobID, uerr)
+		}
 		return err
 	}
 	return nil
@@ -0,0 +1,21 @@
+package handlers
+
+import "net/http"
+
+// deferredWriter sets the success headers on the first write only, so a
+// streaming handler can still answer with an error status until then
+type deferredWriter struct {
+	w       http.ResponseWriter
+	header  func()
+	started bool
+}
+
+func (d *deferredWriter) Write(p []byte) (int, error) {
+	if !d.started {
+		d.started = true
+		if d.header != nil {
+			d.header()
+		}
+	}
+	return d.w.Write(p)
+}
@@ -1,19 +1,34 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
+	"path/filepath"
+	"time"
 
 	"audioml/cmd/api/handlers"
+	"audioml/internal/artifacts"
+	"audioml/internal/audio"
 	"audioml/internal/config"
+	"audioml/internal/dataset"
 	"audioml/internal/db"
+	"audioml/internal/drift"
+	"audioml/internal/evaluation"
+	"audioml/internal/feedback"
+	"audioml/internal/inference"
+	"audioml/internal/lineage"
 	"audioml/internal/logger"
 	"audioml/internal/models"
+	"audioml/internal/nats"
+	"audioml/internal/s3"
 
 	"audioml/internal/trainer"
 	"audioml/internal/training"
+	"audioml/internal/uploads"
 
 	"github.com/gorilla/mux"
+	natslib "github.com/nats-io/nats.go"
 )
 
 func main() {
@@ -28,35 +43,190 @@ func main() {
 
 	r := mux.NewRouter()
 
+	// Lineage
+	lineageService := lineage.NewService(lineage.NewPostgresRepository(db.Pool))
+
+	lineageHandler := &handlers.LineageHandler{
+		Service: lineageService,
+	}
+	lineageHandler.Register(r)
+
 	// Dataset Upload
-	datasetHandler := &handlers.DatasetUploadHandler{}
+	datasetService := dataset.NewService(dataset.NewPostgresRepository(db.Pool), "datasets", dataset.Quota{
+		MaxFiles: cfg.DatasetMaxFiles,
+		MaxBytes: cfg.DatasetMaxBytes,
+	})
+
+	datasetHandler := &handlers.DatasetUploadHandler{
+		Lineage:           lineageService,
+		Datasets:          datasetService,
+		MaxFileBytes:      cfg.UploadMaxFileBytes,
+		MaxRequestBytes:   cfg.UploadMaxRequestBytes,
+		ArchiveMaxBytes:   cfg.ArchiveMaxBytes,
+		ArchiveMaxEntries: cfg.ArchiveMaxEntries,
+	}
 	datasetHandler.Register(r)
 
+	// Object storage
+	minioClient, err := s3.NewMinioClient(cfg)
+	if err != nil {
+		log.Fatalf("unable to create MinIO client: %v", err)
+	}
+
+	// Artifacts: readable from disk and MinIO, published where configured
+	artifactBucket := ""
+	if cfg.ArtifactStorage == "s3" {
+		if err := minioClient.MakeBucketIfNotExists(cfg.ArtifactBucket); err != nil {
+			log.Fatalf("unable to prepare artifact bucket: %v", err)
+		}
+		artifactBucket = cfg.ArtifactBucket
+	}
+	artifactStore := artifacts.NewStore(minioClient, cfg.ArtifactDir, artifactBucket, cfg.ArtifactPrefix)
+
 	// Models
 	modelRepo := models.NewPostgresRepository(db.Pool)
-	modelService := models.NewService(modelRepo)
+	modelService := models.NewService(modelRepo, artifactStore)
 
 	modelHandler := &handlers.ModelHandler{
-		Service: modelService,
+		Service:        modelService,
+		MaxImportBytes: cfg.ModelImportMaxBytes,
 	}
 	modelHandler.Register(r)
 
+	// Inference: long-lived Python workers shared by online and batch predictions
+	predictor := inference.NewPool(cfg.PredictorWorkers, func() inference.Predictor {
+		return inference.NewPythonWorker(cfg.PythonPath, cfg.PredictorScript)
+	})
+	defer predictor.Close()
+
+	inputsDir := ""
+	if cfg.KeepInputs {
+		inputsDir = filepath.Join(cfg.InferenceDir, "inputs")
+	}
+
+	audioStore := inference.NewAudioStore(minioClient, filepath.Join(cfg.InferenceDir, "tmp"))
+
+	artifactCache := inference.NewArtifactCache(artifactStore, filepath.Join(cfg.InferenceDir, "cache"))
+
+	inferenceService := inference.NewService(
+		modelService,
+		predictor,
+		artifactCache,
+		audioStore,
+		inference.NewPostgresRepository(db.Pool),
+		inputsDir,
+	)
+
+	go inferenceService.RunInputRetention(context.Background(), time.Hour, cfg.KeepInputsTTL, cfg.KeepInputsMaxBytes)
+
+	batchService := inference.NewBatchService(inferenceService, cfg.BatchWorkers)
+
+	inferenceHandler := &handlers.InferenceHandler{
+		Service: inferenceService,
+		Batch:   batchService,
+		TmpDir:  filepath.Join(cfg.InferenceDir, "tmp"),
+	}
+	inferenceHandler.Register(r)
+
+	// Feedback, corrected samples are exported next to the uploaded datasets
+	feedbackService := feedback.NewService(feedback.NewPostgresRepository(db.Pool), audioStore, "datasets")
+
+	feedbackHandler := &handlers.FeedbackHandler{
+		Service: feedbackService,
+	}
+	feedbackHandler.Register(r)
+
+	// Drift monitoring, alerts go to NATS when it is reachable
+	nc, err := nats.Connect(cfg.NatsURL)
+	if err != nil {
+		log.Printf("NATS unavailable, drift alerts are only logged: %v", err)
+	} else {
+		defer nc.Close()
+	}
+
+	driftService := drift.NewService(
+		drift.NewPostgresRepository(db.Pool),
+		modelService,
+		cfg.DriftThreshold,
+		cfg.DriftWindow,
+		nc,
+		cfg.DriftAlertSubject,
+	)
+	go driftService.Run(context.Background(), cfg.DriftInterval)
+
+	driftHandler := &handlers.DriftHandler{
+		Service: driftService,
+	}
+	driftHandler.Register(r)
+
+	// Raw audio and resumable uploads, the ingestion of raw audio is
+	// published when JetStream is available
+	var js natslib.JetStreamContext
+	if nc != nil {
+		if js, err = nc.JetStream(); err != nil {
+			log.Printf("JetStream unavailable, ingestion of uploaded audio is not published: %v", err)
+		}
+	}
+
+	audioService := audio.NewService(audio.NewPostgresRepository(db.Pool), cfg.AudioFingerprints, cfg.NearDuplicateThreshold)
+
+	uploadHandler := &handlers.UploadHandler{
+		S3Client:     minioClient,
+		JS:           js,
+		Audio:        audioService,
+		TmpDir:       filepath.Join(cfg.InferenceDir, "tmp"),
+		MaxFileBytes: cfg.UploadMaxFileBytes,
+	}
+	uploadHandler.Register(r)
+
+	uploadService := uploads.NewService(
+		uploads.NewPostgresRepository(db.Pool),
+		cfg.ResumableDir,
+		cfg.ResumableMaxBytes,
+		cfg.ResumableTTL,
+	)
+	go uploadService.Run(context.Background(), time.Hour)
+
+	resumableHandler := &handlers.ResumableUploadHandler{
+		Service:  uploadService,
+		Datasets: datasetHandler,
+		S3Client: minioClient,
+		Audio:    audioService,
+		JS:       js,
+	}
+	resumableHandler.Register(r)
+
 	// Trainer (Python)
 	trainerRunner := trainer.NewPythonRunner(
 		cfg.PythonPath,
 		cfg.TrainerScript,
-		"artifacts", // shared output directory
+		cfg.EvalScript,
+		cfg.ArtifactDir, // shared output directory
 	)
 
 	// Training (Job lifecycle only)
 	trainingRepo := training.NewPostgresRepo()
-	trainingService := training.NewService(trainingRepo, trainerRunner, modelService)
+	trainingService := training.NewService(trainingRepo, trainerRunner, modelService, artifactStore, lineageService, datasetService)
 
 	trainingHandler := &handlers.TrainingHandler{
 		TrainingService: trainingService,
 	}
 	trainingHandler.Register(r)
 
+	// Evaluation on held-out datasets, same runner as training
+	evaluationService := evaluation.NewService(
+		evaluation.NewPostgresRepo(),
+		trainerRunner,
+		modelService,
+		artifactCache,
+		lineageService,
+	)
+
+	evaluationHandler := &handlers.EvaluationHandler{
+		Service: evaluationService,
+	}
+	evaluationHandler.Register(r)
+
 	// Server
 	log.Println("API listening on", cfg.HTTPAddr)
 	if err := http.ListenAndServe(cfg.HTTPAddr, r); err != nil {
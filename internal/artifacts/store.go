@@ -0,0 +1,288 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"audioml/internal/s3"
+)
+
+var ErrNoObjectStorage = errors.New("artifact is in object storage but no client is configured")
+
+// partSize is the multipart chunk used when uploading artifacts
+const partSize = 16 << 20
+
+// Store reads model artifacts from local disk or from MinIO.
+// Local artifacts are plain paths, remote ones are s3://bucket/key URIs.
+// Both schemes are always readable, bucket only decides where Publish
+// puts new artifacts: empty keeps them on local disk.
+type Store struct {
+	s3       *s3.MinioClient
+	localDir string
+	bucket   string
+	prefix   string
+}
+
+// NewStore builds a store, client may be nil when only local paths are used.
+// localDir is where artifacts are produced before being published.
+func NewStore(client *s3.MinioClient, localDir, bucket, prefix string) *Store {
+	return &Store{
+		s3:       client,
+		localDir: localDir,
+		bucket:   bucket,
+		prefix:   strings.Trim(prefix, "/"),
+	}
+}
+
+// LocalDir returns the local staging directory for name
+func (s *Store) LocalDir(name string) string {
+	return filepath.Join(s.localDir, filepath.FromSlash(name))
+}
+
+// PublishedFile is one file of a published output directory
+type PublishedFile struct {
+	Rel    string
+	URI    string
+	SHA256 string
+	Size   int64
+}
+
+// Remote reports whether Publish uploads to object storage
+func (s *Store) Remote() bool {
+	return s.bucket != ""
+}
+
+// Publish makes every file below dir durable under the given name and
+// returns them keyed by their slash-separated path relative to dir.
+// In local mode the files stay where they are and are only hashed.
+func (s *Store) Publish(ctx context.Context, dir, name string) (map[string]PublishedFile, error) {
+	if s.Remote() && s.s3 == nil {
+		return nil, ErrNoObjectStorage
+	}
+
+	files := map[string]PublishedFile{}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("refusing to publish non-regular file %s", p)
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		f, err := s.publishFile(ctx, p, path.Join(name, rel))
+		if err != nil {
+			return fmt.Errorf("publish %s: %w", rel, err)
+		}
+		f.Rel = rel
+		files[rel] = f
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func (s *Store) publishFile(ctx context.Context, localPath, key string) (PublishedFile, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return PublishedFile{}, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return PublishedFile{}, err
+	}
+
+	h := sha256.New()
+
+	if !s.Remote() {
+		if _, err := io.Copy(h, f); err != nil {
+			return PublishedFile{}, err
+		}
+		return PublishedFile{
+			URI:    localPath,
+			SHA256: hex.EncodeToString(h.Sum(nil)),
+			Size:   info.Size(),
+		}, nil
+	}
+
+	objectName := key
+	if s.prefix != "" {
+		objectName = s.prefix + "/" + key
+	}
+
+	err = s.s3.UploadMultipart(
+		ctx,
+		s.bucket,
+		objectName,
+		io.TeeReader(f, h),
+		info.Size(),
+		partSize,
+		"application/octet-stream",
+	)
+	if err != nil {
+		return PublishedFile{}, err
+	}
+
+	return PublishedFile{
+		URI:    "s3://" + s.bucket + "/" + objectName,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+		Size:   info.Size(),
+	}, nil
+}
+
+// Object is an open artifact, seekable so it can serve range requests
+type Object struct {
+	io.ReadSeekCloser
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// ParseS3URI splits s3://bucket/key, ok is false for anything else
+func ParseS3URI(uri string) (bucket, key string, ok bool) {
+	rest, found := strings.CutPrefix(uri, "s3://")
+	if !found {
+		return "", "", false
+	}
+	bucket, key, found = strings.Cut(rest, "/")
+	if !found || bucket == "" || key == "" {
+		return "", "", false
+	}
+	return bucket, key, true
+}
+
+// Open opens the artifact stored at uri
+func (s *Store) Open(ctx context.Context, uri string) (*Object, error) {
+	if bucket, key, ok := ParseS3URI(uri); ok {
+		if s.s3 == nil {
+			return nil, ErrNoObjectStorage
+		}
+		obj, info, err := s.s3.OpenObject(ctx, bucket, key)
+		if err != nil {
+			return nil, err
+		}
+		return &Object{
+			ReadSeekCloser: obj,
+			Name:           path.Base(key),
+			Size:           info.Size,
+			ModTime:        info.LastModified,
+		}, nil
+	}
+
+	f, err := os.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, fmt.Errorf("artifact %s is a directory", uri)
+	}
+	return &Object{
+		ReadSeekCloser: f,
+		Name:           info.Name(),
+		Size:           info.Size(),
+		ModTime:        info.ModTime(),
+	}, nil
+}
+
+// Digest returns the hex SHA-256 and the size of the artifact at uri
+func (s *Store) Digest(ctx context.Context, uri string) (string, int64, error) {
+	obj, err := s.Open(ctx, uri)
+	if err != nil {
+		return "", 0, err
+	}
+	defer obj.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, obj)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// Remove deletes the artifact at uri, a missing artifact is not an error
+func (s *Store) Remove(ctx context.Context, uri string) error {
+	if bucket, key, ok := ParseS3URI(uri); ok {
+		if s.s3 == nil {
+			return ErrNoObjectStorage
+		}
+		return s.s3.RemoveObject(ctx, bucket, key)
+	}
+
+	if err := os.Remove(uri); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// WriteAlongside stores data as a sibling file of the artifact at uri
+// and returns the URI of the new file
+func (s *Store) WriteAlongside(ctx context.Context, uri, name string, data []byte) (string, error) {
+	if bucket, key, ok := ParseS3URI(uri); ok {
+		if s.s3 == nil {
+			return "", ErrNoObjectStorage
+		}
+		objectName := path.Join(path.Dir(key), name)
+		err := s.s3.UploadMultipart(
+			ctx,
+			bucket,
+			objectName,
+			bytes.NewReader(data),
+			int64(len(data)),
+			partSize,
+			contentTypeFor(name),
+		)
+		if err != nil {
+			return "", err
+		}
+		return "s3://" + bucket + "/" + objectName, nil
+	}
+
+	p := filepath.Join(filepath.Dir(uri), name)
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+func contentTypeFor(name string) string {
+	switch path.Ext(name) {
+	case ".json":
+		return "application/json"
+	case ".md":
+		return "text/markdown; charset=utf-8"
+	default:
+		return "application/octet-stream"
+	}
+}
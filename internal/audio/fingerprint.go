@@ -0,0 +1,146 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"audioml/internal/dataset"
+)
+
+// ErrNoFingerprint is returned for files that cannot be fingerprinted:
+// anything but WAV, or shorter than a second
+var ErrNoFingerprint = errors.New("audio cannot be fingerprinted")
+
+// fingerprintFrameSeconds is the time resolution of a fingerprint
+const fingerprintFrameSeconds = 0.1
+
+// minFingerprintFrames keeps very short clips, which match anything,
+// out of near-duplicate detection
+const minFingerprintFrames = 10
+
+// maxFingerprintShift is how many frames a copy may be offset by, for
+// encoders that add or drop a little padding
+const maxFingerprintShift = 3
+
+// Fingerprint is a coarse signature of the loudness of a recording over
+// time: bit i is set when frame i+1 is louder than frame i. It does not
+// change with the sample rate, bit depth, channels or volume of a copy,
+// and little with lossy re-encoding; cut or stretched copies are not
+// recognized.
+type Fingerprint struct {
+	Bits   []byte
+	Frames int
+}
+
+func (fp *Fingerprint) bit(i int) bool {
+	return fp.Bits[i/8]&(1<<(i%8)) != 0
+}
+
+// Similarity is the share of matching bits of two fingerprints, at the
+// best alignment within a few frames
+func (fp *Fingerprint) Similarity(other *Fingerprint) float64 {
+	shorter := min(fp.Frames, other.Frames)
+	best := 0.0
+	for shift := -maxFingerprintShift; shift <= maxFingerprintShift; shift++ {
+		matches, compared := 0, 0
+		for i := max(0, -shift); i < fp.Frames && i+shift < other.Frames; i++ {
+			if fp.bit(i) == other.bit(i+shift) {
+				matches++
+			}
+			compared++
+		}
+		// Require most of the shorter recording to overlap
+		if compared == 0 || compared < shorter-maxFingerprintShift {
+			continue
+		}
+		best = max(best, float64(matches)/float64(compared))
+	}
+	return best
+}
+
+// ComputeFingerprint decodes a PCM or float WAV file into its fingerprint
+func ComputeFingerprint(path string) (*Fingerprint, error) {
+	rc, info, err := dataset.OpenWAV(path)
+	if err != nil {
+		var verr *dataset.ValidationError
+		if errors.As(err, &verr) && verr.Code == dataset.CodeUnsupportedFormat {
+			return nil, fmt.Errorf("%w: not a WAV file", ErrNoFingerprint)
+		}
+		return nil, err
+	}
+	defer rc.Close()
+
+	decode, err := sampleDecoder(info)
+	if err != nil {
+		return nil, err
+	}
+	width := info.BitDepth / 8
+	frameLen := max(1, int(float64(info.SampleRate)*fingerprintFrameSeconds))
+
+	// Log energy of the mono mix of every full frame
+	var energies []float64
+	r := bufio.NewReaderSize(rc, 64<<10)
+	sample := make([]byte, width*info.Channels)
+	var sum float64
+	n := 0
+	for {
+		if _, err := io.ReadFull(r, sample); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return nil, err
+		}
+		var mono float64
+		for c := 0; c < info.Channels; c++ {
+			mono += decode(sample[c*width : (c+1)*width])
+		}
+		mono /= float64(info.Channels)
+		sum += mono * mono
+
+		n++
+		if n == frameLen {
+			energies = append(energies, math.Log(1e-10+sum/float64(n)))
+			sum, n = 0, 0
+		}
+	}
+
+	if len(energies)-1 < minFingerprintFrames {
+		return nil, fmt.Errorf("%w: shorter than a second", ErrNoFingerprint)
+	}
+
+	fp := &Fingerprint{Frames: len(energies) - 1}
+	fp.Bits = make([]byte, (fp.Frames+7)/8)
+	for i := 0; i < fp.Frames; i++ {
+		if energies[i+1] > energies[i] {
+			fp.Bits[i/8] |= 1 << (i % 8)
+		}
+	}
+	return fp, nil
+}
+
+// sampleDecoder returns a function decoding one little endian sample of
+// a WAV file to [-1, 1]
+func sampleDecoder(info *dataset.AudioInfo) (func([]byte) float64, error) {
+	switch {
+	case info.Encoding == "pcm" && info.BitDepth == 8:
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }, nil
+	case info.Encoding == "pcm" && info.BitDepth == 16:
+		return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }, nil
+	case info.Encoding == "pcm" && info.BitDepth == 24:
+		return func(b []byte) float64 {
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			return float64(v) / (1 << 23)
+		}, nil
+	case info.Encoding == "pcm" && info.BitDepth == 32:
+		return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }, nil
+	case info.Encoding == "float" && info.BitDepth == 32:
+		return func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }, nil
+	case info.Encoding == "float" && info.BitDepth == 64:
+		return func(b []byte) float64 { return math.Float64frombits(binary.LittleEndian.Uint64(b)) }, nil
+	}
+	return nil, fmt.Errorf("%w: %d bit %s samples", ErrNoFingerprint, info.BitDepth, info.Encoding)
+}
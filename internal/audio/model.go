@@ -0,0 +1,28 @@
+package audio
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrNotFound = errors.New("audio file not found")
+
+// File is a raw recording uploaded through /upload, a row of audio_files
+type File struct {
+	ID        int64  `json:"id"`
+	S3Path    string `json:"s3_path"`
+	Filename  string `json:"filename,omitempty"`
+	SHA256    string `json:"sha256,omitempty"`
+	SizeBytes int64  `json:"size_bytes,omitempty"`
+	// Fingerprint is only computed when near-duplicate detection is on
+	Fingerprint *Fingerprint `json:"-"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// NearDuplicate is a known file that sounds like a new one without being
+// byte for byte identical, typically a re-encoded copy
+type NearDuplicate struct {
+	ID         int64   `json:"id"`
+	Filename   string  `json:"filename,omitempty"`
+	Similarity float64 `json:"similarity"`
+}
@@ -0,0 +1,91 @@
+package audio
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) PostgresRepository {
+	return PostgresRepository{db: db}
+}
+
+// Create inserts an uploaded file unless a file with the same SHA-256 is
+// already recorded. It reports whether a row was inserted; f.ID is set
+// either way.
+func (r PostgresRepository) Create(ctx context.Context, f *File) (bool, error) {
+	var bits []byte
+	var frames *int
+	if f.Fingerprint != nil {
+		bits, frames = f.Fingerprint.Bits, &f.Fingerprint.Frames
+	}
+
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO audio_files (s3_path_raw, filename, sha256, size_bytes, fingerprint, fingerprint_frames, status, created_at)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, 0), $5, $6, 'uploaded', now())
+		ON CONFLICT (sha256) WHERE sha256 IS NOT NULL DO NOTHING
+		RETURNING id, created_at
+	`, f.S3Path, f.Filename, f.SHA256, f.SizeBytes, bits, frames).Scan(&f.ID, &f.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		existing, err := r.FindBySHA256(ctx, f.SHA256)
+		if err != nil {
+			return false, err
+		}
+		*f = *existing
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r PostgresRepository) FindBySHA256(ctx context.Context, sum string) (*File, error) {
+	var f File
+	err := r.db.QueryRow(ctx, `
+		SELECT id, s3_path_raw, COALESCE(filename, ''), sha256, COALESCE(size_bytes, 0), created_at
+		FROM audio_files WHERE sha256 = $1
+	`, sum).Scan(&f.ID, &f.S3Path, &f.Filename, &f.SHA256, &f.SizeBytes, &f.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// WithFingerprints lists the fingerprinted files whose fingerprint length
+// is between minFrames and maxFrames, newest first
+func (r PostgresRepository) WithFingerprints(ctx context.Context, minFrames, maxFrames, limit int) ([]File, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, s3_path_raw, COALESCE(filename, ''), COALESCE(sha256, ''), COALESCE(size_bytes, 0),
+		       fingerprint, fingerprint_frames, created_at
+		FROM audio_files
+		WHERE fingerprint IS NOT NULL AND fingerprint_frames BETWEEN $1 AND $2
+		ORDER BY id DESC
+		LIMIT $3
+	`, minFrames, maxFrames, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []File
+	for rows.Next() {
+		var f File
+		fp := &Fingerprint{}
+		if err := rows.Scan(&f.ID, &f.S3Path, &f.Filename, &f.SHA256, &f.SizeBytes, &fp.Bits, &fp.Frames, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		f.Fingerprint = fp
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
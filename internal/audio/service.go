@@ -0,0 +1,87 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	ilog "audioml/internal/logger"
+)
+
+// maxNearDuplicateCandidates bounds the fingerprints compared with a new
+// file, the most recent files of a similar length being kept
+const maxNearDuplicateCandidates = 5000
+
+// Service records raw audio uploads once per content. Near-duplicate
+// detection is optional: when on, WAV uploads are fingerprinted and
+// compared with the files of a similar length.
+type Service struct {
+	repo         PostgresRepository
+	fingerprints bool
+	threshold    float64
+}
+
+// NewService reports files whose fingerprints are at least threshold
+// similar as near duplicates when fingerprints is set
+func NewService(repo PostgresRepository, fingerprints bool, threshold float64) *Service {
+	return &Service{repo: repo, fingerprints: fingerprints, threshold: threshold}
+}
+
+// Fingerprints reports whether near-duplicate detection is on, uploads
+// then need a local copy of the file
+func (s *Service) Fingerprints() bool {
+	return s != nil && s.fingerprints
+}
+
+// Lookup finds a recorded file by its SHA-256
+func (s *Service) Lookup(ctx context.Context, sum string) (*File, error) {
+	return s.repo.FindBySHA256(ctx, sum)
+}
+
+// Create records a new file. When a file with the same content already
+// exists nothing is recorded, f becomes that file and duplicate is true.
+func (s *Service) Create(ctx context.Context, f *File) (duplicate bool, err error) {
+	created, err := s.repo.Create(ctx, f)
+	return !created, err
+}
+
+// NearDuplicates fingerprints the local copy of a file about to be
+// recorded, keeping the fingerprint in f, and lists the known files that
+// sound the same, most similar first. Files that cannot be fingerprinted
+// are reported with ErrNoFingerprint.
+func (s *Service) NearDuplicates(ctx context.Context, f *File, path string) ([]NearDuplicate, error) {
+	if !s.Fingerprints() {
+		return nil, nil
+	}
+
+	fp, err := ComputeFingerprint(path)
+	if errors.Is(err, ErrNoFingerprint) {
+		return nil, err
+	}
+	if err != nil {
+		// A file that fails to decode is still uploaded, the ingestion
+		// worker reports it
+		ilog.L.Printf("fingerprint %s: %v", f.Filename, err)
+		return nil, nil
+	}
+	f.Fingerprint = fp
+
+	// Copies have about the same duration
+	slack := max(maxFingerprintShift, fp.Frames/20)
+	candidates, err := s.repo.WithFingerprints(ctx, fp.Frames-slack, fp.Frames+slack, maxNearDuplicateCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	var dups []NearDuplicate
+	for _, c := range candidates {
+		if c.SHA256 != "" && c.SHA256 == f.SHA256 {
+			continue
+		}
+		if sim := fp.Similarity(c.Fingerprint); sim >= s.threshold {
+			dups = append(dups, NearDuplicate{ID: c.ID, Filename: c.Filename, Similarity: sim})
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool { return dups[i].Similarity > dups[j].Similarity })
+	return dups, nil
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -17,6 +19,62 @@ type Config struct {
 	MinioBucket    string
 	PythonPath     string
 	TrainerScript  string
+	EvalScript     string
+
+	// ArtifactStorage is "local" or "s3". With "s3" every training output
+	// directory is uploaded to ArtifactBucket under ArtifactPrefix.
+	ArtifactStorage string
+	ArtifactDir     string
+	ArtifactBucket  string
+	ArtifactPrefix  string
+
+	PredictorScript string
+	InferenceDir    string
+	// KeepInputs keeps audio sent to the predict endpoint for feedback,
+	// for at most KeepInputsTTL and KeepInputsMaxBytes in total
+	KeepInputs         bool
+	KeepInputsTTL      time.Duration
+	KeepInputsMaxBytes int64
+	// PredictorWorkers is the number of predictor processes, BatchWorkers
+	// the number of predictions a batch job keeps in flight
+	PredictorWorkers int
+	BatchWorkers     int
+
+	// Drift reports compare the last DriftWindow of predictions with the
+	// training data every DriftInterval. A PSI above DriftThreshold alerts.
+	DriftInterval     time.Duration
+	DriftWindow       time.Duration
+	DriftThreshold    float64
+	DriftAlertSubject string
+
+	// Default quota of a dataset, zero for no limit. Dataset rules can
+	// set their own.
+	DatasetMaxFiles int
+	DatasetMaxBytes int64
+	// UploadMaxFileBytes bounds every file of an upload and
+	// UploadMaxRequestBytes a whole dataset upload, 0 for no limit
+	UploadMaxFileBytes    int64
+	UploadMaxRequestBytes int64
+	// Archives uploaded to datasets are bounded by the size of the archive
+	// and of its extracted files, and by their number
+	ArchiveMaxBytes   int64
+	ArchiveMaxEntries int
+	// ModelImportMaxBytes bounds an imported model bundle and its
+	// extracted artifact, 0 for no limit
+	ModelImportMaxBytes int64
+
+	// Resumable uploads are kept in ResumableDir, which must be on the
+	// same file system as the datasets, until finalized. Unfinished ones
+	// are dropped after ResumableTTL without data.
+	ResumableDir      string
+	ResumableMaxBytes int64
+	ResumableTTL      time.Duration
+
+	// Raw audio uploads are deduplicated by SHA-256. With AudioFingerprints
+	// WAV uploads are also compared with known files, those at least
+	// NearDuplicateThreshold similar being reported.
+	AudioFingerprints      bool
+	NearDuplicateThreshold float64
 }
 
 func Load() *Config {
@@ -32,12 +90,98 @@ func Load() *Config {
 		MinioBucket:    getEnv("MINIO_BUCKET", "audio-raw"),
 		PythonPath:     getEnv("PYTHON_PATH", "python"),
 		TrainerScript:  getEnv("TRAINER_SCRIPT", "./trainer/trainer.py"),
+		EvalScript:     getEnv("EVALUATOR_SCRIPT", "./trainer/evaluator.py"),
+
+		ArtifactStorage: getEnv("ARTIFACT_STORAGE", "local"),
+		ArtifactDir:     getEnv("ARTIFACT_DIR", "artifacts"),
+		ArtifactBucket:  getEnv("ARTIFACT_BUCKET", "audio-artifacts"),
+		ArtifactPrefix:  getEnv("ARTIFACT_PREFIX", "models"),
+
+		PredictorScript: getEnv("PREDICTOR_SCRIPT", "./trainer/predictor.py"),
+		InferenceDir:    getEnv("INFERENCE_DIR", "inference"),
+		KeepInputs:      getEnvBool("INFERENCE_KEEP_INPUTS", true),
+
+		KeepInputsTTL:      getEnvDuration("INFERENCE_INPUTS_TTL", 30*24*time.Hour),
+		KeepInputsMaxBytes: int64(getEnvInt("INFERENCE_INPUTS_MAX_BYTES", 10<<30)),
+
+		PredictorWorkers: getEnvInt("PREDICTOR_WORKERS", 2),
+		BatchWorkers:     getEnvInt("BATCH_WORKERS", 2),
+
+		DriftInterval:     getEnvDuration("DRIFT_INTERVAL", time.Hour),
+		DriftWindow:       getEnvDuration("DRIFT_WINDOW", 24*time.Hour),
+		DriftThreshold:    getEnvFloat("DRIFT_THRESHOLD", 0.2),
+		DriftAlertSubject: getEnv("DRIFT_ALERT_SUBJECT", "ml.drift.alert"),
+
+		DatasetMaxFiles: getEnvInt("DATASET_MAX_FILES", 100000),
+		DatasetMaxBytes: int64(getEnvInt("DATASET_MAX_BYTES", 20<<30)),
+
+		UploadMaxFileBytes:    int64(getEnvInt("UPLOAD_MAX_FILE_BYTES", 1<<30)),
+		UploadMaxRequestBytes: int64(getEnvInt("UPLOAD_MAX_REQUEST_BYTES", 20<<30)),
+		ArchiveMaxBytes:       int64(getEnvInt("ARCHIVE_MAX_BYTES", 10<<30)),
+		ArchiveMaxEntries:     getEnvInt("ARCHIVE_MAX_ENTRIES", 100000),
+
+		ModelImportMaxBytes: int64(getEnvInt("MODEL_IMPORT_MAX_BYTES", 10<<30)),
+
+		ResumableDir:      getEnv("RESUMABLE_UPLOAD_DIR", "datasets/.uploads/resumable"),
+		ResumableMaxBytes: int64(getEnvInt("RESUMABLE_UPLOAD_MAX_BYTES", 50<<30)),
+		ResumableTTL:      getEnvDuration("RESUMABLE_UPLOAD_TTL", 24*time.Hour),
+
+		AudioFingerprints:      getEnvBool("AUDIO_FINGERPRINTS", false),
+		NearDuplicateThreshold: getEnvFloat("NEAR_DUPLICATE_THRESHOLD", 0.9),
 	}
 
 	log.Printf("Config loaded (env=%s)", cfg.Env)
 	return cfg
 }
 
+func getEnvInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("%s must be an integer: %v", key, err)
+	}
+	return n
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Fatalf("%s must be true or false: %v", key, err)
+	}
+	return b
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Fatalf("%s must be a number: %v", key, err)
+	}
+	return f
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.Fatalf("%s must be a positive duration such as 30m: %v", key, err)
+	}
+	return d
+}
+
 func getEnv(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
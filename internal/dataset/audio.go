@@ -0,0 +1,417 @@
+package dataset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+type AudioFormat string
+
+const (
+	FormatWAV  AudioFormat = "wav"
+	FormatFLAC AudioFormat = "flac"
+	FormatMP3  AudioFormat = "mp3"
+)
+
+// AudioInfo is what the container headers say about an audio file.
+// BitDepth is 0 for lossy formats.
+type AudioInfo struct {
+	Format          AudioFormat `json:"format"`
+	Encoding        string      `json:"encoding"`
+	SampleRate      int         `json:"sample_rate"`
+	Channels        int         `json:"channels"`
+	BitDepth        int         `json:"bit_depth,omitempty"`
+	DurationSeconds float64     `json:"duration_seconds"`
+}
+
+// errCorrupt marks header problems, Probe turns it into a ValidationError
+var errCorrupt = errors.New("corrupt")
+
+func corrupt(format string, args ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{errCorrupt}, args...)...)
+}
+
+// Probe reads the headers of a WAV, FLAC or MP3 file. The format is
+// detected from the content, not the file name. Errors are *ValidationError.
+func Probe(path string) (*AudioInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, &ValidationError{Code: CodeUnreadable, Message: err.Error()}
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, &ValidationError{Code: CodeUnreadable, Message: err.Error()}
+	}
+
+	info, err := probe(f, st.Size())
+	if err != nil {
+		var verr *ValidationError
+		switch {
+		case errors.As(err, &verr):
+			return nil, verr
+		case errors.Is(err, errCorrupt), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+			return nil, &ValidationError{Code: CodeCorrupt, Message: err.Error()}
+		default:
+			return nil, &ValidationError{Code: CodeUnreadable, Message: err.Error()}
+		}
+	}
+	return info, nil
+}
+
+func probe(r io.ReadSeeker, size int64) (*AudioInfo, error) {
+	head := make([]byte, 12)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, &ValidationError{Code: CodeUnsupportedFormat, Message: "file is too small to be audio"}
+	}
+	head = head[:n]
+
+	// An ID3v2 tag may precede FLAC and MP3 streams
+	var offset int64
+	if bytes.HasPrefix(head, []byte("ID3")) && len(head) >= 10 {
+		offset = 10 + int64(syncsafe(head[6:10]))
+		if head[5]&0x10 != 0 {
+			offset += 10 // footer
+		}
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		head = make([]byte, 4)
+		if _, err := io.ReadFull(r, head); err != nil {
+			return nil, corrupt("ID3 tag runs past the end of the file")
+		}
+	}
+
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[0:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")):
+		return probeWAV(r, size)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return probeFLAC(r, offset+4)
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return probeMP3(r, offset, size)
+	}
+
+	return nil, &ValidationError{Code: CodeUnsupportedFormat, Message: "not a WAV, FLAC or MP3 file"}
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+// WAV
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// maxWAVFmtChunk bounds the fmt chunk, 40 bytes for WAVE_FORMAT_EXTENSIBLE.
+// Its size comes from the file and must not decide what is allocated.
+const maxWAVFmtChunk = 64
+
+// wavFmtBytes is how much of the fmt chunk is read, up to the sub format
+// tag of WAVE_FORMAT_EXTENSIBLE
+const wavFmtBytes = 26
+
+func probeWAV(r io.ReadSeeker, size int64) (*AudioInfo, error) {
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var (
+		info      = &AudioInfo{Format: FormatWAV}
+		byteRate  uint32
+		haveFmt   bool
+		pos       int64 = 12
+		chunkHead       = make([]byte, 8)
+	)
+
+	for {
+		if _, err := io.ReadFull(r, chunkHead); err != nil {
+			return nil, corrupt("WAV has no data chunk")
+		}
+		id := string(chunkHead[0:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(chunkHead[4:8]))
+		pos += 8
+
+		switch id {
+		case "fmt ":
+			if chunkSize < 16 {
+				return nil, corrupt("WAV fmt chunk is too short")
+			}
+			if chunkSize > maxWAVFmtChunk || pos+chunkSize > size {
+				return nil, corrupt("WAV fmt chunk has an invalid size")
+			}
+			// The rest of the chunk is skipped below
+			fmtChunk := make([]byte, min(chunkSize, wavFmtBytes))
+			if _, err := io.ReadFull(r, fmtChunk); err != nil {
+				return nil, err
+			}
+
+			tag := binary.LittleEndian.Uint16(fmtChunk[0:2])
+			if tag == wavFormatExtensible && len(fmtChunk) >= 26 {
+				// The sub format GUID starts with the real format tag
+				tag = binary.LittleEndian.Uint16(fmtChunk[24:26])
+			}
+			switch tag {
+			case wavFormatPCM:
+				info.Encoding = "pcm"
+			case wavFormatFloat:
+				info.Encoding = "float"
+			default:
+				return nil, &ValidationError{
+					Code:    CodeUnsupportedFormat,
+					Message: fmt.Sprintf("WAV encoding 0x%04x is not PCM or float", tag),
+				}
+			}
+
+			info.Channels = int(binary.LittleEndian.Uint16(fmtChunk[2:4]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(fmtChunk[4:8]))
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:12])
+			info.BitDepth = int(binary.LittleEndian.Uint16(fmtChunk[14:16]))
+			if info.Channels == 0 || info.SampleRate == 0 || byteRate == 0 || info.BitDepth == 0 {
+				return nil, corrupt("WAV fmt chunk has zero channels, rate or bit depth")
+			}
+			haveFmt = true
+
+		case "data":
+			if !haveFmt {
+				return nil, corrupt("WAV data chunk before fmt chunk")
+			}
+			if pos+chunkSize > size {
+				return nil, corrupt("WAV data chunk is truncated")
+			}
+			info.DurationSeconds = float64(chunkSize) / float64(byteRate)
+			return info, nil
+
+		default:
+			if _, err := r.Seek(chunkSize, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+
+		// Chunks are padded to an even size
+		pos += chunkSize + chunkSize%2
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// OpenWAV opens a PCM or float WAV file at the start of its samples.
+// Decoding FLAC and MP3 is left to the trainer. Errors are *ValidationError.
+func OpenWAV(path string) (io.ReadCloser, *AudioInfo, error) {
+	info, err := Probe(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Format != FormatWAV {
+		return nil, nil, &ValidationError{Code: CodeUnsupportedFormat, Message: "only WAV files can be decoded", Info: info}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, &ValidationError{Code: CodeUnreadable, Message: err.Error()}
+	}
+	st, err := f.Stat()
+	if err == nil {
+		// probe leaves the file at the data chunk
+		_, err = probe(f, st.Size())
+	}
+	if err != nil {
+		f.Close()
+		return nil, nil, &ValidationError{Code: CodeUnreadable, Message: err.Error()}
+	}
+
+	frameBytes := int64(info.Channels * info.BitDepth / 8)
+	n := int64(math.Round(info.DurationSeconds*float64(info.SampleRate))) * frameBytes
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, n), f}, info, nil
+}
+
+// FLAC
+
+func probeFLAC(r io.ReadSeeker, afterMagic int64) (*AudioInfo, error) {
+	if _, err := r.Seek(afterMagic, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	blockHead := make([]byte, 4)
+	if _, err := io.ReadFull(r, blockHead); err != nil {
+		return nil, err
+	}
+	if blockHead[0]&0x7F != 0 {
+		return nil, corrupt("FLAC does not start with STREAMINFO")
+	}
+	length := int(blockHead[1])<<16 | int(blockHead[2])<<8 | int(blockHead[3])
+	if length < 34 {
+		return nil, corrupt("FLAC STREAMINFO is too short")
+	}
+
+	si := make([]byte, 34)
+	if _, err := io.ReadFull(r, si); err != nil {
+		return nil, err
+	}
+
+	// 20 bits sample rate, 3 bits channels-1, 5 bits bps-1, 36 bits samples
+	packed := binary.BigEndian.Uint64(si[10:18])
+	sampleRate := int(packed >> 44)
+	channels := int(packed>>41&0x7) + 1
+	bitDepth := int(packed>>36&0x1F) + 1
+	totalSamples := packed & 0xFFFFFFFFF
+
+	if sampleRate == 0 {
+		return nil, corrupt("FLAC sample rate is zero")
+	}
+
+	info := &AudioInfo{
+		Format:     FormatFLAC,
+		Encoding:   "flac",
+		SampleRate: sampleRate,
+		Channels:   channels,
+		BitDepth:   bitDepth,
+	}
+	// Zero means unknown to the encoder
+	if totalSamples > 0 {
+		info.DurationSeconds = float64(totalSamples) / float64(sampleRate)
+	}
+	return info, nil
+}
+
+// MP3
+
+var (
+	mp3Bitrates = map[bool][16]int{
+		true:  {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, -1},
+		false: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, -1},
+	}
+	mp3SampleRates = map[int][3]int{
+		3: {44100, 48000, 32000}, // MPEG 1
+		2: {22050, 24000, 16000}, // MPEG 2
+		0: {11025, 12000, 8000},  // MPEG 2.5
+	}
+)
+
+type mp3Frame struct {
+	mpeg1      bool
+	bitrate    int // kbit/s
+	sampleRate int
+	channels   int
+	length     int
+	samples    int
+}
+
+func parseMP3Header(h []byte) (*mp3Frame, error) {
+	if h[0] != 0xFF || h[1]&0xE0 != 0xE0 {
+		return nil, corrupt("MP3 frame sync not found")
+	}
+	version := int(h[1] >> 3 & 0x3)
+	layer := h[1] >> 1 & 0x3
+	if version == 1 {
+		return nil, corrupt("reserved MPEG version")
+	}
+	if layer != 1 {
+		return nil, &ValidationError{Code: CodeUnsupportedFormat, Message: "MPEG audio is not layer III"}
+	}
+
+	fr := &mp3Frame{mpeg1: version == 3}
+	fr.bitrate = mp3Bitrates[fr.mpeg1][h[2]>>4]
+	rateIdx := int(h[2] >> 2 & 0x3)
+	if fr.bitrate <= 0 || rateIdx == 3 {
+		return nil, corrupt("MP3 frame has an invalid bitrate or sample rate")
+	}
+	fr.sampleRate = mp3SampleRates[version][rateIdx]
+	padding := int(h[2] >> 1 & 0x1)
+
+	fr.channels = 2
+	if h[3]>>6 == 3 {
+		fr.channels = 1
+	}
+
+	if fr.mpeg1 {
+		fr.samples = 1152
+		fr.length = 144*fr.bitrate*1000/fr.sampleRate + padding
+	} else {
+		fr.samples = 576
+		fr.length = 72*fr.bitrate*1000/fr.sampleRate + padding
+	}
+	return fr, nil
+}
+
+func probeMP3(r io.ReadSeeker, offset, size int64) (*AudioInfo, error) {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// Enough for the first header and a Xing/Info header
+	buf := make([]byte, 4+32+12)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	if n < 4 {
+		return nil, corrupt("MP3 frame header is truncated")
+	}
+	buf = buf[:n]
+
+	fr, err := parseMP3Header(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &AudioInfo{
+		Format:     FormatMP3,
+		Encoding:   "mp3",
+		SampleRate: fr.sampleRate,
+		Channels:   fr.channels,
+	}
+
+	// The next frame must follow, a lone sync word is not an MP3 stream
+	next := offset + int64(fr.length)
+	if next+4 <= size {
+		if _, err := r.Seek(next, io.SeekStart); err != nil {
+			return nil, err
+		}
+		h := make([]byte, 4)
+		if _, err := io.ReadFull(r, h); err != nil {
+			return nil, err
+		}
+		if _, err := parseMP3Header(h); err != nil {
+			return nil, corrupt("MP3 second frame not found")
+		}
+	}
+
+	// VBR files announce their frame count in a Xing or Info header
+	sideInfo := 32
+	switch {
+	case fr.mpeg1 && fr.channels == 1:
+		sideInfo = 17
+	case !fr.mpeg1 && fr.channels == 2:
+		sideInfo = 17
+	case !fr.mpeg1:
+		sideInfo = 9
+	}
+	if x := 4 + sideInfo; len(buf) >= x+12 {
+		tag := string(buf[x : x+4])
+		flags := binary.BigEndian.Uint32(buf[x+4 : x+8])
+		if (tag == "Xing" || tag == "Info") && flags&0x1 != 0 {
+			frames := binary.BigEndian.Uint32(buf[x+8 : x+12])
+			info.DurationSeconds = float64(frames) * float64(fr.samples) / float64(fr.sampleRate)
+			return info, nil
+		}
+	}
+
+	// Otherwise assume a constant bitrate
+	info.DurationSeconds = float64(size-offset) * 8 / float64(fr.bitrate*1000)
+	return info, nil
+}
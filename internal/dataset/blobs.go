@@ -0,0 +1,88 @@
+package dataset
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// BlobStore keeps dataset files by content hash. Blobs are never modified,
+// version snapshots are hard links to them. Blobs are read-only, and so is
+// the live dataset file a blob was linked from.
+type BlobStore struct {
+	Root string
+}
+
+func (b BlobStore) Path(sum string) string {
+	if len(sum) < 2 {
+		return filepath.Join(b.Root, "sha256", sum)
+	}
+	return filepath.Join(b.Root, "sha256", sum[:2], sum)
+}
+
+func (b BlobStore) Has(sum string) bool {
+	_, err := os.Stat(b.Path(sum))
+	return err == nil
+}
+
+// Put stores the file at src under sum, which the caller already computed
+func (b BlobStore) Put(src, sum string) error {
+	dst := b.Path(sum)
+	if b.Has(sum) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tmp-")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	if err := linkOrCopy(src, tmp.Name()); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0444); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// linkOrCopy hard links src to dst, replacing dst, and copies when the
+// two are on different file systems
+func linkOrCopy(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return CopyFile(src, dst)
+}
+
+// CopyFile copies src to dst, replacing dst. A partial copy is removed.
+func CopyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
@@ -0,0 +1,44 @@
+package dataset
+
+// Histogram summarizes one audio feature over a dataset. Edges has one
+// more element than Proportions, the outer bins are open ended.
+type Histogram struct {
+	Count       int       `json:"count"`
+	Mean        float64   `json:"mean"`
+	Std         float64   `json:"std"`
+	Edges       []float64 `json:"edges"`
+	Proportions []float64 `json:"proportions"`
+}
+
+// FeatureStats maps feature names such as duration_seconds or sample_rate
+// to their histogram
+type FeatureStats map[string]Histogram
+
+// Bin returns the share of values falling in each bin of h
+func (h Histogram) Bin(values []float64) []float64 {
+	if len(h.Proportions) == 0 {
+		return nil
+	}
+
+	shares := make([]float64, len(h.Proportions))
+	if len(values) == 0 {
+		return shares
+	}
+	for _, v := range values {
+		shares[h.bin(v)]++
+	}
+	for i := range shares {
+		shares[i] /= float64(len(values))
+	}
+	return shares
+}
+
+func (h Histogram) bin(v float64) int {
+	last := len(h.Proportions) - 1
+	for i := 1; i <= last && i < len(h.Edges); i++ {
+		if v < h.Edges[i] {
+			return i - 1
+		}
+	}
+	return last
+}
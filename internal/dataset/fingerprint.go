@@ -0,0 +1,46 @@
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Fingerprint is a SHA-256 over the relative paths and contents of the
+// files of a dataset directory. Equal fingerprints mean the same files.
+func Fingerprint(dir string) (string, error) {
+	h := sha256.New()
+
+	err := Walk(dir, func(rel string, _ fs.FileInfo) error {
+		sum, err := FileSHA256(filepath.Join(dir, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\t%s\n", rel, sum)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// FileSHA256 is the hex encoded SHA-256 of the content of a file
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,193 @@
+package dataset
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound        = errors.New("dataset not found")
+	ErrInvalidManifest = errors.New("invalid manifest")
+	ErrInvalidLabel    = errors.New("invalid label")
+)
+
+// Split assigns a file to a part of the dataset, empty when unassigned
+type Split string
+
+const (
+	SplitTrain      Split = "train"
+	SplitValidation Split = "validation"
+	SplitTest       Split = "test"
+)
+
+func (s Split) Valid() bool {
+	return s == "" || s == SplitTrain || s == SplitValidation || s == SplitTest
+}
+
+// File is one audio file of a dataset manifest
+type File struct {
+	// Path is slash separated and relative to the dataset directory, the
+	// class folder of a labeled file included
+	Path      string         `json:"path"`
+	Label     string         `json:"label"`
+	Split     Split          `json:"split,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	SHA256    string         `json:"sha256"`
+	SizeBytes int64          `json:"size_bytes"`
+	Audio     *AudioInfo     `json:"audio,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// Dataset is the manifest of a dataset as recorded by uploads
+type Dataset struct {
+	Name                 string                  `json:"name"`
+	Source               string                  `json:"source"`
+	Files                int                     `json:"files"`
+	TotalBytes           int64                   `json:"total_bytes"`
+	TotalDurationSeconds float64                 `json:"total_duration_seconds"`
+	Classes              map[string]ClassSummary `json:"classes"`
+	Splits               map[Split]int           `json:"splits,omitempty"`
+	CreatedAt            time.Time               `json:"created_at"`
+	UpdatedAt            time.Time               `json:"updated_at"`
+
+	// FileList is only filled on request
+	FileList []File `json:"file_list,omitempty"`
+}
+
+// Source is the dataset reference training and evaluation jobs use
+func Source(name string) string {
+	return "local-audio/" + name
+}
+
+// ManifestEntry describes an uploaded file, matched by its path
+type ManifestEntry struct {
+	Path     string         `json:"path"`
+	Label    string         `json:"label,omitempty"`
+	Split    Split          `json:"split,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// Manifest maps the cleaned path of an uploaded file to its entry
+type Manifest map[string]ManifestEntry
+
+// Lookup finds the entry of an uploaded file by its full path, or by its
+// base name when that is unambiguous
+func (m Manifest) Lookup(filename string) (ManifestEntry, bool) {
+	key := manifestKey(filename)
+	if e, ok := m[key]; ok {
+		return e, true
+	}
+
+	var found ManifestEntry
+	n := 0
+	for k, e := range m {
+		if path.Base(k) == path.Base(key) {
+			found = e
+			n++
+		}
+	}
+	return found, n == 1
+}
+
+func manifestKey(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(p, `\`, "/")), "/")
+}
+
+// ParseManifest reads a CSV or JSON manifest, the format being given by
+// the file name. CSV manifests need a header with a path column; label
+// and split are optional and any other column becomes metadata. JSON
+// manifests are an array of entries.
+func ParseManifest(r io.Reader, filename string) (Manifest, error) {
+	var entries []ManifestEntry
+	var err error
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv":
+		entries, err = parseCSVManifest(r)
+	case ".json":
+		err = json.NewDecoder(r).Decode(&entries)
+	default:
+		return nil, fmt.Errorf("%w: use a .csv or .json file", ErrInvalidManifest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidManifest, err)
+	}
+
+	m := Manifest{}
+	for i, e := range entries {
+		if e.Path == "" {
+			return nil, fmt.Errorf("%w: entry %d has no path", ErrInvalidManifest, i+1)
+		}
+		if e.Label != "" {
+			if err := ValidateLabel(e.Label); err != nil {
+				return nil, fmt.Errorf("%w: entry %d: %v", ErrInvalidManifest, i+1, err)
+			}
+		}
+		if !e.Split.Valid() {
+			return nil, fmt.Errorf("%w: entry %d: split must be train, validation or test", ErrInvalidManifest, i+1)
+		}
+
+		key := manifestKey(e.Path)
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("%w: %s is listed twice", ErrInvalidManifest, e.Path)
+		}
+		m[key] = e
+	}
+	return m, nil
+}
+
+func parseCSVManifest(r io.Reader) ([]ManifestEntry, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	pathCol := -1
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+		if header[i] == "path" {
+			pathCol = i
+		}
+	}
+	if pathCol < 0 {
+		return nil, errors.New("missing path column")
+	}
+
+	var entries []ManifestEntry
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var e ManifestEntry
+		for i, v := range row {
+			switch header[i] {
+			case "path":
+				e.Path = v
+			case "label":
+				e.Label = v
+			case "split":
+				e.Split = Split(v)
+			default:
+				if v == "" {
+					continue
+				}
+				if e.Metadata == nil {
+					e.Metadata = map[string]any{}
+				}
+				e.Metadata[header[i]] = v
+			}
+		}
+		entries = append(entries, e)
+	}
+}
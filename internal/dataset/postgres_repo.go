@@ -0,0 +1,519 @@
+package dataset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) PostgresRepository {
+	return PostgresRepository{db: db}
+}
+
+// GetRules returns the validation rules of a dataset, DefaultRules when
+// none were set
+func (r PostgresRepository) GetRules(ctx context.Context, name string) (Rules, error) {
+	var raw []byte
+	err := r.db.QueryRow(ctx, `SELECT rules FROM dataset_rules WHERE dataset = $1`, name).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return DefaultRules, nil
+	}
+	if err != nil {
+		return Rules{}, err
+	}
+
+	var rules Rules
+	if err := json.Unmarshal(raw, &rules); err != nil {
+		return Rules{}, err
+	}
+	return rules, nil
+}
+
+// SetRules replaces the validation rules of a dataset. Files already in
+// the dataset are not checked again.
+func (r PostgresRepository) SetRules(ctx context.Context, name string, rules Rules) error {
+	raw, err := json.Marshal(rules)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, `
+		INSERT INTO dataset_rules (dataset, rules)
+		VALUES ($1, $2)
+		ON CONFLICT (dataset) DO UPDATE SET rules = EXCLUDED.rules, updated_at = now()
+	`, name, raw)
+	return err
+}
+
+// AddFiles records uploaded files in the manifest of a dataset, creating
+// the dataset on first upload. A file already recorded at the same path is
+// replaced, keeping its split unless given another. Files without a split
+// get one from the split policy of the dataset, if any. When the manifest
+// changed a new version is snapshotted, the latest version is returned
+// either way.
+func (r PostgresRepository) AddFiles(ctx context.Context, name string, files []File) (*Version, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO datasets (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET updated_at = now()
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range files {
+		var metadata []byte
+		if len(f.Metadata) > 0 {
+			if metadata, err = json.Marshal(f.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		audio := f.Audio
+		if audio == nil {
+			audio = &AudioInfo{}
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO dataset_files (
+				dataset, path, label, split, metadata, sha256, size_bytes,
+				format, encoding, sample_rate, channels, bit_depth, duration_seconds
+			)
+			VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7,
+				NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, 0), NULLIF($11, 0), NULLIF($12, 0), $13)
+			ON CONFLICT (dataset, path) DO UPDATE SET
+				label = EXCLUDED.label,
+				split = COALESCE(EXCLUDED.split, dataset_files.split),
+				metadata = EXCLUDED.metadata,
+				sha256 = EXCLUDED.sha256,
+				size_bytes = EXCLUDED.size_bytes,
+				format = EXCLUDED.format,
+				encoding = EXCLUDED.encoding,
+				sample_rate = EXCLUDED.sample_rate,
+				channels = EXCLUDED.channels,
+				bit_depth = EXCLUDED.bit_depth,
+				duration_seconds = EXCLUDED.duration_seconds
+		`, name, f.Path, f.Label, string(f.Split), metadata, f.SHA256, f.SizeBytes,
+			string(audio.Format), audio.Encoding, audio.SampleRate, audio.Channels, audio.BitDepth, audio.DurationSeconds)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	policy, err := splitPolicy(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
+	if policy != nil {
+		if err := assignSplits(ctx, tx, name, *policy, false); err != nil {
+			return nil, err
+		}
+	}
+
+	v, err := snapshot(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
+	return v, tx.Commit(ctx)
+}
+
+// GetSplitPolicy returns the split policy of a dataset, nil when it has
+// none
+func (r PostgresRepository) GetSplitPolicy(ctx context.Context, name string) (*SplitPolicy, error) {
+	return splitPolicy(ctx, r.db, name)
+}
+
+// SetSplitPolicy stores the split policy of a dataset and assigns splits
+// to the files that have none, to every file with reassign. The latest
+// version is returned, a new one when splits changed.
+func (r PostgresRepository) SetSplitPolicy(ctx context.Context, name string, policy SplitPolicy, reassign bool) (*Version, error) {
+	raw, err := json.Marshal(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE datasets SET split_policy = $2, updated_at = now()
+		WHERE name = $1
+	`, name, raw)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrNotFound
+	}
+
+	if err := assignSplits(ctx, tx, name, policy, reassign); err != nil {
+		return nil, err
+	}
+
+	v, err := snapshot(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
+	return v, tx.Commit(ctx)
+}
+
+// rowQuerier is a pool or a transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func splitPolicy(ctx context.Context, q rowQuerier, name string) (*SplitPolicy, error) {
+	var raw []byte
+	err := q.QueryRow(ctx, `SELECT split_policy FROM datasets WHERE name = $1`, name).Scan(&raw)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil || raw == nil {
+		return nil, err
+	}
+
+	var policy SplitPolicy
+	if err := json.Unmarshal(raw, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// assignSplits applies a split policy to the manifest of a dataset. The
+// caller holds the lock on the datasets row.
+func assignSplits(ctx context.Context, tx pgx.Tx, name string, policy SplitPolicy, reassign bool) error {
+	rows, err := tx.Query(ctx, `
+		SELECT path, label, COALESCE(split, ''), metadata
+		FROM dataset_files
+		WHERE dataset = $1
+		ORDER BY path
+	`, name)
+	if err != nil {
+		return err
+	}
+	var files []File
+	var before []Split
+	for rows.Next() {
+		var f File
+		var split string
+		var metadata []byte
+		if err := rows.Scan(&f.Path, &f.Label, &split, &metadata); err != nil {
+			rows.Close()
+			return err
+		}
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &f.Metadata); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		before = append(before, Split(split))
+		if !reassign {
+			f.Split = Split(split)
+		}
+		files = append(files, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	AssignSplits(files, policy)
+
+	for i, f := range files {
+		if f.Split == before[i] {
+			continue
+		}
+		_, err := tx.Exec(ctx, `
+			UPDATE dataset_files SET split = $3
+			WHERE dataset = $1 AND path = $2
+		`, name, f.Path, string(f.Split))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshot creates a version from the current manifest unless it equals
+// the latest version. The caller holds the lock on the datasets row.
+func snapshot(ctx context.Context, tx pgx.Tx, name string) (*Version, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT path, label, COALESCE(split, ''), sha256, size_bytes
+		FROM dataset_files
+		WHERE dataset = $1
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	for rows.Next() {
+		var f File
+		var split string
+		if err := rows.Scan(&f.Path, &f.Label, &split, &f.SHA256, &f.SizeBytes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		f.Split = Split(split)
+		files = append(files, f)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	v := Version{Dataset: name, Digest: Digest(files), Files: len(files)}
+	for _, f := range files {
+		v.TotalBytes += f.SizeBytes
+	}
+
+	var latest Version
+	err = tx.QueryRow(ctx, `
+		SELECT version, digest, files, total_bytes, created_at
+		FROM dataset_versions
+		WHERE dataset = $1
+		ORDER BY version DESC
+		LIMIT 1
+	`, name).Scan(&latest.Version, &latest.Digest, &latest.Files, &latest.TotalBytes, &latest.CreatedAt)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return nil, err
+	case latest.Digest == v.Digest:
+		latest.Dataset = name
+		latest.Ref = Ref(name, latest.Version)
+		return &latest, nil
+	}
+
+	v.Version = latest.Version + 1
+	v.Ref = Ref(name, v.Version)
+	err = tx.QueryRow(ctx, `
+		INSERT INTO dataset_versions (dataset, version, digest, files, total_bytes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at
+	`, name, v.Version, v.Digest, v.Files, v.TotalBytes).Scan(&v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO dataset_version_files (dataset, version, path, label, split, metadata, sha256, size_bytes)
+		SELECT dataset, $2, path, label, split, metadata, sha256, size_bytes
+		FROM dataset_files
+		WHERE dataset = $1
+	`, name, v.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Get returns the manifest summary of a dataset, with its files when
+// withFiles is set
+func (r PostgresRepository) Get(ctx context.Context, name string, withFiles bool) (*Dataset, error) {
+	d := Dataset{
+		Name:    name,
+		Source:  Source(name),
+		Classes: map[string]ClassSummary{},
+		Splits:  map[Split]int{},
+	}
+	err := r.db.QueryRow(ctx, `SELECT created_at, updated_at FROM datasets WHERE name = $1`, name).
+		Scan(&d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT label, COUNT(*), SUM(size_bytes), COALESCE(SUM(duration_seconds), 0)
+		FROM dataset_files
+		WHERE dataset = $1
+		GROUP BY label
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var label string
+		var c ClassSummary
+		if err := rows.Scan(&label, &c.Files, &c.Bytes, &c.DurationSeconds); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Classes[label] = c
+		d.Files += c.Files
+		d.TotalBytes += c.Bytes
+		d.TotalDurationSeconds += c.DurationSeconds
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = r.db.Query(ctx, `
+		SELECT split, COUNT(*)
+		FROM dataset_files
+		WHERE dataset = $1 AND split IS NOT NULL
+		GROUP BY split
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var split string
+		var n int
+		if err := rows.Scan(&split, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Splits[Split(split)] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if withFiles {
+		if d.FileList, err = r.files(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	return &d, nil
+}
+
+func (r PostgresRepository) files(ctx context.Context, name string) ([]File, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT path, label, COALESCE(split, ''), metadata, sha256, size_bytes,
+			format, COALESCE(encoding, ''), COALESCE(sample_rate, 0), COALESCE(channels, 0),
+			COALESCE(bit_depth, 0), COALESCE(duration_seconds, 0), created_at
+		FROM dataset_files
+		WHERE dataset = $1
+		ORDER BY path
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []File
+	for rows.Next() {
+		var f File
+		var split string
+		var metadata []byte
+		var format *string
+		var audio AudioInfo
+		if err := rows.Scan(&f.Path, &f.Label, &split, &metadata, &f.SHA256, &f.SizeBytes,
+			&format, &audio.Encoding, &audio.SampleRate, &audio.Channels,
+			&audio.BitDepth, &audio.DurationSeconds, &f.CreatedAt); err != nil {
+			return nil, err
+		}
+		f.Split = Split(split)
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &f.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		if format != nil {
+			audio.Format = AudioFormat(*format)
+			f.Audio = &audio
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// Versions lists the versions of a dataset, latest first
+func (r PostgresRepository) Versions(ctx context.Context, name string) ([]Version, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT version, digest, files, total_bytes, created_at
+		FROM dataset_versions
+		WHERE dataset = $1
+		ORDER BY version DESC
+	`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []Version
+	for rows.Next() {
+		v := Version{Dataset: name}
+		if err := rows.Scan(&v.Version, &v.Digest, &v.Files, &v.TotalBytes, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		v.Ref = Ref(name, v.Version)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetVersion returns a version of a dataset, the latest one when version
+// is 0, with its files when withFiles is set
+func (r PostgresRepository) GetVersion(ctx context.Context, name string, version int, withFiles bool) (*Version, error) {
+	v := Version{Dataset: name}
+	err := r.db.QueryRow(ctx, `
+		SELECT version, digest, files, total_bytes, created_at
+		FROM dataset_versions
+		WHERE dataset = $1 AND ($2 = 0 OR version = $2)
+		ORDER BY version DESC
+		LIMIT 1
+	`, name, version).Scan(&v.Version, &v.Digest, &v.Files, &v.TotalBytes, &v.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	v.Ref = Ref(name, v.Version)
+
+	if !withFiles {
+		return &v, nil
+	}
+
+	rows, err := r.db.Query(ctx, `
+		SELECT path, label, COALESCE(split, ''), metadata, sha256, size_bytes
+		FROM dataset_version_files
+		WHERE dataset = $1 AND version = $2
+		ORDER BY path
+	`, name, v.Version)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f File
+		var split string
+		var metadata []byte
+		if err := rows.Scan(&f.Path, &f.Label, &split, &metadata, &f.SHA256, &f.SizeBytes); err != nil {
+			return nil, err
+		}
+		f.Split = Split(split)
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &f.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		v.FileList = append(v.FileList, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
@@ -0,0 +1,271 @@
+package dataset
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	ilog "audioml/internal/logger"
+)
+
+// Service keeps the manifests of the datasets under root and their
+// immutable versions. Live datasets are in root/local-audio/<name>; blobs
+// and checked out versions live in hidden folders next to them.
+type Service struct {
+	repo  PostgresRepository
+	root  string
+	blobs BlobStore
+	// quota applies to datasets whose rules do not set their own
+	quota Quota
+}
+
+func NewService(repo PostgresRepository, root string, quota Quota) *Service {
+	return &Service{
+		repo:  repo,
+		root:  root,
+		blobs: BlobStore{Root: filepath.Join(root, ".blobs")},
+		quota: quota,
+	}
+}
+
+// Dir is the live directory of a dataset. The name must have been
+// validated.
+func (s *Service) Dir(name string) string {
+	return filepath.Join(s.root, "local-audio", name)
+}
+
+// Prepare validates a dataset name and creates its live directory
+func (s *Service) Prepare(name string) (string, error) {
+	if err := ValidateName(name); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(s.root, 0755); err != nil {
+		return "", err
+	}
+	return ensureDir(s.root, "local-audio/"+name)
+}
+
+// Staging creates a directory to receive an upload in, on the same file
+// system as the datasets so that placing a file is a link. The caller
+// removes it.
+func (s *Service) Staging() (string, error) {
+	dir := filepath.Join(s.root, ".uploads")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(dir, "upload-")
+}
+
+// Place moves a staged file into the live directory of a dataset at rel,
+// a sanitized name optionally inside its class folder. See place for
+// how name collisions are handled.
+func (s *Service) Place(name, staged, rel, sum string) (stored string, duplicate bool, err error) {
+	return place(s.Dir(name), staged, rel, sum)
+}
+
+// Quota is the quota of a dataset with the given rules
+func (s *Service) Quota(rules Rules) Quota {
+	q := s.quota
+	if rules.MaxFiles > 0 {
+		q.MaxFiles = rules.MaxFiles
+	}
+	if rules.MaxBytes > 0 {
+		q.MaxBytes = rules.MaxBytes
+	}
+	return q
+}
+
+// Usage is the current size of the live directory of a dataset
+func (s *Service) Usage(name string) (Usage, error) {
+	return DirUsage(s.Dir(name))
+}
+
+func (s *Service) Rules(ctx context.Context, name string) (Rules, error) {
+	return s.repo.GetRules(ctx, name)
+}
+
+func (s *Service) SetRules(ctx context.Context, name string, rules Rules) error {
+	return s.repo.SetRules(ctx, name, rules)
+}
+
+func (s *Service) Get(ctx context.Context, name string, withFiles bool) (*Dataset, error) {
+	return s.repo.Get(ctx, name, withFiles)
+}
+
+func (s *Service) Versions(ctx context.Context, name string) ([]Version, error) {
+	return s.repo.Versions(ctx, name)
+}
+
+func (s *Service) Version(ctx context.Context, name string, version int) (*Version, error) {
+	return s.repo.GetVersion(ctx, name, version, true)
+}
+
+// SplitPolicy returns the split policy of a dataset, nil when it has none
+func (s *Service) SplitPolicy(ctx context.Context, name string) (*SplitPolicy, error) {
+	return s.repo.GetSplitPolicy(ctx, name)
+}
+
+// SetSplitPolicy assigns splits to the files of a dataset that have none,
+// or to every file with reassign, and to the files of later uploads. It
+// returns the version holding the new splits.
+func (s *Service) SetSplitPolicy(ctx context.Context, name string, policy SplitPolicy, reassign bool) (*Version, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return s.repo.SetSplitPolicy(ctx, name, policy, reassign)
+}
+
+// Commit records files already stored in the live directory of a dataset
+// and returns the version that contains them
+func (s *Service) Commit(ctx context.Context, name string, files []File) (*Version, error) {
+	for _, f := range files {
+		if err := s.blobs.Put(filepath.Join(s.Dir(name), filepath.FromSlash(f.Path)), f.SHA256); err != nil {
+			return nil, fmt.Errorf("store %s: %w", f.Path, err)
+		}
+	}
+
+	v, err := s.repo.AddFiles(ctx, name, files)
+	if err != nil {
+		return nil, err
+	}
+
+	s.adopt(ctx, name, v.Version)
+	return v, nil
+}
+
+// adopt stores the blobs of files recorded before datasets were versioned,
+// as long as the live copy still has the recorded content
+func (s *Service) adopt(ctx context.Context, name string, version int) {
+	v, err := s.repo.GetVersion(ctx, name, version, true)
+	if err != nil {
+		ilog.L.Printf("adopt blobs of dataset %s: %v", name, err)
+		return
+	}
+
+	for _, f := range v.FileList {
+		if s.blobs.Has(f.SHA256) {
+			continue
+		}
+		src := filepath.Join(s.Dir(name), filepath.FromSlash(f.Path))
+		sum, err := FileSHA256(src)
+		if err != nil || sum != f.SHA256 {
+			ilog.L.Printf("dataset %s@%d: %s is missing from the blob store", name, version, f.Path)
+			continue
+		}
+		if err := s.blobs.Put(src, sum); err != nil {
+			ilog.L.Printf("dataset %s@%d: store %s: %v", name, version, f.Path, err)
+		}
+	}
+}
+
+// Resolve pins a dataset reference, "local-audio/<name>[@<version>]", to
+// a version. Without a version the latest one is used; a dataset that was
+// never versioned resolves to its live directory.
+func (s *Service) Resolve(ctx context.Context, ref string) (*Snapshot, error) {
+	name, version, err := ParseRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := s.repo.GetVersion(ctx, name, version, false)
+	switch {
+	case errors.Is(err, ErrVersionNotFound) && version == 0:
+		return &Snapshot{Name: name, Source: Source(name)}, nil
+	case err != nil:
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+
+	return &Snapshot{Name: name, Version: v.Version, Digest: v.Digest, Source: v.Ref}, nil
+}
+
+// Checkout returns a directory holding exactly the files of a snapshot,
+// in their class folders. Versions are checked out once, as hard links to
+// their blobs, and must not be modified.
+func (s *Service) Checkout(ctx context.Context, snap *Snapshot) (string, error) {
+	if snap.Version == 0 {
+		return s.Dir(snap.Name), nil
+	}
+
+	dir := filepath.Join(s.root, ".snapshots", snap.Name, fmt.Sprint(snap.Version))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, s.ensureSplits(ctx, snap, dir)
+	}
+
+	v, err := s.repo.GetVersion(ctx, snap.Name, snap.Version, true)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), ".checkout-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, f := range v.FileList {
+		dst := filepath.Join(tmp, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return "", err
+		}
+		if err := linkOrCopy(s.blobs.Path(f.SHA256), dst); err != nil {
+			return "", fmt.Errorf("%s@%d: %s: %w", snap.Name, snap.Version, f.Path, err)
+		}
+	}
+	if err := writeSplits(filepath.Join(tmp, SplitsFile), v.FileList); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp, dir); err != nil {
+		// Checked out concurrently by another job
+		if _, statErr := os.Stat(dir); statErr == nil {
+			return dir, nil
+		}
+		return "", err
+	}
+	return dir, nil
+}
+
+// ensureSplits writes the split manifest of a version checked out before
+// splits were written along
+func (s *Service) ensureSplits(ctx context.Context, snap *Snapshot, dir string) error {
+	path := filepath.Join(dir, SplitsFile)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	v, err := s.repo.GetVersion(ctx, snap.Name, snap.Version, true)
+	if err != nil {
+		return err
+	}
+	return writeSplits(path, v.FileList)
+}
+
+// writeSplits writes the path, label and split of every file as CSV, an
+// empty split meaning unassigned
+func writeSplits(path string, files []File) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".splits-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := csv.NewWriter(tmp)
+	w.Write([]string{"path", "label", "split"})
+	for _, f := range files {
+		w.Write([]string{f.Path, f.Label, string(f.Split)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
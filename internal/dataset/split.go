@@ -0,0 +1,190 @@
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var ErrInvalidSplitPolicy = errors.New("invalid split policy")
+
+// SplitsFile is the manifest of a checked out version, path, label and
+// split of every file, for the trainer. Hidden so that it is not taken
+// for a class folder or an audio file.
+const SplitsFile = ".splits.csv"
+
+// SplitPolicy assigns splits to the files of a dataset that have none.
+// The assignment only depends on the seed and the manifest: it is
+// stratified by label, and files keep their split in later versions, so
+// that every version of a model is tested on the same files.
+type SplitPolicy struct {
+	Seed       int64   `json:"seed"`
+	Train      float64 `json:"train"`
+	Validation float64 `json:"validation"`
+	Test       float64 `json:"test"`
+	// GroupBy is a metadata key, the speaker or recording id. Files with
+	// the same value always land in the same split.
+	GroupBy string `json:"group_by,omitempty"`
+}
+
+func (p SplitPolicy) Validate() error {
+	for _, r := range []float64{p.Train, p.Validation, p.Test} {
+		if r < 0 || r > 1 || math.IsNaN(r) {
+			return fmt.Errorf("%w: ratios must be between 0 and 1", ErrInvalidSplitPolicy)
+		}
+	}
+	if math.Abs(p.Train+p.Validation+p.Test-1) > 1e-6 {
+		return fmt.Errorf("%w: train, validation and test must add up to 1", ErrInvalidSplitPolicy)
+	}
+	if p.Train == 0 {
+		return fmt.Errorf("%w: train must not be empty", ErrInvalidSplitPolicy)
+	}
+	return nil
+}
+
+// splitOrder breaks ties between splits lacking as many files
+var splitOrder = []Split{SplitTrain, SplitValidation, SplitTest}
+
+func (p SplitPolicy) ratio(s Split) float64 {
+	switch s {
+	case SplitTrain:
+		return p.Train
+	case SplitValidation:
+		return p.Validation
+	case SplitTest:
+		return p.Test
+	}
+	return 0
+}
+
+// splitGroup is a set of files that must share a split
+type splitGroup struct {
+	key   string
+	label string
+	split Split
+	files []int
+	rank  uint64
+}
+
+// AssignSplits gives a split to every file without one and returns the
+// indexes of the files it changed. A file joins the split of its group
+// when another file of the group already has one. Other groups are
+// ranked by a hash of the seed and their key and, label by label, each
+// goes to the split furthest below its share.
+func AssignSplits(files []File, p SplitPolicy) []int {
+	groups := map[string]*splitGroup{}
+	var keys []string
+	for i, f := range files {
+		key := "file:" + f.Path
+		if p.GroupBy != "" {
+			if v, ok := f.Metadata[p.GroupBy]; ok && v != nil && fmt.Sprint(v) != "" {
+				key = "group:" + fmt.Sprint(v)
+			}
+		}
+		g, ok := groups[key]
+		if !ok {
+			g = &splitGroup{key: key, rank: splitRank(p.Seed, key)}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		g.files = append(g.files, i)
+	}
+
+	// Files are counted per label, a group spanning labels belongs to
+	// its most common one
+	type stratum struct {
+		total  int
+		counts map[Split]int
+		groups []*splitGroup
+	}
+	strata := map[string]*stratum{}
+	sort.Strings(keys)
+	for _, key := range keys {
+		g := groups[key]
+		labels := map[string]int{}
+		for _, i := range g.files {
+			labels[files[i].Label]++
+			if g.split == "" && files[i].Split != "" {
+				g.split = files[i].Split
+			}
+		}
+		g.label = majority(labels)
+
+		st, ok := strata[g.label]
+		if !ok {
+			st = &stratum{counts: map[Split]int{}}
+			strata[g.label] = st
+		}
+		st.total += len(g.files)
+		for _, i := range g.files {
+			if files[i].Split != "" {
+				st.counts[files[i].Split]++
+			}
+		}
+		st.groups = append(st.groups, g)
+	}
+
+	var changed []int
+	for _, st := range strata {
+		sort.Slice(st.groups, func(i, j int) bool {
+			if st.groups[i].rank != st.groups[j].rank {
+				return st.groups[i].rank < st.groups[j].rank
+			}
+			return st.groups[i].key < st.groups[j].key
+		})
+
+		for _, g := range st.groups {
+			split := g.split
+			if split == "" {
+				split = SplitTrain
+				best := math.Inf(-1)
+				for _, s := range splitOrder {
+					if p.ratio(s) == 0 {
+						continue
+					}
+					if deficit := p.ratio(s)*float64(st.total) - float64(st.counts[s]); deficit > best {
+						split, best = s, deficit
+					}
+				}
+			}
+
+			for _, i := range g.files {
+				if files[i].Split != "" {
+					continue
+				}
+				files[i].Split = split
+				st.counts[split]++
+				changed = append(changed, i)
+			}
+		}
+	}
+	sort.Ints(changed)
+	return changed
+}
+
+// majority is the most common label, the first in order on a tie
+func majority(counts map[string]int) string {
+	labels := make([]string, 0, len(counts))
+	for l := range counts {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+
+	best := labels[0]
+	for _, l := range labels[1:] {
+		if counts[l] > counts[best] {
+			best = l
+		}
+	}
+	return best
+}
+
+func splitRank(seed int64, key string) uint64 {
+	h := sha256.New()
+	binary.Write(h, binary.LittleEndian, seed)
+	h.Write([]byte(key))
+	return binary.BigEndian.Uint64(h.Sum(nil)[:8])
+}
@@ -0,0 +1,195 @@
+package dataset
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidName     = errors.New("invalid dataset name")
+	ErrInvalidFilename = errors.New("invalid filename")
+	ErrQuotaExceeded   = errors.New("dataset quota exceeded")
+	ErrSymlink         = errors.New("symbolic links are not allowed in datasets")
+)
+
+// Dataset names become directories and labels class folders
+var (
+	namePattern  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+	labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+	unsafeChars  = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+)
+
+// maxFilenameLength keeps stored names, with a "-<n>" suffix added on
+// collision, within the 255 bytes most file systems allow
+const maxFilenameLength = 200
+
+// ValidateName checks a dataset name: 1-64 letters, digits, '_' or '-'
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w %q: use 1-64 letters, digits, '_' or '-'", ErrInvalidName, name)
+	}
+	return nil
+}
+
+// ValidateLabel checks a label can be used as a class folder name: 1-64
+// letters, digits, '_', '-' or '.', not starting with '.'
+func ValidateLabel(label string) error {
+	if !labelPattern.MatchString(label) {
+		return fmt.Errorf("%w %q: use 1-64 letters, digits, '_', '-' or '.'", ErrInvalidLabel, label)
+	}
+	return nil
+}
+
+// SanitizeFilename reduces an uploaded file name to a safe base name: any
+// directory is dropped and runs of characters other than letters, digits,
+// '.', '_' and '-' become '_'. Hidden files and names without any usable
+// character are rejected.
+func SanitizeFilename(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = path.Base(path.Clean("/" + name))
+	if strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("%w: hidden files are not allowed", ErrInvalidFilename)
+	}
+
+	name = unsafeChars.ReplaceAllString(name, "_")
+	if strings.Trim(name, "._-") == "" {
+		return "", fmt.Errorf("%w: no usable characters", ErrInvalidFilename)
+	}
+
+	if len(name) > maxFilenameLength {
+		ext := path.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = name[:maxFilenameLength-len(ext)] + ext
+	}
+	return name, nil
+}
+
+// Quota limits the size of one dataset, zero meaning no limit
+type Quota struct {
+	MaxFiles int
+	MaxBytes int64
+}
+
+// Usage is the current size of a live dataset directory
+type Usage struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Allows reports whether adding files and bytes keeps usage within the quota
+func (q Quota) Allows(u Usage, files int, bytes int64) bool {
+	if q.MaxFiles > 0 && u.Files+files > q.MaxFiles {
+		return false
+	}
+	if q.MaxBytes > 0 && u.Bytes+bytes > q.MaxBytes {
+		return false
+	}
+	return true
+}
+
+// DirUsage counts the files of a dataset directory, a missing directory
+// being empty
+func DirUsage(dir string) (Usage, error) {
+	var u Usage
+	err := Walk(dir, func(_ string, info fs.FileInfo) error {
+		u.Files++
+		u.Bytes += info.Size()
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return Usage{}, nil
+	}
+	return u, err
+}
+
+// ensureDir creates dir below root, refusing to follow a symbolic link on
+// the way so a planted link cannot redirect writes out of the data root
+func ensureDir(root, rel string) (string, error) {
+	dir := root
+	if err := noSymlink(dir); err != nil {
+		return "", err
+	}
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		if part == ".." {
+			return "", fmt.Errorf("%w: %s", ErrInvalidFilename, rel)
+		}
+		dir = filepath.Join(dir, part)
+		if err := os.Mkdir(dir, 0755); err != nil && !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+		if err := noSymlink(dir); err != nil {
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+func noSymlink(p string) error {
+	info, err := os.Lstat(p)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("%w: %s", ErrSymlink, p)
+	}
+	return nil
+}
+
+// place moves the staged file to rel inside dir. A file with the same
+// name and content makes it a duplicate that is not stored again; with
+// another content it gets the first free "<name>-<n><ext>". The stored
+// path is returned.
+func place(dir, staged, rel, sum string) (stored string, duplicate bool, err error) {
+	ext := path.Ext(rel)
+	stem := strings.TrimSuffix(rel, ext)
+
+	if _, err := ensureDir(dir, path.Dir(rel)); err != nil {
+		return "", false, err
+	}
+
+	name := rel
+	for n := 1; ; n++ {
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		info, err := os.Lstat(target)
+		if errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		if err != nil {
+			return "", false, err
+		}
+
+		if info.Mode().IsRegular() {
+			existing, err := FileSHA256(target)
+			if err != nil {
+				return "", false, err
+			}
+			if existing == sum {
+				return name, true, nil
+			}
+		}
+		name = fmt.Sprintf("%s-%d%s", stem, n, ext)
+	}
+
+	// Link rather than rename so that a file created meanwhile under the
+	// same name is never replaced
+	target := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.Link(staged, target); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return place(dir, staged, rel, sum)
+		}
+		return "", false, err
+	}
+	os.Remove(staged)
+	return name, false, nil
+}
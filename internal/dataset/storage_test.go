@@ -0,0 +1,244 @@
+package dataset
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"emotion", true},
+		{"speech_v2-clean", true},
+		{strings.Repeat("a", 64), true},
+		{"", false},
+		{"..", false},
+		{"../x", false},
+		{"a/b", false},
+		{`a\b`, false},
+		{".hidden", false},
+		{"-flag", false},
+		{strings.Repeat("a", 65), false},
+		{"a\x00b", false},
+		{"\x00", false},
+		{"a b", false},
+	}
+	for _, tt := range tests {
+		err := ValidateName(tt.name)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateName(%q) = %v, want nil", tt.name, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidName) {
+			t.Errorf("ValidateName(%q) = %v, want ErrInvalidName", tt.name, err)
+		}
+	}
+}
+
+func TestValidateLabel(t *testing.T) {
+	tests := []struct {
+		label string
+		valid bool
+	}{
+		{"happy", true},
+		{"v1.2", true},
+		{"", false},
+		{"..", false},
+		{".hidden", false},
+		{"../sad", false},
+		{"a/b", false},
+		{"a\x00", false},
+		{strings.Repeat("a", 65), false},
+	}
+	for _, tt := range tests {
+		err := ValidateLabel(tt.label)
+		if tt.valid && err != nil {
+			t.Errorf("ValidateLabel(%q) = %v, want nil", tt.label, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrInvalidLabel) {
+			t.Errorf("ValidateLabel(%q) = %v, want ErrInvalidLabel", tt.label, err)
+		}
+	}
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // empty when rejected
+	}{
+		{"a.wav", "a.wav"},
+		{"happy/a.wav", "a.wav"},
+		{"../../etc/passwd", "passwd"},
+		{`..\x.wav`, "x.wav"},
+		{`..\..\windows\x.wav`, "x.wav"},
+		{"/etc/passwd", "passwd"},
+		{`C:\audio\x.wav`, "x.wav"},
+		{"my file (1).wav", "my_file_1_.wav"},
+		{"a\x00b.wav", "a_b.wav"},
+		{"x.wav\x00.sh", "x.wav_.sh"},
+		{".hidden.wav", ""},
+		{"dir/.hidden", ""},
+		{"..", ""},
+		{".", ""},
+		{"/", ""},
+		{"", ""},
+		{"\x00", ""},
+		{"___", ""},
+	}
+	for _, tt := range tests {
+		got, err := SanitizeFilename(tt.name)
+		if tt.want == "" {
+			if !errors.Is(err, ErrInvalidFilename) {
+				t.Errorf("SanitizeFilename(%q) = %q, %v, want ErrInvalidFilename", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("SanitizeFilename(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	long, err := SanitizeFilename(strings.Repeat("a", 300) + ".wav")
+	if err != nil || len(long) > maxFilenameLength || !strings.HasSuffix(long, ".wav") {
+		t.Errorf("SanitizeFilename(long name) = %q, %v, want at most %d bytes ending in .wav", long, err, maxFilenameLength)
+	}
+}
+
+func TestPlaceRefusesSymlinkedDataset(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	dir := filepath.Join(root, "emotion")
+	if err := os.Symlink(outside, dir); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	staged := stage(t, root, "audio")
+	if _, _, err := place(dir, staged, "a.wav", "sum"); !errors.Is(err, ErrSymlink) {
+		t.Fatalf("place into a symlinked dataset = %v, want ErrSymlink", err)
+	}
+	assertEmpty(t, outside)
+}
+
+func TestPlaceRefusesSymlinkedClassFolder(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "happy")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	staged := stage(t, t.TempDir(), "audio")
+	if _, _, err := place(dir, staged, "happy/a.wav", "sum"); !errors.Is(err, ErrSymlink) {
+		t.Fatalf("place into a symlinked class folder = %v, want ErrSymlink", err)
+	}
+	assertEmpty(t, outside)
+}
+
+func TestPlaceDoesNotWriteThroughSymlinkedTarget(t *testing.T) {
+	dir := t.TempDir()
+	victim := filepath.Join(t.TempDir(), "victim")
+	if err := os.WriteFile(victim, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(victim, filepath.Join(dir, "a.wav")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	staged := stage(t, dir, "audio")
+	stored, duplicate, err := place(dir, staged, "a.wav", "sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if duplicate || stored != "a-1.wav" {
+		t.Errorf("place = %q, duplicate %v, want a-1.wav", stored, duplicate)
+	}
+
+	if b, _ := os.ReadFile(victim); string(b) != "keep" {
+		t.Errorf("symlink target was overwritten with %q", b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "a-1.wav")); string(b) != "audio" {
+		t.Errorf("stored file holds %q, want the upload", b)
+	}
+}
+
+func TestEnsureDirRejectsParentElements(t *testing.T) {
+	if _, err := ensureDir(t.TempDir(), "../escape"); !errors.Is(err, ErrInvalidFilename) {
+		t.Errorf("ensureDir(../escape) = %v, want ErrInvalidFilename", err)
+	}
+}
+
+func TestQuotaAllows(t *testing.T) {
+	q := Quota{MaxFiles: 10, MaxBytes: 1000}
+	tests := []struct {
+		usage Usage
+		files int
+		bytes int64
+		want  bool
+	}{
+		{Usage{}, 1, 100, true},
+		{Usage{Files: 9, Bytes: 900}, 1, 100, true},
+		{Usage{Files: 9, Bytes: 900}, 1, 101, false},
+		{Usage{Files: 10, Bytes: 0}, 1, 1, false},
+		{Usage{Files: 5, Bytes: 1000}, 1, 0, true},
+		{Usage{Files: 0, Bytes: 0}, 1, 1001, false},
+	}
+	for _, tt := range tests {
+		if got := q.Allows(tt.usage, tt.files, tt.bytes); got != tt.want {
+			t.Errorf("Allows(%+v, %d, %d) = %v, want %v", tt.usage, tt.files, tt.bytes, got, tt.want)
+		}
+	}
+
+	if !(Quota{}).Allows(Usage{Files: 1 << 30, Bytes: 1 << 60}, 1, 1<<40) {
+		t.Error("zero quota must not limit")
+	}
+}
+
+func TestDirUsageAndAllows(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.wav"), make([]byte, 600), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := DirUsage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Files != 1 || u.Bytes != 600 {
+		t.Fatalf("DirUsage = %+v, want 1 file of 600 bytes", u)
+	}
+
+	q := Quota{MaxBytes: 1000}
+	if !q.Allows(u, 1, 400) {
+		t.Error("a file filling the quota exactly must be allowed")
+	}
+	if q.Allows(u, 1, 401) {
+		t.Error("a file exceeding the quota must be refused")
+	}
+}
+
+func stage(t *testing.T, dir, content string) string {
+	t.Helper()
+	f, err := os.CreateTemp(dir, ".staged-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func assertEmpty(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		t.Errorf("%s has %d entries, nothing may be written outside the dataset", dir, len(entries))
+	}
+}
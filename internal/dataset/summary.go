@@ -0,0 +1,93 @@
+package dataset
+
+import (
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// UnlabeledClass groups files that are not inside a class folder
+const UnlabeledClass = "unlabeled"
+
+// Summary describes the content of a dataset directory
+type Summary struct {
+	Files                int                     `json:"files"`
+	TotalBytes           int64                   `json:"total_bytes"`
+	TotalDurationSeconds float64                 `json:"total_duration_seconds,omitempty"`
+	Classes              map[string]ClassSummary `json:"classes"`
+	// Features are reported by the trainer, they are the drift baseline
+	Features FeatureStats `json:"features,omitempty"`
+}
+
+// ClassSummary describes the files of one class
+type ClassSummary struct {
+	Files           int     `json:"files"`
+	Bytes           int64   `json:"bytes"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+}
+
+// Walk calls fn for every regular file of a dataset directory, with its
+// slash separated path relative to dir. Hidden files and folders are skipped.
+func Walk(dir string, fn func(rel string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && p != dir {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		return fn(filepath.ToSlash(rel), info)
+	})
+}
+
+// Summarize walks a dataset directory. The first folder level below dir
+// is taken as the class label, files at the top level are unlabeled.
+func Summarize(dir string) (*Summary, error) {
+	s := &Summary{Classes: map[string]ClassSummary{}}
+
+	err := Walk(dir, func(rel string, info fs.FileInfo) error {
+		label := UnlabeledClass
+		if parts := strings.Split(rel, "/"); len(parts) > 1 {
+			label = parts[0]
+		}
+
+		// Files that are not readable audio still count, without a duration
+		var duration float64
+		if audio, err := Probe(filepath.Join(dir, filepath.FromSlash(rel))); err == nil {
+			duration = audio.DurationSeconds
+		}
+
+		c := s.Classes[label]
+		c.Files++
+		c.Bytes += info.Size()
+		c.DurationSeconds += duration
+		s.Classes[label] = c
+
+		s.Files++
+		s.TotalBytes += info.Size()
+		s.TotalDurationSeconds += duration
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -1,41 +1,166 @@
 package dataset
 
-// "errors"
-
-// "strconv"
-// "strings"
-
-func ValidateAudio(path string) error {
-	// cmd := exec.Command(
-	// 	"ffprobe",
-	// 	"-v", "error",
-	// 	"-show_entries", "format=duration",
-	// 	"-show_entries", "stream=sample_rate",
-	// 	"-of", "default=noprint_wrappers=1",
-	// 	path,
-	// )
-
-	// var out bytes.Buffer
-	// cmd.Stdout = &out
-
-	// if err := cmd.Run(); err != nil {
-	// 	return errors.New("invalid audio file")
-	// }
-
-	// output := out.String()
-
-	// if !strings.Contains(output, "sample_rate=44100") {
-	// 	return errors.New("sample rate must be 44100 Hz")
-	// }
-
-	// for _, line := range strings.Split(output, "\n") {
-	// 	if strings.HasPrefix(line, "duration=") {
-	// 		d, _ := strconv.ParseFloat(strings.TrimPrefix(line, "duration="), 64)
-	// 		if d < 1.0 {
-	// 			return errors.New("audio duration too short")
-	// 		}
-	// 	}
-	// }
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
 
+type ValidationCode string
+
+const (
+	CodeUnreadable         ValidationCode = "unreadable"
+	CodeUnsupportedFormat  ValidationCode = "unsupported_format"
+	CodeCorrupt            ValidationCode = "corrupt"
+	CodeFormatNotAllowed   ValidationCode = "format_not_allowed"
+	CodeSampleRate         ValidationCode = "sample_rate_not_allowed"
+	CodeChannels           ValidationCode = "channels_not_allowed"
+	CodeDurationTooShort   ValidationCode = "duration_too_short"
+	CodeDurationTooLong    ValidationCode = "duration_too_long"
+	CodeDurationUnknown    ValidationCode = "duration_unknown"
+	CodeBitDepthNotAllowed ValidationCode = "bit_depth_not_allowed"
+
+	// Upload checks that do not look at the audio
+	CodeInvalidFilename ValidationCode = "invalid_filename"
+	CodeInvalidLabel    ValidationCode = "invalid_label"
+	CodeQuotaExceeded   ValidationCode = "quota_exceeded"
+	CodeFileTooLarge    ValidationCode = "file_too_large"
+
+	// Archive checks, for the archive as a whole or one of its entries
+	CodeInvalidArchive  ValidationCode = "invalid_archive"
+	CodeArchiveTooLarge ValidationCode = "archive_too_large"
+	CodeUnsafePath      ValidationCode = "unsafe_path"
+	CodeNotRegularFile  ValidationCode = "not_regular_file"
+)
+
+// ValidationError explains why a file was rejected. Info is set when the
+// headers could be read.
+type ValidationError struct {
+	Code    ValidationCode `json:"code"`
+	Message string         `json:"message"`
+	Info    *AudioInfo     `json:"info,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	return string(e.Code) + ": " + e.Message
+}
+
+// Is matches validation errors by code, so errors.Is(err, ErrCorrupt) works
+func (e *ValidationError) Is(target error) bool {
+	t, ok := target.(*ValidationError)
+	return ok && t.Code == e.Code
+}
+
+// Sentinels to match validation errors with errors.Is
+var (
+	ErrUnreadable        = &ValidationError{Code: CodeUnreadable}
+	ErrUnsupportedFormat = &ValidationError{Code: CodeUnsupportedFormat}
+	ErrCorrupt           = &ValidationError{Code: CodeCorrupt}
+)
+
+// Rules constrain the audio accepted into a dataset. Empty lists and zero
+// durations accept anything.
+type Rules struct {
+	Formats            []AudioFormat `json:"formats,omitempty"`
+	SampleRates        []int         `json:"sample_rates,omitempty"`
+	Channels           []int         `json:"channels,omitempty"`
+	BitDepths          []int         `json:"bit_depths,omitempty"`
+	MinDurationSeconds float64       `json:"min_duration_seconds,omitempty"`
+	MaxDurationSeconds float64       `json:"max_duration_seconds,omitempty"`
+
+	// Quota of the dataset, zero keeps the server default
+	MaxFiles int   `json:"max_files,omitempty"`
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+}
+
+// DefaultRules accept any readable WAV, FLAC or MP3 file
+var DefaultRules = Rules{}
+
+// Check validates the rules themselves
+func (r Rules) Check() error {
+	for _, f := range r.Formats {
+		if f != FormatWAV && f != FormatFLAC && f != FormatMP3 {
+			return fmt.Errorf("unknown format %q, use wav, flac or mp3", f)
+		}
+	}
+	for _, sr := range r.SampleRates {
+		if sr <= 0 {
+			return fmt.Errorf("sample rates must be positive")
+		}
+	}
+	for _, c := range r.Channels {
+		if c <= 0 {
+			return fmt.Errorf("channels must be positive")
+		}
+	}
+	for _, b := range r.BitDepths {
+		if b <= 0 {
+			return fmt.Errorf("bit depths must be positive")
+		}
+	}
+	if r.MinDurationSeconds < 0 || r.MaxDurationSeconds < 0 {
+		return fmt.Errorf("durations must not be negative")
+	}
+	if r.MaxDurationSeconds > 0 && r.MinDurationSeconds > r.MaxDurationSeconds {
+		return fmt.Errorf("min_duration_seconds is above max_duration_seconds")
+	}
+	if r.MaxFiles < 0 || r.MaxBytes < 0 {
+		return fmt.Errorf("max_files and max_bytes must not be negative")
+	}
+	return nil
+}
+
+// Validate checks the properties of a probed file against the rules
+func (r Rules) Validate(info *AudioInfo) error {
+	reject := func(code ValidationCode, format string, args ...any) error {
+		return &ValidationError{Code: code, Message: fmt.Sprintf(format, args...), Info: info}
+	}
+
+	if len(r.Formats) > 0 && !slices.Contains(r.Formats, info.Format) {
+		return reject(CodeFormatNotAllowed, "format %s is not one of %s", info.Format, joinFormats(r.Formats))
+	}
+	if len(r.SampleRates) > 0 && !slices.Contains(r.SampleRates, info.SampleRate) {
+		return reject(CodeSampleRate, "sample rate %d Hz is not one of %v", info.SampleRate, r.SampleRates)
+	}
+	if len(r.Channels) > 0 && !slices.Contains(r.Channels, info.Channels) {
+		return reject(CodeChannels, "%d channels, expected one of %v", info.Channels, r.Channels)
+	}
+	// Lossy formats have no bit depth to check
+	if len(r.BitDepths) > 0 && info.BitDepth > 0 && !slices.Contains(r.BitDepths, info.BitDepth) {
+		return reject(CodeBitDepthNotAllowed, "bit depth %d is not one of %v", info.BitDepth, r.BitDepths)
+	}
+
+	if r.MinDurationSeconds > 0 || r.MaxDurationSeconds > 0 {
+		if info.DurationSeconds == 0 {
+			return reject(CodeDurationUnknown, "duration is not recorded in the file")
+		}
+	}
+	if r.MinDurationSeconds > 0 && info.DurationSeconds < r.MinDurationSeconds {
+		return reject(CodeDurationTooShort, "%.2f s is shorter than %.2f s", info.DurationSeconds, r.MinDurationSeconds)
+	}
+	if r.MaxDurationSeconds > 0 && info.DurationSeconds > r.MaxDurationSeconds {
+		return reject(CodeDurationTooLong, "%.2f s is longer than %.2f s", info.DurationSeconds, r.MaxDurationSeconds)
+	}
 	return nil
 }
+
+func joinFormats(formats []AudioFormat) string {
+	s := make([]string, len(formats))
+	for i, f := range formats {
+		s[i] = string(f)
+	}
+	return strings.Join(s, ", ")
+}
+
+// ValidateAudio reads the headers of an audio file and checks them
+// against the rules. Errors are *ValidationError.
+func ValidateAudio(path string, rules Rules) (*AudioInfo, error) {
+	info, err := Probe(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := rules.Validate(info); err != nil {
+		return info, err
+	}
+	return info, nil
+}
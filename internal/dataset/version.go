@@ -0,0 +1,85 @@
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrVersionNotFound = errors.New("dataset version not found")
+	ErrInvalidRef      = errors.New("invalid dataset reference")
+)
+
+// Version is an immutable snapshot of a dataset manifest. Every upload that
+// changes the content, labels or splits of a dataset creates a new one.
+type Version struct {
+	Dataset    string    `json:"dataset"`
+	Version    int       `json:"version"`
+	Ref        string    `json:"ref"`
+	Digest     string    `json:"digest"`
+	Files      int       `json:"files"`
+	TotalBytes int64     `json:"total_bytes"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	// FileList is only filled on request
+	FileList []File `json:"file_list,omitempty"`
+}
+
+// Ref is the reference training requests use to pin a version,
+// "local-audio/<name>@<version>"
+func Ref(name string, version int) string {
+	return fmt.Sprintf("%s@%d", Source(name), version)
+}
+
+// ParseRef splits "local-audio/<name>[@<version>]". The version is 0 when
+// not pinned.
+func ParseRef(ref string) (name string, version int, err error) {
+	name, ok := strings.CutPrefix(ref, "local-audio/")
+	if !ok {
+		return "", 0, fmt.Errorf("%w: only local-audio datasets are supported", ErrInvalidRef)
+	}
+
+	if at := strings.LastIndex(name, "@"); at >= 0 {
+		version, err = strconv.Atoi(name[at+1:])
+		if err != nil || version < 1 {
+			return "", 0, fmt.Errorf("%w: version must be a positive number", ErrInvalidRef)
+		}
+		name = name[:at]
+	}
+
+	if err := ValidateName(name); err != nil {
+		return "", 0, fmt.Errorf("%w: %v", ErrInvalidRef, err)
+	}
+	return name, version, nil
+}
+
+// Digest identifies the content of a manifest: the path, content hash,
+// label and split of every file
+func Digest(files []File) string {
+	sorted := make([]File, len(files))
+	copy(sorted, files)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	h := sha256.New()
+	for _, f := range sorted {
+		fmt.Fprintf(h, "%s\t%s\t%s\t%s\n", f.Path, f.SHA256, f.Label, f.Split)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Snapshot is a dataset reference resolved for a job
+type Snapshot struct {
+	Name string
+	// Version is 0 for a dataset that was never versioned, the job then
+	// reads the live dataset directory
+	Version int
+	Digest  string
+	// Source is what the job records, pinned to the version if any
+	Source string
+}
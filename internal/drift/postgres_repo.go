@@ -0,0 +1,113 @@
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// maxSamples bounds the predictions loaded for one report, the newest win
+const maxSamples = 100000
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) PostgresRepository {
+	return PostgresRepository{db: db}
+}
+
+// Samples returns the successful online predictions of a version in a window
+func (r PostgresRepository) Samples(ctx context.Context, versionID string, from, to time.Time) ([]Sample, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT COALESCE(label, ''), features
+		FROM predictions
+		WHERE model_version_id = $1
+		  AND inference_job_id IS NULL
+		  AND error IS NULL
+		  AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at DESC
+		LIMIT $4
+	`, versionID, from, to, maxSamples)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []Sample
+	for rows.Next() {
+		var (
+			s        Sample
+			features []byte
+		)
+		if err := rows.Scan(&s.Label, &features); err != nil {
+			return nil, err
+		}
+		if features != nil {
+			if err := json.Unmarshal(features, &s.Features); err != nil {
+				return nil, err
+			}
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+func (r PostgresRepository) Insert(ctx context.Context, rep *Report) error {
+	body, err := json.Marshal(rep)
+	if err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(ctx, `
+		INSERT INTO drift_reports
+			(model_name, model_version_id, window_start, window_end, predictions, drifted, report)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`,
+		rep.ModelName,
+		rep.ModelVersionID,
+		rep.WindowStart,
+		rep.WindowEnd,
+		rep.Predictions,
+		rep.Drifted,
+		body,
+	).Scan(&rep.ID, &rep.CreatedAt)
+}
+
+// List returns the latest reports of a model name, newest first
+func (r PostgresRepository) List(ctx context.Context, modelName string, limit int) ([]Report, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, created_at, report
+		FROM drift_reports
+		WHERE model_name = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, modelName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		var (
+			id        int64
+			createdAt time.Time
+			body      []byte
+		)
+		if err := rows.Scan(&id, &createdAt, &body); err != nil {
+			return nil, err
+		}
+
+		var rep Report
+		if err := json.Unmarshal(body, &rep); err != nil {
+			return nil, err
+		}
+		rep.ID, rep.CreatedAt = id, createdAt
+		reports = append(reports, rep)
+	}
+	return reports, rows.Err()
+}
@@ -0,0 +1,189 @@
+package drift
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"audioml/internal/dataset"
+)
+
+// psiEpsilon keeps empty bins from making the PSI infinite
+const psiEpsilon = 1e-4
+
+// Sample is what drift needs from one logged prediction
+type Sample struct {
+	Label    string
+	Features map[string]float64
+}
+
+// FeatureDrift compares one feature of live traffic with the training data
+type FeatureDrift struct {
+	PSI          float64   `json:"psi"`
+	Drifted      bool      `json:"drifted"`
+	LiveCount    int       `json:"live_count"`
+	LiveMean     float64   `json:"live_mean"`
+	BaselineMean float64   `json:"baseline_mean"`
+	Live         []float64 `json:"live_proportions"`
+	Baseline     []float64 `json:"baseline_proportions"`
+}
+
+// ClassDrift compares predicted classes with the class balance of the
+// training data
+type ClassDrift struct {
+	PSI      float64            `json:"psi"`
+	Drifted  bool               `json:"drifted"`
+	Live     map[string]float64 `json:"live"`
+	Baseline map[string]float64 `json:"baseline"`
+}
+
+type Report struct {
+	ID             int64                   `json:"id"`
+	ModelName      string                  `json:"model"`
+	ModelVersionID string                  `json:"model_version_id"`
+	WindowStart    time.Time               `json:"window_start"`
+	WindowEnd      time.Time               `json:"window_end"`
+	Predictions    int                     `json:"predictions"`
+	Threshold      float64                 `json:"threshold"`
+	Drifted        bool                    `json:"drifted"`
+	Features       map[string]FeatureDrift `json:"features"`
+	Classes        *ClassDrift             `json:"classes,omitempty"`
+	// Notes explain what could not be compared
+	Notes     []string  `json:"notes,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DriftedFeatures lists the features and "class" when classes drifted
+func (r *Report) DriftedFeatures() []string {
+	var names []string
+	for name, f := range r.Features {
+		if f.Drifted {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if r.Classes != nil && r.Classes.Drifted {
+		names = append(names, "class")
+	}
+	return names
+}
+
+// Compare computes the population stability index of every baseline
+// feature and of the predicted classes. A PSI above threshold is drift.
+func Compare(r *Report, summary *dataset.Summary, samples []Sample) {
+	r.Predictions = len(samples)
+	r.Features = map[string]FeatureDrift{}
+
+	if summary == nil {
+		r.Notes = append(r.Notes, "no dataset summary recorded for this version")
+		return
+	}
+	if len(samples) == 0 {
+		r.Notes = append(r.Notes, "no predictions in the window")
+		return
+	}
+
+	if len(summary.Features) == 0 {
+		r.Notes = append(r.Notes, "trainer reported no feature statistics")
+	}
+	for name, h := range summary.Features {
+		var values []float64
+		for _, s := range samples {
+			if v, ok := s.Features[name]; ok {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 || len(h.Proportions) == 0 {
+			continue
+		}
+
+		live := h.Bin(values)
+		fd := FeatureDrift{
+			PSI:          psi(h.Proportions, live),
+			LiveCount:    len(values),
+			LiveMean:     mean(values),
+			BaselineMean: h.Mean,
+			Live:         live,
+			Baseline:     h.Proportions,
+		}
+		fd.Drifted = fd.PSI > r.Threshold
+		r.Features[name] = fd
+		r.Drifted = r.Drifted || fd.Drifted
+	}
+
+	if cd := compareClasses(summary, samples); cd != nil {
+		cd.Drifted = cd.PSI > r.Threshold
+		r.Classes = cd
+		r.Drifted = r.Drifted || cd.Drifted
+	}
+}
+
+func compareClasses(summary *dataset.Summary, samples []Sample) *ClassDrift {
+	if summary.Files == 0 {
+		return nil
+	}
+
+	cd := &ClassDrift{Live: map[string]float64{}, Baseline: map[string]float64{}}
+	for label, c := range summary.Classes {
+		if label != dataset.UnlabeledClass {
+			cd.Baseline[label] = float64(c.Files)
+		}
+	}
+	if len(cd.Baseline) == 0 {
+		return nil
+	}
+	normalize(cd.Baseline)
+
+	for _, s := range samples {
+		if s.Label != "" {
+			cd.Live[s.Label]++
+		}
+	}
+	normalize(cd.Live)
+
+	labels := map[string]bool{}
+	for l := range cd.Baseline {
+		labels[l] = true
+	}
+	for l := range cd.Live {
+		labels[l] = true
+	}
+	var expected, actual []float64
+	for l := range labels {
+		expected = append(expected, cd.Baseline[l])
+		actual = append(actual, cd.Live[l])
+	}
+	cd.PSI = psi(expected, actual)
+	return cd
+}
+
+func psi(expected, actual []float64) float64 {
+	var total float64
+	for i := range expected {
+		e := math.Max(expected[i], psiEpsilon)
+		a := math.Max(actual[i], psiEpsilon)
+		total += (a - e) * math.Log(a/e)
+	}
+	return total
+}
+
+func normalize(m map[string]float64) {
+	var sum float64
+	for _, v := range m {
+		sum += v
+	}
+	if sum == 0 {
+		return
+	}
+	for k := range m {
+		m[k] /= sum
+	}
+}
+
+func mean(values []float64) float64 {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,146 @@
+package drift
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ilog "audioml/internal/logger"
+	"audioml/internal/models"
+	inats "audioml/internal/nats"
+
+	natslib "github.com/nats-io/nats.go"
+)
+
+var ErrNoActiveVersion = errors.New("model has no active version")
+
+type Service struct {
+	repo      PostgresRepository
+	models    *models.Service
+	threshold float64
+	window    time.Duration
+
+	// Alerts are only logged when nc is nil
+	nc      *natslib.Conn
+	subject string
+}
+
+func NewService(
+	repo PostgresRepository,
+	modelService *models.Service,
+	threshold float64,
+	window time.Duration,
+	nc *natslib.Conn,
+	subject string,
+) *Service {
+	return &Service{
+		repo:      repo,
+		models:    modelService,
+		threshold: threshold,
+		window:    window,
+		nc:        nc,
+		subject:   subject,
+	}
+}
+
+// Check compares the last window of traffic of the active version of a
+// model with its training data, stores the report and alerts on drift
+func (s *Service) Check(ctx context.Context, modelName string) (*Report, error) {
+	m, err := s.models.GetActive(ctx, modelName)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return nil, ErrNoActiveVersion
+	}
+
+	end := time.Now()
+	rep := &Report{
+		ModelName:      modelName,
+		ModelVersionID: m.ID,
+		WindowStart:    end.Add(-s.window),
+		WindowEnd:      end,
+		Threshold:      s.threshold,
+	}
+
+	samples, err := s.repo.Samples(ctx, m.ID, rep.WindowStart, rep.WindowEnd)
+	if err != nil {
+		return nil, err
+	}
+	Compare(rep, m.DatasetSummary, samples)
+
+	if err := s.repo.Insert(ctx, rep); err != nil {
+		return nil, err
+	}
+
+	if rep.Drifted {
+		s.alert(rep)
+	}
+	return rep, nil
+}
+
+func (s *Service) alert(rep *Report) {
+	drifted := rep.DriftedFeatures()
+	ilog.L.Printf("drift detected for %s (%s): %v", rep.ModelName, rep.ModelVersionID, drifted)
+
+	if s.nc == nil {
+		return
+	}
+	err := inats.PublishDriftAlert(s.nc, s.subject, inats.DriftAlert{
+		ModelName:      rep.ModelName,
+		ModelVersionID: rep.ModelVersionID,
+		ReportID:       rep.ID,
+		Drifted:        drifted,
+		Threshold:      rep.Threshold,
+		WindowStart:    rep.WindowStart,
+		WindowEnd:      rep.WindowEnd,
+	})
+	if err != nil {
+		ilog.L.Printf("publish drift alert for %s: %v", rep.ModelName, err)
+	}
+}
+
+// Reports returns the latest stored reports of a model, newest first
+func (s *Service) Reports(ctx context.Context, modelName string, limit int) ([]Report, error) {
+	return s.repo.List(ctx, modelName, limit)
+}
+
+// Run checks every model with an active version each interval until ctx
+// is done
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkAll(ctx)
+		}
+	}
+}
+
+func (s *Service) checkAll(ctx context.Context) {
+	const page = 200
+	for offset := 0; ; offset += page {
+		summaries, total, err := s.models.ListModels(ctx, page, offset)
+		if err != nil {
+			ilog.L.Printf("drift check: list models: %v", err)
+			return
+		}
+
+		for _, ms := range summaries {
+			if ms.ActiveVersionID == nil {
+				continue
+			}
+			if _, err := s.Check(ctx, ms.Name); err != nil {
+				ilog.L.Printf("drift check of %s: %v", ms.Name, err)
+			}
+		}
+
+		if offset+page >= total {
+			return
+		}
+	}
+}
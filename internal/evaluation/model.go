@@ -0,0 +1,59 @@
+package evaluation
+
+import (
+	"errors"
+	"time"
+
+	"audioml/internal/dataset"
+	"audioml/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrEvaluationNotFound = errors.New("evaluation not found")
+	ErrInvalidEvaluation  = errors.New("invalid evaluation request")
+)
+
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"
+)
+
+// Evaluation is one run of a model version on a held-out dataset. Results
+// are kept apart from the metrics the trainer reported.
+type Evaluation struct {
+	ID             uuid.UUID `json:"id"`
+	Status         Status    `json:"status"`
+	ModelVersionID string    `json:"model_version_id"`
+	ModelName      string    `json:"model"`
+	Version        int       `json:"version"`
+	DatasetSource  string    `json:"dataset"`
+
+	// DatasetFingerprint identifies the exact files evaluated on
+	DatasetFingerprint string                          `json:"dataset_fingerprint,omitempty"`
+	DatasetSummary     *dataset.Summary                `json:"dataset_summary,omitempty"`
+	Samples            int                             `json:"samples"`
+	Metrics            map[string]float64              `json:"metrics,omitempty"`
+	ClassMetrics       map[string]metrics.ClassMetrics `json:"class_metrics,omitempty"`
+	ConfusionMatrix    *metrics.ConfusionMatrix        `json:"confusion_matrix,omitempty"`
+
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Error      *string    `json:"error,omitempty"`
+}
+
+// Comparison ranks the latest completed evaluation of several versions on
+// the same dataset
+type Comparison struct {
+	DatasetSource string `json:"dataset"`
+	Metric        string `json:"metric"`
+	// Consistent is false when the evaluations did not see the same files
+	Consistent  bool         `json:"consistent"`
+	Evaluations []Evaluation `json:"evaluations"`
+}
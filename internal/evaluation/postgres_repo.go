@@ -0,0 +1,205 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"audioml/internal/dataset"
+	"audioml/internal/db"
+	"audioml/internal/trainer"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type PostgresRepo struct{}
+
+func NewPostgresRepo() *PostgresRepo {
+	return &PostgresRepo{}
+}
+
+const evaluationColumns = `
+	e.id,
+	e.status,
+	e.model_version_id,
+	m.name,
+	m.version,
+	e.dataset_source,
+	COALESCE(e.dataset_fingerprint, ''),
+	e.dataset_summary,
+	e.samples,
+	e.metrics,
+	e.class_metrics,
+	e.confusion_matrix,
+	e.created_at,
+	e.started_at,
+	e.finished_at,
+	e.error
+`
+
+func scanEvaluation(row pgx.Row) (*Evaluation, error) {
+	var (
+		e                                       Evaluation
+		summary, metricsJSON, classJSON, cmJSON []byte
+	)
+	err := row.Scan(
+		&e.ID,
+		&e.Status,
+		&e.ModelVersionID,
+		&e.ModelName,
+		&e.Version,
+		&e.DatasetSource,
+		&e.DatasetFingerprint,
+		&summary,
+		&e.Samples,
+		&metricsJSON,
+		&classJSON,
+		&cmJSON,
+		&e.CreatedAt,
+		&e.StartedAt,
+		&e.FinishedAt,
+		&e.Error,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range []struct {
+		data []byte
+		dst  any
+	}{
+		{summary, &e.DatasetSummary},
+		{metricsJSON, &e.Metrics},
+		{classJSON, &e.ClassMetrics},
+		{cmJSON, &e.ConfusionMatrix},
+	} {
+		if f.data == nil {
+			continue
+		}
+		if err := json.Unmarshal(f.data, f.dst); err != nil {
+			return nil, err
+		}
+	}
+	return &e, nil
+}
+
+func (r *PostgresRepo) Create(ctx context.Context, e *Evaluation) error {
+	_, err := db.Pool.Exec(ctx, `
+INSERT INTO evaluations
+(id, status, model_version_id, dataset_source, created_at)
+VALUES ($1, $2, $3, $4, $5)
+`,
+		e.ID,
+		e.Status,
+		e.ModelVersionID,
+		e.DatasetSource,
+		e.CreatedAt,
+	)
+	return err
+}
+
+func (r *PostgresRepo) UpdateStatus(ctx context.Context, id string, status Status, errMsg *string) error {
+	if status == StatusRunning {
+		_, err := db.Pool.Exec(ctx, `UPDATE evaluations SET status=$1, started_at=$2 WHERE id=$3`, status, time.Now(), id)
+		return err
+	}
+
+	if status == StatusCompleted || status == StatusFailed {
+		_, err := db.Pool.Exec(ctx, `UPDATE evaluations SET status=$1, finished_at=$2, error=$3 WHERE id=$4`, status, time.Now(), errMsg, id)
+		return err
+	}
+
+	_, err := db.Pool.Exec(ctx, `UPDATE evaluations SET status=$1 WHERE id=$2`, status, id)
+	return err
+}
+
+func (r *PostgresRepo) SaveResult(ctx context.Context, id, fingerprint string, summary *dataset.Summary, result *trainer.EvalResult) error {
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	metricsJSON, err := json.Marshal(result.Metrics)
+	if err != nil {
+		return err
+	}
+	classJSON, err := json.Marshal(result.ClassMetrics)
+	if err != nil {
+		return err
+	}
+	cmJSON, err := json.Marshal(result.ConfusionMatrix)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Pool.Exec(ctx, `
+UPDATE evaluations SET
+  dataset_fingerprint=$1,
+  dataset_summary=$2,
+  samples=$3,
+  metrics=$4,
+  class_metrics=$5,
+  confusion_matrix=$6
+WHERE id=$7
+`, fingerprint, summaryJSON, result.Samples, metricsJSON, classJSON, cmJSON, id)
+	return err
+}
+
+func (r *PostgresRepo) GetByID(ctx context.Context, id string) (*Evaluation, error) {
+	e, err := scanEvaluation(db.Pool.QueryRow(ctx, `
+SELECT `+evaluationColumns+`
+FROM evaluations e
+JOIN model_versions m ON m.id = e.model_version_id
+WHERE e.id::text = $1
+`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrEvaluationNotFound
+	}
+	return e, err
+}
+
+func (r *PostgresRepo) ListByVersion(ctx context.Context, versionID string) ([]Evaluation, error) {
+	return r.list(ctx, `
+SELECT `+evaluationColumns+`
+FROM evaluations e
+JOIN model_versions m ON m.id = e.model_version_id
+WHERE e.model_version_id::text = $1
+ORDER BY e.created_at DESC
+`, versionID)
+}
+
+// LatestOnDataset returns the latest completed evaluation on a dataset of
+// each selected version. Versions are selected by model name, by ids, or both.
+func (r *PostgresRepo) LatestOnDataset(ctx context.Context, datasetSource, modelName string, versionIDs []string) ([]Evaluation, error) {
+	return r.list(ctx, `
+SELECT * FROM (
+  SELECT DISTINCT ON (e.model_version_id) `+evaluationColumns+`
+  FROM evaluations e
+  JOIN model_versions m ON m.id = e.model_version_id
+  WHERE e.dataset_source = $1
+    AND e.status = 'completed'
+    AND ($2 = '' OR m.name = $2)
+    AND (COALESCE(cardinality($3::text[]), 0) = 0 OR e.model_version_id::text = ANY($3))
+  ORDER BY e.model_version_id, e.finished_at DESC
+) latest
+ORDER BY created_at
+`, datasetSource, modelName, versionIDs)
+}
+
+func (r *PostgresRepo) list(ctx context.Context, query string, args ...any) ([]Evaluation, error) {
+	rows, err := db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	evaluations := []Evaluation{}
+	for rows.Next() {
+		e, err := scanEvaluation(rows)
+		if err != nil {
+			return nil, err
+		}
+		evaluations = append(evaluations, *e)
+	}
+	return evaluations, rows.Err()
+}
@@ -0,0 +1,17 @@
+package evaluation
+
+import (
+	"context"
+
+	"audioml/internal/dataset"
+	"audioml/internal/trainer"
+)
+
+type Repository interface {
+	Create(ctx context.Context, e *Evaluation) error
+	UpdateStatus(ctx context.Context, id string, status Status, err *string) error
+	SaveResult(ctx context.Context, id, fingerprint string, summary *dataset.Summary, result *trainer.EvalResult) error
+	GetByID(ctx context.Context, id string) (*Evaluation, error)
+	ListByVersion(ctx context.Context, versionID string) ([]Evaluation, error)
+	LatestOnDataset(ctx context.Context, datasetSource, modelName string, versionIDs []string) ([]Evaluation, error)
+}
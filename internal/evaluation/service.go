@@ -0,0 +1,210 @@
+package evaluation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"audioml/internal/dataset"
+	"audioml/internal/inference"
+	"audioml/internal/lineage"
+	ilog "audioml/internal/logger"
+	"audioml/internal/models"
+	"audioml/internal/trainer"
+
+	"github.com/google/uuid"
+)
+
+// DefaultMetric ranks versions in comparisons
+const DefaultMetric = "accuracy"
+
+type Service struct {
+	repo         Repository
+	runner       *trainer.PythonRunner
+	modelService *models.Service
+	artifacts    *inference.ArtifactCache
+	lineage      *lineage.Service
+}
+
+func NewService(
+	repo Repository,
+	runner *trainer.PythonRunner,
+	modelService *models.Service,
+	artifactCache *inference.ArtifactCache,
+	lineageService *lineage.Service,
+) *Service {
+	return &Service{
+		repo:         repo,
+		runner:       runner,
+		modelService: modelService,
+		artifacts:    artifactCache,
+		lineage:      lineageService,
+	}
+}
+
+// StartRequest names the version to evaluate and the held-out dataset
+type StartRequest struct {
+	ModelVersionID string
+	DatasetSource  string
+}
+
+func (s *Service) StartJob(ctx context.Context, req StartRequest) (*Evaluation, error) {
+
+	// Same contract as training jobs
+	if !strings.HasPrefix(req.DatasetSource, "local-audio/") || strings.Contains(req.DatasetSource, "..") {
+		return nil, fmt.Errorf("%w: only local-audio datasets are supported", ErrInvalidEvaluation)
+	}
+	if _, err := os.Stat(filepath.Join("datasets", req.DatasetSource)); err != nil {
+		return nil, fmt.Errorf("%w: dataset not found: %s", ErrInvalidEvaluation, req.DatasetSource)
+	}
+
+	m, err := s.modelService.Get(ctx, req.ModelVersionID)
+	if err != nil {
+		return nil, err
+	}
+	if m.ArchivedAt != nil {
+		return nil, models.ErrVersionArchived
+	}
+
+	e := &Evaluation{
+		ID:             uuid.New(),
+		Status:         StatusQueued,
+		ModelVersionID: m.ID,
+		ModelName:      m.Name,
+		Version:        m.Version,
+		DatasetSource:  req.DatasetSource,
+		CreatedAt:      time.Now(),
+	}
+
+	if err := s.repo.Create(ctx, e); err != nil {
+		return nil, err
+	}
+
+	evalNode := lineage.EvaluationNode(e.ID.String(), e.DatasetSource)
+	s.link(ctx, lineage.DatasetNode(e.DatasetSource), evalNode, lineage.RelInputOf)
+	s.link(ctx, lineage.ModelVersionNode(m.ID, m.Name, m.Version), evalNode, lineage.RelEvaluatedBy)
+
+	go s.run(context.Background(), e, m)
+
+	return e, nil
+}
+
+// link records lineage, a missing link must not fail the evaluation
+func (s *Service) link(ctx context.Context, from, to lineage.Node, relation string) {
+	if err := s.lineage.Link(ctx, from, to, relation); err != nil {
+		ilog.L.Printf("lineage %s -[%s]-> %s: %v", from.Key(), relation, to.Key(), err)
+	}
+}
+
+func (s *Service) run(ctx context.Context, e *Evaluation, m *models.ModelVersion) {
+	id := e.ID.String()
+	_ = s.repo.UpdateStatus(ctx, id, StatusRunning, nil)
+
+	fail := func(msg string) {
+		_ = s.repo.UpdateStatus(ctx, id, StatusFailed, &msg)
+	}
+
+	datasetPath := filepath.Join("datasets", e.DatasetSource)
+
+	summary, err := dataset.Summarize(datasetPath)
+	if err != nil {
+		fail("dataset summary failed: " + err.Error())
+		return
+	}
+	fingerprint, err := dataset.Fingerprint(datasetPath)
+	if err != nil {
+		fail("dataset fingerprint failed: " + err.Error())
+		return
+	}
+
+	artifactPath, err := s.artifacts.LocalPath(ctx, m)
+	if err != nil {
+		fail("artifact unavailable: " + err.Error())
+		return
+	}
+	labels, err := s.modelService.ClassLabels(ctx, m)
+	if err != nil {
+		fail("class labels: " + err.Error())
+		return
+	}
+
+	result, err := s.runner.Evaluate(ctx, trainer.EvalRequest{
+		JobID:          id,
+		Dataset:        datasetPath,
+		ModelVersionID: m.ID,
+		ArtifactPath:   artifactPath,
+		Labels:         labels,
+	})
+	if err != nil {
+		fail(err.Error())
+		return
+	}
+
+	if err := s.repo.SaveResult(ctx, id, fingerprint, summary, result); err != nil {
+		fail("saving results failed: " + err.Error())
+		return
+	}
+
+	_ = s.repo.UpdateStatus(ctx, id, StatusCompleted, nil)
+}
+
+func (s *Service) Get(ctx context.Context, id string) (*Evaluation, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// ListByVersion lists the evaluations of a version, newest first
+func (s *Service) ListByVersion(ctx context.Context, versionID string) ([]Evaluation, error) {
+	if _, err := s.modelService.Get(ctx, versionID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListByVersion(ctx, versionID)
+}
+
+// Compare ranks versions by metric on their latest evaluation on a
+// dataset, best first. ascending ranks lower values first, e.g. for loss.
+func (s *Service) Compare(ctx context.Context, datasetSource, modelName string, versionIDs []string, metric string, ascending bool) (*Comparison, error) {
+	if datasetSource == "" {
+		return nil, fmt.Errorf("%w: dataset is required", ErrInvalidEvaluation)
+	}
+	if modelName == "" && len(versionIDs) == 0 {
+		return nil, fmt.Errorf("%w: give a model name or version ids", ErrInvalidEvaluation)
+	}
+	if metric == "" {
+		metric = DefaultMetric
+	}
+
+	evaluations, err := s.repo.LatestOnDataset(ctx, datasetSource, modelName, versionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions without the metric go last
+	sort.SliceStable(evaluations, func(i, j int) bool {
+		a, aok := evaluations[i].Metrics[metric]
+		b, bok := evaluations[j].Metrics[metric]
+		if aok != bok {
+			return aok
+		}
+		if ascending {
+			return a < b
+		}
+		return a > b
+	})
+
+	cmp := &Comparison{
+		DatasetSource: datasetSource,
+		Metric:        metric,
+		Consistent:    true,
+		Evaluations:   evaluations,
+	}
+	for _, e := range evaluations {
+		if e.DatasetFingerprint != evaluations[0].DatasetFingerprint {
+			cmp.Consistent = false
+		}
+	}
+	return cmp, nil
+}
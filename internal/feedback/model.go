@@ -0,0 +1,71 @@
+package feedback
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrPredictionNotFound = errors.New("prediction not found")
+	ErrInvalidFeedback    = errors.New("invalid feedback")
+	ErrDatasetExists      = errors.New("dataset already exists")
+	ErrNothingToExport    = errors.New("no feedback samples to export")
+)
+
+// Feedback is the true label of one prediction, as reported by a user.
+// Submitting again replaces the previous label.
+type Feedback struct {
+	PredictionID   int64     `json:"prediction_id"`
+	ModelVersionID string    `json:"model_version_id"`
+	PredictedLabel string    `json:"predicted_label,omitempty"`
+	TrueLabel      string    `json:"label"`
+	Correct        bool      `json:"correct"`
+	Comment        string    `json:"comment,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// VersionAccuracy is the accuracy of a version on the requests that got
+// feedback. Shadow and canary predictions of a request share its feedback.
+type VersionAccuracy struct {
+	ModelVersionID string  `json:"model_version_id"`
+	Version        int     `json:"version"`
+	Active         bool    `json:"active"`
+	Labeled        int     `json:"labeled"`
+	Correct        int     `json:"correct"`
+	Accuracy       float64 `json:"accuracy"`
+}
+
+// ExportRequest selects the feedback to turn into a dataset
+type ExportRequest struct {
+	ModelName string
+	// ModelVersionID restricts the export to one version when set
+	ModelVersionID string
+	Dataset        string
+	// All exports confirmed predictions too, not only corrections
+	All bool
+}
+
+type ExportResult struct {
+	// Dataset is the source to pass to /training/start
+	Dataset string         `json:"dataset"`
+	Files   int            `json:"files"`
+	Classes map[string]int `json:"classes"`
+	Skipped []Skipped      `json:"skipped,omitempty"`
+}
+
+type Skipped struct {
+	PredictionID int64  `json:"prediction_id"`
+	Reason       string `json:"reason"`
+}
+
+// sample is a labeled prediction input. Exactly one way to read the
+// audio is set: a kept input, uploaded audio or a dataset file.
+type sample struct {
+	PredictionID  int64
+	TrueLabel     string
+	InputPath     string
+	AudioFileID   *int64
+	DatasetSource string
+	Source        string
+}
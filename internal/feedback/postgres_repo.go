@@ -0,0 +1,127 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) PostgresRepository {
+	return PostgresRepository{db: db}
+}
+
+// Upsert records the true label of a prediction
+func (r PostgresRepository) Upsert(ctx context.Context, predictionID int64, label, comment string) (*Feedback, error) {
+	f := Feedback{PredictionID: predictionID, TrueLabel: label, Comment: comment}
+
+	err := r.db.QueryRow(ctx, `
+		WITH upsert AS (
+			INSERT INTO prediction_feedback (prediction_id, true_label, comment)
+			SELECT id, $2, NULLIF($3, '') FROM predictions WHERE id = $1
+			ON CONFLICT (prediction_id) DO UPDATE SET
+				true_label = EXCLUDED.true_label,
+				comment = EXCLUDED.comment,
+				updated_at = now()
+			RETURNING created_at, updated_at
+		)
+		SELECT p.model_version_id, COALESCE(p.label, ''), u.created_at, u.updated_at
+		FROM upsert u, predictions p
+		WHERE p.id = $1
+	`, predictionID, label, comment).Scan(&f.ModelVersionID, &f.PredictedLabel, &f.CreatedAt, &f.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrPredictionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f.Correct = f.PredictedLabel == f.TrueLabel
+	return &f, nil
+}
+
+// Accuracy scores every version of a model name against the feedback of
+// the requests it answered, served or shadowed
+func (r PostgresRepository) Accuracy(ctx context.Context, modelName string) ([]VersionAccuracy, error) {
+	rows, err := r.db.Query(ctx, `
+		WITH labeled AS (
+			SELECT DISTINCT ON (q.id) q.model_version_id, q.label, f.true_label
+			FROM prediction_feedback f
+			JOIN predictions p ON p.id = f.prediction_id
+			JOIN predictions q
+			  ON q.id = p.id
+			  OR (p.request_id IS NOT NULL AND q.request_id = p.request_id)
+			WHERE q.error IS NULL
+			ORDER BY q.id, f.updated_at DESC
+		)
+		SELECT
+			m.id,
+			m.version,
+			m.is_active,
+			count(*),
+			count(*) FILTER (WHERE l.label = l.true_label)
+		FROM labeled l
+		JOIN model_versions m ON m.id = l.model_version_id
+		WHERE m.name = $1
+		GROUP BY m.id, m.version, m.is_active
+		ORDER BY m.version DESC
+	`, modelName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	accuracies := []VersionAccuracy{}
+	for rows.Next() {
+		var a VersionAccuracy
+		if err := rows.Scan(&a.ModelVersionID, &a.Version, &a.Active, &a.Labeled, &a.Correct); err != nil {
+			return nil, err
+		}
+		if a.Labeled > 0 {
+			a.Accuracy = float64(a.Correct) / float64(a.Labeled)
+		}
+		accuracies = append(accuracies, a)
+	}
+	return accuracies, rows.Err()
+}
+
+// samples lists the labeled predictions selected by req
+func (r PostgresRepository) samples(ctx context.Context, req ExportRequest) ([]sample, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			f.prediction_id,
+			f.true_label,
+			COALESCE(p.input_path, ''),
+			p.audio_file_id,
+			COALESCE(j.dataset_source, ''),
+			p.source
+		FROM prediction_feedback f
+		JOIN predictions p ON p.id = f.prediction_id
+		JOIN model_versions m ON m.id = p.model_version_id
+		LEFT JOIN inference_jobs j ON j.id = p.inference_job_id
+		WHERE m.name = $1
+		  AND ($2 = '' OR p.model_version_id::text = $2)
+		  AND ($3 OR p.label IS DISTINCT FROM f.true_label)
+		ORDER BY f.prediction_id
+	`, req.ModelName, req.ModelVersionID, req.All)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var samples []sample
+	for rows.Next() {
+		var s sample
+		err := rows.Scan(&s.PredictionID, &s.TrueLabel, &s.InputPath, &s.AudioFileID, &s.DatasetSource, &s.Source)
+		if err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
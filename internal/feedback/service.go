@@ -0,0 +1,125 @@
+package feedback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"audioml/internal/dataset"
+	"audioml/internal/inference"
+)
+
+type Service struct {
+	repo  PostgresRepository
+	audio *inference.AudioStore
+	// datasetsDir holds local-audio/, the directory training reads from
+	datasetsDir string
+}
+
+func NewService(repo PostgresRepository, audio *inference.AudioStore, datasetsDir string) *Service {
+	return &Service{repo: repo, audio: audio, datasetsDir: datasetsDir}
+}
+
+// Submit records the true label of a prediction
+func (s *Service) Submit(ctx context.Context, predictionID int64, label, comment string) (*Feedback, error) {
+	label = strings.TrimSpace(label)
+	if dataset.ValidateLabel(label) != nil {
+		return nil, fmt.Errorf("%w: label must be 1-64 letters, digits, '_', '-' or '.'", ErrInvalidFeedback)
+	}
+	return s.repo.Upsert(ctx, predictionID, label, comment)
+}
+
+// Accuracy returns the online accuracy of every version of a model
+func (s *Service) Accuracy(ctx context.Context, modelName string) ([]VersionAccuracy, error) {
+	return s.repo.Accuracy(ctx, modelName)
+}
+
+// Export copies the inputs of labeled predictions into a new local-audio
+// dataset, one class folder per true label. The dataset only appears once
+// complete.
+func (s *Service) Export(ctx context.Context, req ExportRequest) (*ExportResult, error) {
+	if dataset.ValidateName(req.Dataset) != nil {
+		return nil, fmt.Errorf("%w: dataset must be 1-64 letters, digits, '_' or '-'", ErrInvalidFeedback)
+	}
+
+	target := filepath.Join(s.datasetsDir, "local-audio", req.Dataset)
+	if _, err := os.Stat(target); err == nil {
+		return nil, ErrDatasetExists
+	}
+
+	samples, err := s.repo.samples(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, ErrNothingToExport
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+	// Hidden, so training never picks up a half written dataset
+	staging, err := os.MkdirTemp(filepath.Dir(target), "."+req.Dataset+"-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	res := &ExportResult{
+		Dataset: "local-audio/" + req.Dataset,
+		Classes: map[string]int{},
+	}
+	for _, smp := range samples {
+		if err := s.exportSample(ctx, staging, smp); err != nil {
+			res.Skipped = append(res.Skipped, Skipped{PredictionID: smp.PredictionID, Reason: err.Error()})
+			continue
+		}
+		res.Files++
+		res.Classes[smp.TrueLabel]++
+	}
+
+	if res.Files == 0 {
+		return nil, fmt.Errorf("%w: the inputs of all %d samples are unavailable", ErrNothingToExport, len(res.Skipped))
+	}
+
+	if err := os.Rename(staging, target); err != nil {
+		if _, statErr := os.Stat(target); statErr == nil {
+			return nil, ErrDatasetExists
+		}
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *Service) exportSample(ctx context.Context, dir string, smp sample) error {
+	var src string
+	switch {
+	case smp.InputPath != "":
+		src = smp.InputPath
+	case smp.AudioFileID != nil:
+		path, cleanup, err := s.audio.Fetch(ctx, *smp.AudioFileID)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		src = path
+	case smp.DatasetSource != "" && smp.Source != "":
+		src = filepath.Join(s.datasetsDir, smp.DatasetSource, filepath.FromSlash(smp.Source))
+	default:
+		return errors.New("input audio was not kept")
+	}
+
+	classDir := filepath.Join(dir, smp.TrueLabel)
+	if err := os.MkdirAll(classDir, 0755); err != nil {
+		return err
+	}
+	dst := filepath.Join(classDir, fmt.Sprintf("%d%s", smp.PredictionID, filepath.Ext(src)))
+	err := dataset.CopyFile(src, dst)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("input audio no longer available")
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"audioml/internal/artifacts"
+	"audioml/internal/db"
+	"audioml/internal/s3"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrAudioNotFound = errors.New("audio file not found")
+
+// AudioStore fetches audio uploaded through /upload to a local temp file
+type AudioStore struct {
+	s3     *s3.MinioClient
+	tmpDir string
+}
+
+func NewAudioStore(client *s3.MinioClient, tmpDir string) *AudioStore {
+	return &AudioStore{s3: client, tmpDir: tmpDir}
+}
+
+// Fetch downloads the audio_files row id. The caller must call cleanup.
+func (a *AudioStore) Fetch(ctx context.Context, id int64) (path string, cleanup func(), err error) {
+	var s3Path, filename string
+	err = db.Pool.QueryRow(
+		ctx,
+		`SELECT s3_path_raw, COALESCE(filename, '') FROM audio_files WHERE id = $1`,
+		id,
+	).Scan(&s3Path, &filename)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, ErrAudioNotFound
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Rows hold either an object name in the raw bucket or a full s3:// URL
+	bucket, key, ok := artifacts.ParseS3URI(s3Path)
+	if !ok {
+		bucket, key = a.s3.Bucket, s3Path
+	}
+
+	obj, _, err := a.s3.OpenObject(ctx, bucket, key)
+	if err != nil {
+		return "", nil, err
+	}
+	defer obj.Close()
+
+	if err := os.MkdirAll(a.tmpDir, 0755); err != nil {
+		return "", nil, err
+	}
+	f, err := os.CreateTemp(a.tmpDir, "audio-*"+filepath.Ext(key))
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+
+	_, err = io.Copy(f, obj)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+
+	return f.Name(), cleanup, nil
+}
@@ -0,0 +1,297 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"audioml/internal/dataset"
+	ilog "audioml/internal/logger"
+	"audioml/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBatchNotFound = errors.New("inference job not found")
+	ErrInvalidBatch  = errors.New("invalid batch request")
+)
+
+type BatchStatus string
+
+const (
+	BatchQueued    BatchStatus = "queued"
+	BatchRunning   BatchStatus = "running"
+	BatchCompleted BatchStatus = "completed"
+	BatchFailed    BatchStatus = "failed"
+)
+
+// BatchJob scores a dataset or a list of uploaded audio files with one
+// model version. Succeeded and Failed are counted from its predictions.
+type BatchJob struct {
+	ID             uuid.UUID   `json:"id"`
+	Status         BatchStatus `json:"status"`
+	ModelVersionID string      `json:"model_version_id"`
+	DatasetSource  string      `json:"dataset,omitempty"`
+	AudioIDs       []int64     `json:"audio_ids,omitempty"`
+	Labels         []string    `json:"labels"`
+	Total          int         `json:"total"`
+	Succeeded      int         `json:"succeeded"`
+	Failed         int         `json:"failed"`
+	Progress       float64     `json:"progress"`
+	CreatedAt      time.Time   `json:"created_at"`
+	StartedAt      *time.Time  `json:"started_at,omitempty"`
+	FinishedAt     *time.Time  `json:"finished_at,omitempty"`
+	Error          *string     `json:"error,omitempty"`
+}
+
+// PredictionRecord is a stored prediction. Failed inputs are stored too,
+// with Error set and no label.
+type PredictionRecord struct {
+	ID             int64              `json:"id"`
+	InferenceJobID *uuid.UUID         `json:"inference_job_id,omitempty"`
+	RequestID      *uuid.UUID         `json:"request_id,omitempty"`
+	Role           string             `json:"role"`
+	ModelVersionID string             `json:"model_version_id"`
+	AudioFileID    *int64             `json:"audio_file_id,omitempty"`
+	Source         string             `json:"source,omitempty"`
+	InputPath      string             `json:"input_path,omitempty"`
+	Label          string             `json:"label,omitempty"`
+	Confidence     float64            `json:"confidence,omitempty"`
+	Probabilities  map[string]float64 `json:"probabilities,omitempty"`
+	Features       map[string]float64 `json:"features,omitempty"`
+	LatencyMs      float64            `json:"latency_ms"`
+	Error          string             `json:"error,omitempty"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+func (p *PredictionRecord) setResult(res *Result) {
+	p.Label = res.Label
+	p.Confidence = res.Confidence
+	p.Probabilities = res.Probabilities
+	p.Features = res.Features
+	p.LatencyMs = res.LatencyMs
+}
+
+// BatchRequest names the model version and either a dataset or audio ids
+type BatchRequest struct {
+	ModelVersionID string
+	DatasetSource  string
+	AudioIDs       []int64
+}
+
+type BatchService struct {
+	svc     *Service
+	repo    PostgresRepository
+	workers int
+}
+
+// NewBatchService runs every batch with at most workers predictions in
+// flight. The predictor decides how many really run in parallel.
+func NewBatchService(svc *Service, workers int) *BatchService {
+	if workers < 1 {
+		workers = 1
+	}
+	return &BatchService{svc: svc, repo: svc.repo, workers: workers}
+}
+
+// batchItem is one input of a batch, uploaded audio or a dataset file
+type batchItem struct {
+	audioID int64
+	source  string
+}
+
+func (b *BatchService) Start(ctx context.Context, req BatchRequest) (*BatchJob, error) {
+	if (req.DatasetSource == "") == (len(req.AudioIDs) == 0) {
+		return nil, fmt.Errorf("%w: give either a dataset or audio ids", ErrInvalidBatch)
+	}
+
+	m, err := b.svc.models.Get(ctx, req.ModelVersionID)
+	if err != nil {
+		return nil, err
+	}
+	if m.ArchivedAt != nil {
+		return nil, models.ErrVersionArchived
+	}
+
+	var items []batchItem
+	if req.DatasetSource != "" {
+		items, err = datasetItems(req.DatasetSource)
+	} else {
+		items, err = audioItems(req.AudioIDs)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := b.svc.models.ClassLabels(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	// The column is NOT NULL, a model without known labels has none
+	if labels == nil {
+		labels = []string{}
+	}
+
+	job := &BatchJob{
+		ID:             uuid.New(),
+		Status:         BatchQueued,
+		ModelVersionID: m.ID,
+		DatasetSource:  req.DatasetSource,
+		Labels:         labels,
+		Total:          len(items),
+		CreatedAt:      time.Now(),
+	}
+	for _, it := range items {
+		if it.audioID != 0 {
+			job.AudioIDs = append(job.AudioIDs, it.audioID)
+		}
+	}
+
+	if err := b.repo.CreateBatch(ctx, job); err != nil {
+		return nil, err
+	}
+
+	go b.run(context.Background(), job, m, items)
+
+	return job, nil
+}
+
+// datasetItems lists the files of a local dataset
+func datasetItems(source string) ([]batchItem, error) {
+	// Same contract as training jobs
+	if !strings.HasPrefix(source, "local-audio/") || strings.Contains(source, "..") {
+		return nil, fmt.Errorf("%w: only local-audio datasets are supported", ErrInvalidBatch)
+	}
+
+	dir := filepath.Join("datasets", source)
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("%w: dataset not found: %s", ErrInvalidBatch, source)
+	}
+
+	var items []batchItem
+	err := dataset.Walk(dir, func(rel string, _ fs.FileInfo) error {
+		items = append(items, batchItem{source: rel})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: dataset %s is empty", ErrInvalidBatch, source)
+	}
+	return items, nil
+}
+
+// audioItems dedupes the ids, keeping their order
+func audioItems(ids []int64) ([]batchItem, error) {
+	seen := make(map[int64]bool, len(ids))
+	items := make([]batchItem, 0, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			return nil, fmt.Errorf("%w: audio ids must be positive", ErrInvalidBatch)
+		}
+		if !seen[id] {
+			seen[id] = true
+			items = append(items, batchItem{audioID: id})
+		}
+	}
+	return items, nil
+}
+
+func (b *BatchService) run(ctx context.Context, job *BatchJob, m *models.ModelVersion, items []batchItem) {
+	_ = b.repo.UpdateBatchStatus(ctx, job.ID, BatchRunning, nil)
+
+	artifactPath, err := b.svc.cache.LocalPath(ctx, m)
+	if err != nil {
+		msg := "artifact unavailable: " + err.Error()
+		_ = b.repo.UpdateBatchStatus(ctx, job.ID, BatchFailed, &msg)
+		return
+	}
+
+	work := make(chan batchItem)
+	var wg sync.WaitGroup
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range work {
+				b.score(ctx, job, m, artifactPath, it)
+			}
+		}()
+	}
+
+	for _, it := range items {
+		work <- it
+	}
+	close(work)
+	wg.Wait()
+
+	_ = b.repo.UpdateBatchStatus(ctx, job.ID, BatchCompleted, nil)
+}
+
+// score predicts one item and stores the outcome, failures included
+func (b *BatchService) score(ctx context.Context, job *BatchJob, m *models.ModelVersion, artifactPath string, it batchItem) {
+	rec := &PredictionRecord{
+		InferenceJobID: &job.ID,
+		Role:           RoleBatch,
+		ModelVersionID: m.ID,
+		Source:         it.source,
+	}
+	if it.audioID != 0 {
+		rec.AudioFileID = &it.audioID
+	}
+
+	res, err := b.scoreItem(ctx, job, m, artifactPath, it)
+	if err != nil {
+		rec.Error = err.Error()
+	} else {
+		rec.setResult(res)
+	}
+
+	if err := b.repo.InsertPrediction(ctx, rec); err != nil {
+		ilog.L.Printf("store prediction of job %s: %v", job.ID, err)
+	}
+}
+
+func (b *BatchService) scoreItem(ctx context.Context, job *BatchJob, m *models.ModelVersion, artifactPath string, it batchItem) (*Result, error) {
+	audioPath := filepath.Join("datasets", job.DatasetSource, filepath.FromSlash(it.source))
+	if it.audioID != 0 {
+		path, cleanup, err := b.svc.audio.Fetch(ctx, it.audioID)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		audioPath = path
+	}
+
+	return b.svc.predict(ctx, m, PredictRequest{
+		ModelVersionID: m.ID,
+		ArtifactPath:   artifactPath,
+		AudioPath:      audioPath,
+		Labels:         job.Labels,
+	})
+}
+
+// Get returns a job with its progress
+func (b *BatchService) Get(ctx context.Context, id string) (*BatchJob, error) {
+	jobID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrBatchNotFound
+	}
+
+	job, err := b.repo.GetBatch(ctx, jobID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Total > 0 {
+		job.Progress = float64(job.Succeeded+job.Failed) / float64(job.Total)
+	}
+	return job, nil
+}
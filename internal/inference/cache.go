@@ -0,0 +1,76 @@
+package inference
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"audioml/internal/artifacts"
+	"audioml/internal/models"
+)
+
+// ArtifactCache gives the predictor a local copy of remote artifacts.
+// Copies are verified against the registry digest once, when downloaded.
+type ArtifactCache struct {
+	store *artifacts.Store
+	dir   string
+	mu    sync.Mutex
+}
+
+func NewArtifactCache(store *artifacts.Store, dir string) *ArtifactCache {
+	return &ArtifactCache{store: store, dir: dir}
+}
+
+// LocalPath returns a local file holding the artifact of m
+func (c *ArtifactCache) LocalPath(ctx context.Context, m *models.ModelVersion) (string, error) {
+	if _, _, remote := artifacts.ParseS3URI(m.ArtifactPath); !remote {
+		return m.ArtifactPath, nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dst := filepath.Join(c.dir, m.ID, filepath.Base(m.ArtifactPath))
+	if info, err := os.Stat(dst); err == nil && (m.ArtifactSize == 0 || info.Size() == m.ArtifactSize) {
+		return dst, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+
+	obj, err := c.store.Open(ctx, m.ArtifactPath)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".download-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), obj)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if m.ArtifactSHA256 != "" && hex.EncodeToString(h.Sum(nil)) != m.ArtifactSHA256 {
+		return "", fmt.Errorf("artifact of %s does not match its recorded digest", m.ID)
+	}
+
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return "", err
+	}
+	return dst, nil
+}
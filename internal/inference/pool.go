@@ -0,0 +1,51 @@
+package inference
+
+import (
+	"context"
+	"errors"
+)
+
+// Pool spreads predictions over several predictors, each used by one
+// caller at a time. Callers wait for a free predictor.
+type Pool struct {
+	all  []Predictor
+	idle chan Predictor
+}
+
+func NewPool(size int, newPredictor func() Predictor) *Pool {
+	if size < 1 {
+		size = 1
+	}
+	p := &Pool{idle: make(chan Predictor, size)}
+	for i := 0; i < size; i++ {
+		pr := newPredictor()
+		p.all = append(p.all, pr)
+		p.idle <- pr
+	}
+	return p
+}
+
+// Size is the number of predictions that can run at the same time
+func (p *Pool) Size() int {
+	return len(p.all)
+}
+
+func (p *Pool) Predict(ctx context.Context, req PredictRequest) (*Prediction, error) {
+	var pr Predictor
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case pr = <-p.idle:
+	}
+	defer func() { p.idle <- pr }()
+
+	return pr.Predict(ctx, req)
+}
+
+func (p *Pool) Close() error {
+	var errs []error
+	for _, pr := range p.all {
+		errs = append(errs, pr.Close())
+	}
+	return errors.Join(errs...)
+}
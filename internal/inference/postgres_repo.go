@@ -0,0 +1,320 @@
+package inference
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) PostgresRepository {
+	return PostgresRepository{db: db}
+}
+
+func (r PostgresRepository) CreateBatch(ctx context.Context, job *BatchJob) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO inference_jobs
+			(id, status, model_version_id, dataset_source, audio_ids, labels, total, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6, $7, $8)
+	`,
+		job.ID,
+		job.Status,
+		job.ModelVersionID,
+		job.DatasetSource,
+		job.AudioIDs,
+		job.Labels,
+		job.Total,
+		job.CreatedAt,
+	)
+	return err
+}
+
+func (r PostgresRepository) UpdateBatchStatus(ctx context.Context, id uuid.UUID, status BatchStatus, errMsg *string) error {
+	switch status {
+	case BatchRunning:
+		_, err := r.db.Exec(ctx, `UPDATE inference_jobs SET status = $1, started_at = now() WHERE id = $2`, status, id)
+		return err
+	case BatchCompleted, BatchFailed:
+		_, err := r.db.Exec(ctx, `UPDATE inference_jobs SET status = $1, finished_at = now(), error = $2 WHERE id = $3`, status, errMsg, id)
+		return err
+	}
+	_, err := r.db.Exec(ctx, `UPDATE inference_jobs SET status = $1 WHERE id = $2`, status, id)
+	return err
+}
+
+func (r PostgresRepository) GetBatch(ctx context.Context, id uuid.UUID) (*BatchJob, error) {
+	var job BatchJob
+	err := r.db.QueryRow(ctx, `
+		SELECT
+			j.id, j.status, j.model_version_id, COALESCE(j.dataset_source, ''),
+			COALESCE(j.audio_ids, '{}'), j.labels, j.total,
+			p.succeeded, p.failed,
+			j.created_at, j.started_at, j.finished_at, j.error
+		FROM inference_jobs j,
+		LATERAL (
+			SELECT
+				count(*) FILTER (WHERE error IS NULL) AS succeeded,
+				count(*) FILTER (WHERE error IS NOT NULL) AS failed
+			FROM predictions
+			WHERE inference_job_id = j.id
+		) p
+		WHERE j.id = $1
+	`, id).Scan(
+		&job.ID,
+		&job.Status,
+		&job.ModelVersionID,
+		&job.DatasetSource,
+		&job.AudioIDs,
+		&job.Labels,
+		&job.Total,
+		&job.Succeeded,
+		&job.Failed,
+		&job.CreatedAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+		&job.Error,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r PostgresRepository) InsertPrediction(ctx context.Context, p *PredictionRecord) error {
+	probs, err := marshalOptional(p.Probabilities)
+	if err != nil {
+		return err
+	}
+	features, err := marshalOptional(p.Features)
+	if err != nil {
+		return err
+	}
+
+	// Duration and sample rate get their own columns for ad-hoc queries
+	var duration, sampleRate *float64
+	if v, ok := p.Features["duration_seconds"]; ok {
+		duration = &v
+	}
+	if v, ok := p.Features["sample_rate"]; ok {
+		sampleRate = &v
+	}
+
+	var confidence *float64
+	if p.Error == "" {
+		confidence = &p.Confidence
+	}
+
+	return r.db.QueryRow(ctx, `
+		INSERT INTO predictions
+			(inference_job_id, request_id, role, model_version_id, audio_file_id, source, label, probabilities, latency_ms, error,
+			 confidence, features, duration_seconds, sample_rate, input_path)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, NULLIF($10, ''), $11, $12, $13, $14::integer, NULLIF($15, ''))
+		RETURNING id, created_at
+	`,
+		p.InferenceJobID,
+		p.RequestID,
+		p.Role,
+		p.ModelVersionID,
+		p.AudioFileID,
+		p.Source,
+		p.Label,
+		probs,
+		p.LatencyMs,
+		p.Error,
+		confidence,
+		features,
+		duration,
+		sampleRate,
+		p.InputPath,
+	).Scan(&p.ID, &p.CreatedAt)
+}
+
+// marshalOptional encodes a JSONB value, nil maps are stored as NULL
+func marshalOptional(v map[string]float64) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+const predictionColumns = `
+	id,
+	inference_job_id,
+	request_id,
+	role,
+	model_version_id,
+	audio_file_id,
+	source,
+	COALESCE(input_path, ''),
+	COALESCE(label, ''),
+	COALESCE(confidence, 0),
+	probabilities,
+	features,
+	COALESCE(latency_ms, 0),
+	COALESCE(error, ''),
+	created_at
+`
+
+func scanPrediction(row pgx.Row) (*PredictionRecord, error) {
+	var (
+		p        PredictionRecord
+		probs    []byte
+		features []byte
+	)
+	err := row.Scan(
+		&p.ID,
+		&p.InferenceJobID,
+		&p.RequestID,
+		&p.Role,
+		&p.ModelVersionID,
+		&p.AudioFileID,
+		&p.Source,
+		&p.InputPath,
+		&p.Label,
+		&p.Confidence,
+		&probs,
+		&features,
+		&p.LatencyMs,
+		&p.Error,
+		&p.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if probs != nil {
+		if err := json.Unmarshal(probs, &p.Probabilities); err != nil {
+			return nil, err
+		}
+	}
+	if features != nil {
+		if err := json.Unmarshal(features, &p.Features); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// EachBatchPrediction streams the predictions of a job in insertion order
+func (r PostgresRepository) EachBatchPrediction(ctx context.Context, jobID uuid.UUID, fn func(*PredictionRecord) error) error {
+	rows, err := r.db.Query(ctx, `
+		SELECT `+predictionColumns+`
+		FROM predictions
+		WHERE inference_job_id = $1
+		ORDER BY id
+	`, jobID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p, err := scanPrediction(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// OnlineStats summarizes the online predictions of a model name since a
+// point in time, per version and role
+func (r PostgresRepository) OnlineStats(ctx context.Context, modelName string, since time.Time) ([]VersionStats, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+			p.model_version_id,
+			p.role,
+			count(*),
+			count(*) FILTER (WHERE p.error IS NOT NULL),
+			COALESCE(avg(p.latency_ms) FILTER (WHERE p.error IS NULL), 0),
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY p.latency_ms) FILTER (WHERE p.error IS NULL), 0),
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY p.latency_ms) FILTER (WHERE p.error IS NULL), 0),
+			COALESCE(
+				(SELECT jsonb_object_agg(label, n) FROM (
+					SELECT label, count(*) AS n
+					FROM predictions l
+					WHERE l.model_version_id = p.model_version_id
+					  AND l.role = p.role
+					  AND l.created_at >= $2
+					  AND l.label IS NOT NULL
+					GROUP BY label
+				) per_label),
+				'{}'
+			)
+		FROM predictions p
+		JOIN model_versions m ON m.id = p.model_version_id
+		WHERE m.name = $1
+		  AND p.inference_job_id IS NULL
+		  AND p.created_at >= $2
+		GROUP BY p.model_version_id, p.role
+		ORDER BY p.role, p.model_version_id
+	`, modelName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []VersionStats
+	for rows.Next() {
+		var (
+			st     VersionStats
+			labels []byte
+		)
+		err := rows.Scan(
+			&st.ModelVersionID,
+			&st.Role,
+			&st.Predictions,
+			&st.Errors,
+			&st.MeanLatencyMs,
+			&st.P50LatencyMs,
+			&st.P95LatencyMs,
+			&labels,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(labels, &st.Labels); err != nil {
+			return nil, err
+		}
+		stats = append(stats, st)
+	}
+	return stats, rows.Err()
+}
+
+// CompareShadow pairs the successful shadow predictions of a version with
+// the successful answer served for the same request
+func (r PostgresRepository) CompareShadow(ctx context.Context, shadowVersionID string, since time.Time) (*ShadowComparison, error) {
+	var cmp ShadowComparison
+	err := r.db.QueryRow(ctx, `
+		SELECT
+			count(*),
+			count(*) FILTER (WHERE s.label = p.label),
+			COALESCE(avg(s.latency_ms - p.latency_ms), 0)
+		FROM predictions s
+		JOIN predictions p
+		  ON p.request_id = s.request_id
+		 AND p.role IN ('primary', 'canary')
+		 AND p.error IS NULL
+		WHERE s.role = 'shadow'
+		  AND s.model_version_id = $1
+		  AND s.created_at >= $2
+		  AND s.error IS NULL
+	`, shadowVersionID, since).Scan(&cmp.Compared, &cmp.Agreed, &cmp.MeanLatencyDeltaMs)
+	if err != nil {
+		return nil, err
+	}
+	return &cmp, nil
+}
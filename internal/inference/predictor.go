@@ -0,0 +1,32 @@
+package inference
+
+import "context"
+
+// Predictor scores audio files with a model artifact
+type Predictor interface {
+	Predict(ctx context.Context, req PredictRequest) (*Prediction, error)
+	Close() error
+}
+
+// PredictRequest names the model and the audio to score, both as local files
+type PredictRequest struct {
+	ModelVersionID string
+	ArtifactPath   string
+	AudioPath      string
+	// Labels are the class labels known to the registry, in model order
+	Labels []string
+}
+
+// Prediction is what a predictor returns for one audio file
+type Prediction struct {
+	Label         string             `json:"label"`
+	Probabilities map[string]float64 `json:"probabilities"`
+	// Features summarize the input audio, e.g. duration_seconds, sample_rate
+	// and rms. They are compared with the training data to detect drift.
+	Features map[string]float64 `json:"features,omitempty"`
+}
+
+// Confidence is the probability of the predicted label
+func (p *Prediction) Confidence() float64 {
+	return p.Probabilities[p.Label]
+}
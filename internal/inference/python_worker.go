@@ -0,0 +1,158 @@
+package inference
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+)
+
+// PythonWorker keeps one Python process alive and talks to it with one
+// JSON object per line on stdin/stdout. Requests are answered in order,
+// so calls are serialized. A worker that dies, misbehaves or outlives a
+// cancelled request is killed and restarted on the next call.
+type PythonWorker struct {
+	PythonBin string
+	Script    string
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	seq    uint64
+}
+
+func NewPythonWorker(pythonBin, script string) *PythonWorker {
+	return &PythonWorker{
+		PythonBin: pythonBin,
+		Script:    script,
+	}
+}
+
+type workerRequest struct {
+	ID             string   `json:"id"`
+	ModelVersionID string   `json:"model_version_id"`
+	ArtifactPath   string   `json:"artifact_path"`
+	AudioPath      string   `json:"audio_path"`
+	Labels         []string `json:"labels,omitempty"`
+}
+
+type workerResponse struct {
+	ID    string `json:"id"`
+	Error string `json:"error,omitempty"`
+	Prediction
+}
+
+func (w *PythonWorker) Predict(ctx context.Context, req PredictRequest) (*Prediction, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.cmd == nil {
+		if err := w.start(); err != nil {
+			return nil, fmt.Errorf("start predictor: %w", err)
+		}
+	}
+
+	w.seq++
+	id := strconv.FormatUint(w.seq, 10)
+
+	line, err := json.Marshal(workerRequest{
+		ID:             id,
+		ModelVersionID: req.ModelVersionID,
+		ArtifactPath:   req.ArtifactPath,
+		AudioPath:      req.AudioPath,
+		Labels:         req.Labels,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.stdin.Write(append(line, '\n')); err != nil {
+		w.stop()
+		return nil, fmt.Errorf("predictor write: %w", err)
+	}
+
+	type readResult struct {
+		line []byte
+		err  error
+	}
+	done := make(chan readResult, 1)
+	stdout := w.stdout
+	go func() {
+		l, err := stdout.ReadBytes('\n')
+		done <- readResult{l, err}
+	}()
+
+	var res readResult
+	select {
+	case <-ctx.Done():
+		// The answer would arrive for a request nobody waits for anymore
+		w.stop()
+		return nil, ctx.Err()
+	case res = <-done:
+	}
+
+	if res.err != nil {
+		w.stop()
+		return nil, fmt.Errorf("predictor read: %w", res.err)
+	}
+
+	var resp workerResponse
+	if err := json.Unmarshal(res.line, &resp); err != nil || resp.ID != id {
+		w.stop()
+		return nil, errors.New("predictor protocol error")
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	return &resp.Prediction, nil
+}
+
+func (w *PythonWorker) start() error {
+	cmd := exec.Command(w.PythonBin, w.Script)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	w.cmd = cmd
+	w.stdin = stdin
+	w.stdout = bufio.NewReader(stdout)
+	return nil
+}
+
+func (w *PythonWorker) stop() {
+	if w.cmd == nil {
+		return
+	}
+	w.stdin.Close()
+	_ = w.cmd.Process.Kill()
+	_ = w.cmd.Wait()
+	w.cmd = nil
+	w.stdin = nil
+	w.stdout = nil
+}
+
+// Close stops the worker process
+func (w *PythonWorker) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stop()
+	return nil
+}
@@ -0,0 +1,84 @@
+package inference
+
+import (
+	"context"
+	"time"
+
+	"audioml/internal/models"
+)
+
+// VersionStats summarizes the online predictions of one version in a role
+type VersionStats struct {
+	ModelVersionID string         `json:"model_version_id"`
+	Role           string         `json:"role"`
+	Predictions    int            `json:"predictions"`
+	Errors         int            `json:"errors"`
+	MeanLatencyMs  float64        `json:"mean_latency_ms"`
+	P50LatencyMs   float64        `json:"p50_latency_ms"`
+	P95LatencyMs   float64        `json:"p95_latency_ms"`
+	Labels         map[string]int `json:"labels"`
+}
+
+// ShadowComparison pairs shadow predictions with the answer served for
+// the same request
+type ShadowComparison struct {
+	Compared           int     `json:"compared"`
+	Agreed             int     `json:"agreed"`
+	Agreement          float64 `json:"agreement"`
+	MeanLatencyDeltaMs float64 `json:"mean_latency_delta_ms"`
+}
+
+// DeploymentReport compares the candidate of a deployment with the active
+// version over the traffic seen since the candidate was deployed
+type DeploymentReport struct {
+	ModelName          string                `json:"model"`
+	Mode               models.DeploymentMode `json:"mode"`
+	Percent            int                   `json:"percent"`
+	CandidateVersionID string                `json:"candidate_version_id"`
+	ActiveVersionID    string                `json:"active_version_id,omitempty"`
+	Since              time.Time             `json:"since"`
+	Versions           []VersionStats        `json:"versions"`
+	Shadow             *ShadowComparison     `json:"shadow,omitempty"`
+}
+
+// DeploymentReport builds the comparison report of the deployment of a model
+func (s *Service) DeploymentReport(ctx context.Context, modelName string) (*DeploymentReport, error) {
+	d, err := s.models.GetDeployment(ctx, modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DeploymentReport{
+		ModelName:          modelName,
+		Mode:               d.Mode,
+		Percent:            d.Percent,
+		CandidateVersionID: d.CandidateVersionID,
+		Since:              d.CreatedAt,
+	}
+
+	active, err := s.models.GetActive(ctx, modelName)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		report.ActiveVersionID = active.ID
+	}
+
+	report.Versions, err = s.repo.OnlineStats(ctx, modelName, d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Mode == models.DeploymentShadow {
+		cmp, err := s.repo.CompareShadow(ctx, d.CandidateVersionID, d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if cmp.Compared > 0 {
+			cmp.Agreement = float64(cmp.Agreed) / float64(cmp.Compared)
+		}
+		report.Shadow = cmp
+	}
+
+	return report, nil
+}
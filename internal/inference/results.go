@@ -0,0 +1,72 @@
+package inference
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Result download formats
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// WriteResults streams the predictions of a job as CSV or JSON lines.
+// CSV rows carry one probability column per label of the job.
+func (b *BatchService) WriteResults(ctx context.Context, job *BatchJob, format string, w io.Writer) error {
+	switch format {
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		return b.repo.EachBatchPrediction(ctx, job.ID, func(p *PredictionRecord) error {
+			return enc.Encode(p)
+		})
+
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		header := []string{"prediction_id", "audio_file_id", "source", "label", "confidence"}
+		for _, l := range job.Labels {
+			header = append(header, "p_"+l)
+		}
+		header = append(header, "latency_ms", "error")
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+
+		err := b.repo.EachBatchPrediction(ctx, job.ID, func(p *PredictionRecord) error {
+			audioID := ""
+			if p.AudioFileID != nil {
+				audioID = strconv.FormatInt(*p.AudioFileID, 10)
+			}
+			confidence := ""
+			if p.Error == "" {
+				confidence = formatFloat(p.Probabilities[p.Label])
+			}
+
+			row := []string{strconv.FormatInt(p.ID, 10), audioID, p.Source, p.Label, confidence}
+			for _, l := range job.Labels {
+				v := ""
+				if prob, ok := p.Probabilities[l]; ok {
+					v = formatFloat(prob)
+				}
+				row = append(row, v)
+			}
+			row = append(row, formatFloat(p.LatencyMs), p.Error)
+			return cw.Write(row)
+		})
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	return fmt.Errorf("%w: unknown format %q", ErrInvalidBatch, format)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,361 @@
+package inference
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"audioml/internal/dataset"
+	ilog "audioml/internal/logger"
+	"audioml/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var ErrNoActiveModel = errors.New("model has no active version")
+
+// Roles of a stored prediction
+const (
+	RolePrimary = "primary"
+	RoleCanary  = "canary"
+	RoleShadow  = "shadow"
+	RoleBatch   = "batch"
+)
+
+const (
+	// maxShadowInFlight bounds mirrored predictions, extra ones are dropped
+	// rather than slowing down the served traffic
+	maxShadowInFlight = 4
+	shadowTimeout     = time.Minute
+)
+
+type Service struct {
+	models    *models.Service
+	predictor Predictor
+	cache     *ArtifactCache
+	audio     *AudioStore
+	repo      PostgresRepository
+	// inputsDir keeps audio sent inline so feedback can turn it into
+	// training data later. Empty disables it.
+	inputsDir string
+
+	shadowSlots chan struct{}
+}
+
+func NewService(
+	modelService *models.Service,
+	predictor Predictor,
+	cache *ArtifactCache,
+	audio *AudioStore,
+	repo PostgresRepository,
+	inputsDir string,
+) *Service {
+	return &Service{
+		models:      modelService,
+		predictor:   predictor,
+		cache:       cache,
+		audio:       audio,
+		repo:        repo,
+		inputsDir:   inputsDir,
+		shadowSlots: make(chan struct{}, maxShadowInFlight),
+	}
+}
+
+// Input is the audio to score: a local file or an uploaded audio id
+type Input struct {
+	AudioPath string
+	AudioID   int64
+}
+
+// onlineRequest identifies one predict call across the predictions it logs
+type onlineRequest struct {
+	id        uuid.UUID
+	audioID   int64
+	inputPath string
+}
+
+// Result is a prediction along with the version that produced it
+type Result struct {
+	PredictionID   int64              `json:"prediction_id"`
+	RequestID      uuid.UUID          `json:"request_id"`
+	Role           string             `json:"role"`
+	ModelName      string             `json:"model"`
+	ModelVersionID string             `json:"model_version_id"`
+	Version        int                `json:"version"`
+	Label          string             `json:"label"`
+	Confidence     float64            `json:"confidence"`
+	Probabilities  map[string]float64 `json:"probabilities"`
+	Features       map[string]float64 `json:"features,omitempty"`
+	LatencyMs      float64            `json:"latency_ms"`
+}
+
+// Predict scores the input with the active version of the model name, or
+// with the candidate of its deployment for the share of canary traffic.
+// Every prediction is logged under the same request id.
+func (s *Service) Predict(ctx context.Context, modelName string, in Input) (*Result, error) {
+	active, err := s.models.GetActive(ctx, modelName)
+	if err != nil {
+		return nil, err
+	}
+	if active == nil {
+		return nil, ErrNoActiveModel
+	}
+
+	candidate, mode, err := s.route(ctx, modelName)
+	if err != nil {
+		return nil, err
+	}
+
+	audioPath := in.AudioPath
+	if audioPath == "" {
+		path, cleanup, err := s.audio.Fetch(ctx, in.AudioID)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		audioPath = path
+	}
+
+	req := onlineRequest{id: uuid.New(), audioID: in.AudioID}
+	if in.AudioPath != "" {
+		req.inputPath = s.retain(in.AudioPath, req.id)
+	}
+
+	if candidate != nil && mode == models.DeploymentShadow {
+		s.mirror(candidate, req, audioPath)
+	}
+
+	if candidate != nil && mode == models.DeploymentCanary {
+		res, err := s.predictWith(ctx, candidate, audioPath)
+		s.record(ctx, req, RoleCanary, candidate, res, err)
+		if err == nil {
+			return res, nil
+		}
+		// A broken canary must not fail the request, the error is logged
+		// and the active version answers instead
+	}
+
+	res, err := s.predictWith(ctx, active, audioPath)
+	s.record(ctx, req, RolePrimary, active, res, err)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// retain keeps a copy of inline audio under inputsDir and returns its
+// path, or "" when inputs are not kept
+func (s *Service) retain(audioPath string, requestID uuid.UUID) string {
+	if s.inputsDir == "" {
+		return ""
+	}
+
+	dst := filepath.Join(s.inputsDir, requestID.String()+filepath.Ext(audioPath))
+	err := os.MkdirAll(s.inputsDir, 0755)
+	if err == nil {
+		// A hard link is free, copy when the directories are on different devices
+		if err = os.Link(audioPath, dst); err != nil {
+			err = dataset.CopyFile(audioPath, dst)
+		}
+	}
+	if err != nil {
+		ilog.L.Printf("keep input of request %s: %v", requestID, err)
+		return ""
+	}
+	return dst
+}
+
+// RunInputRetention prunes the kept inputs each interval until ctx is
+// done: inputs older than maxAge are removed, then the oldest ones until
+// the rest take at most maxBytes. Zero disables a limit. Feedback on a
+// pruned input can no longer be exported.
+func (s *Service) RunInputRetention(ctx context.Context, interval, maxAge time.Duration, maxBytes int64) {
+	if s.inputsDir == "" {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.pruneInputs(time.Now().Add(-maxAge), maxAge > 0, maxBytes); err != nil {
+				ilog.L.Printf("prune kept inputs: %v", err)
+			}
+		}
+	}
+}
+
+func (s *Service) pruneInputs(before time.Time, byAge bool, maxBytes int64) error {
+	entries, err := os.ReadDir(s.inputsDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	type input struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var kept []input
+	var total int64
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		in := input{filepath.Join(s.inputsDir, e.Name()), info.Size(), info.ModTime()}
+		if byAge && in.modTime.Before(before) {
+			if err := os.Remove(in.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, in)
+		total += in.size
+	}
+
+	if maxBytes <= 0 || total <= maxBytes {
+		return nil
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].modTime.Before(kept[j].modTime) })
+	for _, in := range kept {
+		if total <= maxBytes {
+			break
+		}
+		if err := os.Remove(in.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		total -= in.size
+	}
+	return nil
+}
+
+// route picks the candidate version for this request, if any
+func (s *Service) route(ctx context.Context, modelName string) (*models.ModelVersion, models.DeploymentMode, error) {
+	d, err := s.models.GetDeployment(ctx, modelName)
+	if errors.Is(err, models.ErrNoDeployment) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if rand.IntN(100) >= d.Percent {
+		return nil, "", nil
+	}
+
+	candidate, err := s.models.Get(ctx, d.CandidateVersionID)
+	if err != nil {
+		ilog.L.Printf("deployment of %s: candidate %s: %v", modelName, d.CandidateVersionID, err)
+		return nil, "", nil
+	}
+	return candidate, d.Mode, nil
+}
+
+// mirror scores the audio with a shadow version in the background. The
+// audio is hard linked so the caller can remove its copy right away.
+func (s *Service) mirror(m *models.ModelVersion, req onlineRequest, audioPath string) {
+	select {
+	case s.shadowSlots <- struct{}{}:
+	default:
+		ilog.L.Printf("shadow prediction for request %s dropped: too many in flight", req.id)
+		return
+	}
+
+	shadowPath := audioPath + ".shadow-" + req.id.String()
+	if err := os.Link(audioPath, shadowPath); err != nil {
+		<-s.shadowSlots
+		ilog.L.Printf("shadow prediction for request %s: %v", req.id, err)
+		return
+	}
+
+	go func() {
+		defer func() {
+			os.Remove(shadowPath)
+			<-s.shadowSlots
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+
+		res, err := s.predictWith(ctx, m, shadowPath)
+		s.record(ctx, req, RoleShadow, m, res, err)
+	}()
+}
+
+// record logs an online prediction and stamps res with its ids. A failed
+// insert only costs the log line.
+func (s *Service) record(ctx context.Context, req onlineRequest, role string, m *models.ModelVersion, res *Result, predictErr error) {
+	rec := &PredictionRecord{
+		RequestID:      &req.id,
+		Role:           role,
+		ModelVersionID: m.ID,
+		InputPath:      req.inputPath,
+	}
+	if req.audioID != 0 {
+		rec.AudioFileID = &req.audioID
+	}
+	if predictErr != nil {
+		rec.Error = predictErr.Error()
+	} else {
+		rec.setResult(res)
+		res.RequestID, res.Role = req.id, role
+	}
+
+	if err := s.repo.InsertPrediction(ctx, rec); err != nil {
+		ilog.L.Printf("store prediction of request %s: %v", req.id, err)
+		return
+	}
+	if res != nil {
+		res.PredictionID = rec.ID
+	}
+}
+
+func (s *Service) predictWith(ctx context.Context, m *models.ModelVersion, audioPath string) (*Result, error) {
+	artifactPath, err := s.cache.LocalPath(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := s.models.ClassLabels(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.predict(ctx, m, PredictRequest{
+		ModelVersionID: m.ID,
+		ArtifactPath:   artifactPath,
+		AudioPath:      audioPath,
+		Labels:         labels,
+	})
+}
+
+func (s *Service) predict(ctx context.Context, m *models.ModelVersion, req PredictRequest) (*Result, error) {
+	start := time.Now()
+	p, err := s.predictor.Predict(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		ModelName:      m.Name,
+		ModelVersionID: m.ID,
+		Version:        m.Version,
+		Label:          p.Label,
+		Confidence:     p.Confidence(),
+		Probabilities:  p.Probabilities,
+		Features:       p.Features,
+		LatencyMs:      float64(time.Since(start).Microseconds()) / 1000,
+	}, nil
+}
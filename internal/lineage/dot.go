@@ -0,0 +1,34 @@
+package lineage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DOT renders the graph in Graphviz format
+func (g *Graph) DOT() string {
+	var b strings.Builder
+
+	b.WriteString("digraph lineage {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+
+	for _, n := range g.Nodes {
+		label := n.Label
+		if label == "" {
+			label = n.ID
+		}
+		attrs := fmt.Sprintf("label=%q", string(n.Type)+"\n"+label)
+		if n.NodeRef == g.Root {
+			attrs += ", style=bold"
+		}
+		fmt.Fprintf(&b, "  %q [%s];\n", n.Key(), attrs)
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %q -> %q [label=%q];\n", e.From.Key(), e.To.Key(), e.Relation)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package lineage
+
+import (
+	"fmt"
+	"time"
+)
+
+type NodeType string
+
+const (
+	NodeAudioFile       NodeType = "audio_file"
+	NodeDataset         NodeType = "dataset"
+	NodeDatasetSnapshot NodeType = "dataset_snapshot"
+	NodeTrainingJob     NodeType = "training_job"
+	NodeModelVersion    NodeType = "model_version"
+	NodeEvaluation      NodeType = "evaluation"
+)
+
+// Relations between nodes, read as "From <relation> To"
+const (
+	RelMemberOf    = "member_of"    // audio file -> dataset
+	RelVersionedAs = "versioned_as" // dataset -> dataset snapshot
+	RelInputOf     = "input_of"     // dataset or snapshot -> training job or evaluation
+	RelBaseOf      = "base_of"      // base model version -> training job
+	RelProduced    = "produced"     // training job -> model version
+	RelEvaluatedBy = "evaluated_by" // model version -> evaluation
+)
+
+// NodeRef identifies a node of any type
+type NodeRef struct {
+	Type NodeType `json:"type"`
+	ID   string   `json:"id"`
+}
+
+// Key is the unique, human readable name of a node
+func (r NodeRef) Key() string {
+	return string(r.Type) + ":" + r.ID
+}
+
+type Node struct {
+	NodeRef
+	Label      string         `json:"label"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+type Edge struct {
+	From      NodeRef   `json:"from"`
+	To        NodeRef   `json:"to"`
+	Relation  string    `json:"relation"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Graph is the part of the lineage reachable from Root
+type Graph struct {
+	Root  NodeRef `json:"root"`
+	Nodes []Node  `json:"nodes"`
+	Edges []Edge  `json:"edges"`
+}
+
+func DatasetNode(source string) Node {
+	return Node{NodeRef: NodeRef{Type: NodeDataset, ID: source}, Label: source}
+}
+
+// DatasetSnapshotNode is an immutable version of a dataset, ref being
+// "local-audio/<name>@<version>"
+func DatasetSnapshotNode(ref, digest string) Node {
+	return Node{
+		NodeRef:    NodeRef{Type: NodeDatasetSnapshot, ID: ref},
+		Label:      ref,
+		Attributes: map[string]any{"digest": digest},
+	}
+}
+
+func AudioFileNode(path string) Node {
+	return Node{NodeRef: NodeRef{Type: NodeAudioFile, ID: path}, Label: path}
+}
+
+func TrainingJobNode(id, modelName string) Node {
+	return Node{NodeRef: NodeRef{Type: NodeTrainingJob, ID: id}, Label: modelName}
+}
+
+func EvaluationNode(id, datasetSource string) Node {
+	return Node{NodeRef: NodeRef{Type: NodeEvaluation, ID: id}, Label: "evaluation on " + datasetSource}
+}
+
+func ModelVersionNode(id, name string, version int) Node {
+	return Node{
+		NodeRef:    NodeRef{Type: NodeModelVersion, ID: id},
+		Label:      fmt.Sprintf("%s v%d", name, version),
+		Attributes: map[string]any{"name": name, "version": version},
+	}
+}
@@ -0,0 +1,150 @@
+package lineage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) PostgresRepository {
+	return PostgresRepository{db: db}
+}
+
+// UpsertNode stores a node, refreshing its label and attributes
+func (r *PostgresRepository) UpsertNode(ctx context.Context, n Node) error {
+	if n.Attributes == nil {
+		n.Attributes = map[string]any{}
+	}
+	attrsJSON, err := json.Marshal(n.Attributes)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(
+		ctx,
+		`INSERT INTO lineage_nodes (node_type, node_id, label, attributes)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (node_type, node_id) DO UPDATE SET
+			label = EXCLUDED.label,
+			attributes = lineage_nodes.attributes || EXCLUDED.attributes`,
+		n.Type,
+		n.ID,
+		n.Label,
+		attrsJSON,
+	)
+	return err
+}
+
+// AddEdge links two nodes, recording the same link twice is a no-op
+func (r *PostgresRepository) AddEdge(ctx context.Context, from, to NodeRef, relation string) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO lineage_edges (src_type, src_id, dst_type, dst_id, relation)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT DO NOTHING`,
+		from.Type,
+		from.ID,
+		to.Type,
+		to.ID,
+		relation,
+	)
+	return err
+}
+
+// Walk returns every edge reachable from root within maxDepth hops,
+// following edges backwards (upstream) or forwards (downstream)
+func (r *PostgresRepository) Walk(ctx context.Context, root NodeRef, upstream bool, maxDepth int) ([]Edge, error) {
+	// near is the side of an edge already in the walk, far the side to expand
+	near, far := "src", "dst"
+	if upstream {
+		near, far = "dst", "src"
+	}
+
+	query := fmt.Sprintf(`
+		WITH RECURSIVE walk AS (
+			SELECT e.id, e.%[2]s_type AS next_type, e.%[2]s_id AS next_id, 1 AS depth
+			FROM lineage_edges e
+			WHERE e.%[1]s_type = $1 AND e.%[1]s_id = $2
+			UNION
+			SELECT e.id, e.%[2]s_type, e.%[2]s_id, w.depth + 1
+			FROM lineage_edges e
+			JOIN walk w ON e.%[1]s_type = w.next_type AND e.%[1]s_id = w.next_id
+			WHERE w.depth < $3
+		)
+		SELECT e.src_type, e.src_id, e.dst_type, e.dst_id, e.relation, e.created_at
+		FROM lineage_edges e
+		WHERE e.id IN (SELECT id FROM walk)
+		ORDER BY e.id
+	`, near, far)
+
+	rows, err := r.db.Query(ctx, query, root.Type, root.ID, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var edges []Edge
+
+	for rows.Next() {
+		var e Edge
+		err := rows.Scan(
+			&e.From.Type,
+			&e.From.ID,
+			&e.To.Type,
+			&e.To.ID,
+			&e.Relation,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		edges = append(edges, e)
+	}
+
+	return edges, rows.Err()
+}
+
+// GetNodes loads the stored labels and attributes of the given nodes.
+// Nodes that were only ever referenced by an edge are absent from the result.
+func (r *PostgresRepository) GetNodes(ctx context.Context, refs []NodeRef) (map[NodeRef]Node, error) {
+	types := make([]string, len(refs))
+	ids := make([]string, len(refs))
+	for i, ref := range refs {
+		types[i] = string(ref.Type)
+		ids[i] = ref.ID
+	}
+
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT n.node_type, n.node_id, n.label, n.attributes
+		 FROM lineage_nodes n
+		 JOIN unnest($1::text[], $2::text[]) AS want(node_type, node_id)
+		   ON n.node_type = want.node_type AND n.node_id = want.node_id`,
+		types,
+		ids,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	nodes := map[NodeRef]Node{}
+
+	for rows.Next() {
+		var n Node
+		var attrsJSON []byte
+		if err := rows.Scan(&n.Type, &n.ID, &n.Label, &attrsJSON); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(attrsJSON, &n.Attributes)
+		nodes[n.NodeRef] = n
+	}
+
+	return nodes, rows.Err()
+}
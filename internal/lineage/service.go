@@ -0,0 +1,76 @@
+package lineage
+
+import "context"
+
+// DefaultMaxDepth bounds graph walks, lineage chains are short in practice
+const DefaultMaxDepth = 16
+
+type Service struct {
+	repo PostgresRepository
+}
+
+func NewService(repo PostgresRepository) *Service {
+	return &Service{repo: repo}
+}
+
+// Link records "from <relation> to" and makes sure both nodes exist
+func (s *Service) Link(ctx context.Context, from, to Node, relation string) error {
+	if err := s.repo.UpsertNode(ctx, from); err != nil {
+		return err
+	}
+	if err := s.repo.UpsertNode(ctx, to); err != nil {
+		return err
+	}
+	return s.repo.AddEdge(ctx, from.NodeRef, to.NodeRef, relation)
+}
+
+// Upstream returns everything root was derived from
+func (s *Service) Upstream(ctx context.Context, root NodeRef, maxDepth int) (*Graph, error) {
+	return s.graph(ctx, root, true, maxDepth)
+}
+
+// Downstream returns everything derived from root
+func (s *Service) Downstream(ctx context.Context, root NodeRef, maxDepth int) (*Graph, error) {
+	return s.graph(ctx, root, false, maxDepth)
+}
+
+func (s *Service) graph(ctx context.Context, root NodeRef, upstream bool, maxDepth int) (*Graph, error) {
+	if maxDepth < 1 {
+		maxDepth = DefaultMaxDepth
+	}
+
+	edges, err := s.repo.Walk(ctx, root, upstream, maxDepth)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := []NodeRef{root}
+	seen := map[NodeRef]bool{root: true}
+	for _, e := range edges {
+		for _, ref := range []NodeRef{e.From, e.To} {
+			if !seen[ref] {
+				seen[ref] = true
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	stored, err := s.repo.GetNodes(ctx, refs)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Graph{Root: root, Nodes: make([]Node, 0, len(refs)), Edges: edges}
+	if g.Edges == nil {
+		g.Edges = []Edge{}
+	}
+	for _, ref := range refs {
+		n, ok := stored[ref]
+		if !ok {
+			n = Node{NodeRef: ref}
+		}
+		g.Nodes = append(g.Nodes, n)
+	}
+
+	return g, nil
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ClassMetrics are the one-vs-rest scores of a single class
+type ClassMetrics struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"`
+}
+
+// ConfusionMatrix counts predictions per class.
+// Rows are the true labels and columns the predicted ones, both in Labels order.
+type ConfusionMatrix struct {
+	Labels []string `json:"labels"`
+	Matrix [][]int  `json:"matrix"`
+}
+
+// Validate checks that the matrix is square and matches Labels
+func (cm *ConfusionMatrix) Validate() error {
+	n := len(cm.Labels)
+	if len(cm.Matrix) != n {
+		return fmt.Errorf("confusion matrix has %d rows for %d labels", len(cm.Matrix), n)
+	}
+	for i, row := range cm.Matrix {
+		if len(row) != n {
+			return fmt.Errorf("confusion matrix row %d has %d columns for %d labels", i, len(row), n)
+		}
+		for _, v := range row {
+			if v < 0 {
+				return fmt.Errorf("confusion matrix row %d has a negative count", i)
+			}
+		}
+	}
+	return nil
+}
+
+// ClassMetrics derives per-class precision, recall and F1 from the matrix
+func (cm *ConfusionMatrix) ClassMetrics() map[string]ClassMetrics {
+	out := make(map[string]ClassMetrics, len(cm.Labels))
+
+	for i, label := range cm.Labels {
+		tp := cm.Matrix[i][i]
+		support, predicted := 0, 0
+		for j := range cm.Labels {
+			support += cm.Matrix[i][j]
+			predicted += cm.Matrix[j][i]
+		}
+
+		var m ClassMetrics
+		m.Support = support
+		if predicted > 0 {
+			m.Precision = float64(tp) / float64(predicted)
+		}
+		if support > 0 {
+			m.Recall = float64(tp) / float64(support)
+		}
+		if m.Precision+m.Recall > 0 {
+			m.F1 = 2 * m.Precision * m.Recall / (m.Precision + m.Recall)
+		}
+		out[label] = m
+	}
+
+	return out
+}
+
+// Table renders the matrix as a Markdown table, true labels as rows
+func (cm *ConfusionMatrix) Table() string {
+	var b strings.Builder
+
+	b.WriteString("| true \\ predicted |")
+	for _, l := range cm.Labels {
+		b.WriteString(" " + l + " |")
+	}
+	b.WriteString("\n|---|")
+	for range cm.Labels {
+		b.WriteString("---:|")
+	}
+	b.WriteString("\n")
+
+	for i, l := range cm.Labels {
+		b.WriteString("| " + l + " |")
+		for j := range cm.Labels {
+			fmt.Fprintf(&b, " %d |", cm.Matrix[i][j])
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+// ClassTable renders per-class metrics as a Markdown table sorted by label
+func ClassTable(classes map[string]ClassMetrics) string {
+	labels := make([]string, 0, len(classes))
+	for l := range classes {
+		labels = append(labels, l)
+	}
+	sort.Strings(labels)
+
+	var b strings.Builder
+	b.WriteString("| Class | Precision | Recall | F1 | Support |\n|---|---:|---:|---:|---:|\n")
+	for _, l := range labels {
+		m := classes[l]
+		fmt.Fprintf(&b, "| %s | %.3f | %.3f | %.3f | %d |\n", l, m.Precision, m.Recall, m.F1, m.Support)
+	}
+	return b.String()
+}
@@ -0,0 +1,448 @@
+package models
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"audioml/internal/dataset"
+	"audioml/internal/metrics"
+
+	"github.com/google/uuid"
+)
+
+// BundleFormatVersion is bumped whenever the bundle layout changes
+const BundleFormatVersion = 1
+
+var ErrInvalidBundle = errors.New("invalid model bundle")
+
+// Bundle layout
+const (
+	bundleManifest    = "manifest.json"
+	bundleMetrics     = "metrics.json"
+	bundleHyperparams = "hyperparameters.json"
+	bundleCardJSON    = "model_card.json"
+	bundleCardMD      = "model_card.md"
+	bundleChecksums   = "SHA256SUMS"
+	bundleArtifactDir = "artifact/"
+
+	// maxBundleMetaSize bounds the small JSON/Markdown files kept in memory
+	maxBundleMetaSize = 16 << 20
+)
+
+// BundleManifest describes the exported version and where it came from
+type BundleManifest struct {
+	FormatVersion int       `json:"format_version"`
+	ExportedAt    time.Time `json:"exported_at"`
+
+	ModelVersionID string            `json:"model_version_id"`
+	Name           string            `json:"name"`
+	Version        int               `json:"version"`
+	TrainingJobID  string            `json:"training_job_id"`
+	CreatedAt      time.Time         `json:"created_at"`
+	Description    string            `json:"description,omitempty"`
+	Tags           []string          `json:"tags,omitempty"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+
+	ArtifactFile   string `json:"artifact_file"`
+	ArtifactSHA256 string `json:"artifact_sha256"`
+	ArtifactSize   int64  `json:"artifact_size"`
+
+	DatasetSource      string           `json:"dataset_source,omitempty"`
+	DatasetSummary     *dataset.Summary `json:"dataset_summary,omitempty"`
+	TrainingStartedAt  *time.Time       `json:"training_started_at,omitempty"`
+	TrainingFinishedAt *time.Time       `json:"training_finished_at,omitempty"`
+
+	// Provenance of the exported version itself, when it was imported too
+	Provenance map[string]any `json:"provenance,omitempty"`
+}
+
+type bundleMetricsFile struct {
+	Metrics         map[string]float64              `json:"metrics"`
+	ClassMetrics    map[string]metrics.ClassMetrics `json:"class_metrics,omitempty"`
+	ConfusionMatrix *metrics.ConfusionMatrix        `json:"confusion_matrix,omitempty"`
+}
+
+// ExportBundle writes a self-contained tar.gz of a version to w: the
+// artifact, a manifest, metrics, hyperparameters, the model card and a
+// SHA256SUMS file covering all of them
+func (s *Service) ExportBundle(ctx context.Context, id string, w io.Writer) error {
+	m, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	obj, err := s.artifacts.Open(ctx, m.ArtifactPath)
+	if err != nil {
+		return fmt.Errorf("open artifact: %w", err)
+	}
+	defer obj.Close()
+
+	if m.ArtifactSize > 0 && obj.Size != m.ArtifactSize {
+		return errors.New("artifact size does not match the registry, refusing to export")
+	}
+
+	card, err := s.card(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	manifest := BundleManifest{
+		FormatVersion:      BundleFormatVersion,
+		ExportedAt:         time.Now().UTC(),
+		ModelVersionID:     m.ID,
+		Name:               m.Name,
+		Version:            m.Version,
+		TrainingJobID:      m.TrainingJobID,
+		CreatedAt:          m.CreatedAt,
+		Description:        m.Description,
+		Tags:               m.Tags,
+		Annotations:        m.Annotations,
+		ArtifactFile:       bundleArtifactDir + obj.Name,
+		ArtifactSHA256:     m.ArtifactSHA256,
+		ArtifactSize:       obj.Size,
+		DatasetSource:      m.DatasetSource,
+		DatasetSummary:     m.DatasetSummary,
+		TrainingStartedAt:  m.TrainingStartedAt,
+		TrainingFinishedAt: m.TrainingFinishedAt,
+		Provenance:         m.Provenance,
+	}
+
+	meta := map[string]any{
+		bundleManifest:    manifest,
+		bundleMetrics:     bundleMetricsFile{m.Metrics, m.ClassMetrics, m.ConfusionMatrix},
+		bundleHyperparams: m.Hyperparams,
+		bundleCardJSON:    card,
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	sums := map[string]string{}
+
+	for _, name := range sortedKeys(meta) {
+		data, err := json.MarshalIndent(meta[name], "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, name, data, sums); err != nil {
+			return err
+		}
+	}
+	if err := writeTarFile(tw, bundleCardMD, []byte(card.Markdown()), sums); err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    manifest.ArtifactFile,
+		Mode:    0644,
+		Size:    obj.Size,
+		ModTime: obj.ModTime,
+	})
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, h), obj); err != nil {
+		return err
+	}
+	sums[manifest.ArtifactFile] = hex.EncodeToString(h.Sum(nil))
+
+	var checksums bytes.Buffer
+	for _, name := range sortedKeys(sums) {
+		fmt.Fprintf(&checksums, "%s  %s\n", sums[name], name)
+	}
+	if err := writeTarFile(tw, bundleChecksums, checksums.Bytes(), nil); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, sums map[string]string) error {
+	err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	if sums != nil {
+		sum := sha256.Sum256(data)
+		sums[name] = hex.EncodeToString(sum[:])
+	}
+	return nil
+}
+
+// ImportBundle validates a bundle produced by ExportBundle and registers it
+// as a new version. name overrides the model name from the manifest and
+// maxArtifactBytes bounds the extracted artifact, 0 for no limit.
+// The original ids, version and timestamps are kept as provenance.
+func (s *Service) ImportBundle(ctx context.Context, r io.Reader, name string, maxArtifactBytes int64) (*ModelVersion, error) {
+	newID := uuid.NewString()
+	stageDir := s.artifacts.LocalDir(path.Join("imports", newID))
+
+	if err := os.MkdirAll(stageDir, 0755); err != nil {
+		return nil, err
+	}
+
+	m, err := s.importBundle(ctx, r, name, newID, stageDir, maxArtifactBytes)
+	if err != nil || s.artifacts.Remote() {
+		_ = os.RemoveAll(stageDir)
+	}
+	return m, err
+}
+
+func (s *Service) importBundle(ctx context.Context, r io.Reader, name, newID, stageDir string, maxArtifactBytes int64) (*ModelVersion, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not gzip compressed: %w", ErrInvalidBundle, err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	actual := map[string]string{}
+	artifactName := ""
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBundle, hdr.Name)
+		}
+		// A repeated entry would replace the one its checksum was taken from
+		if _, seen := actual[hdr.Name]; seen {
+			return nil, fmt.Errorf("%w: duplicate entry %q", ErrInvalidBundle, hdr.Name)
+		}
+
+		h := sha256.New()
+
+		if rest, ok := strings.CutPrefix(hdr.Name, bundleArtifactDir); ok {
+			if artifactName != "" || rest == "" || rest != path.Base(rest) || rest == "." || rest == ".." {
+				return nil, fmt.Errorf("%w: unexpected artifact entry %q", ErrInvalidBundle, hdr.Name)
+			}
+			artifactName = rest
+
+			if maxArtifactBytes > 0 && hdr.Size > maxArtifactBytes {
+				return nil, fmt.Errorf("%w: artifact is larger than %d bytes", ErrInvalidBundle, maxArtifactBytes)
+			}
+
+			f, err := os.Create(filepath.Join(stageDir, rest))
+			if err != nil {
+				return nil, err
+			}
+			src := io.Reader(tr)
+			if maxArtifactBytes > 0 {
+				src = io.LimitReader(tr, maxArtifactBytes+1)
+			}
+			n, err := io.Copy(io.MultiWriter(f, h), src)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return nil, err
+			}
+			if maxArtifactBytes > 0 && n > maxArtifactBytes {
+				return nil, fmt.Errorf("%w: artifact is larger than %d bytes", ErrInvalidBundle, maxArtifactBytes)
+			}
+			actual[hdr.Name] = hex.EncodeToString(h.Sum(nil))
+			continue
+		}
+
+		switch hdr.Name {
+		case bundleManifest, bundleMetrics, bundleHyperparams, bundleCardJSON, bundleCardMD, bundleChecksums:
+		default:
+			return nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBundle, hdr.Name)
+		}
+		if hdr.Size > maxBundleMetaSize {
+			return nil, fmt.Errorf("%w: %s is too large", ErrInvalidBundle, hdr.Name)
+		}
+
+		data, err := io.ReadAll(io.TeeReader(io.LimitReader(tr, maxBundleMetaSize), h))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBundle, err)
+		}
+		files[hdr.Name] = data
+		actual[hdr.Name] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	if err := verifyChecksums(files[bundleChecksums], actual); err != nil {
+		return nil, err
+	}
+
+	var manifest BundleManifest
+	if err := json.Unmarshal(files[bundleManifest], &manifest); err != nil {
+		return nil, fmt.Errorf("%w: manifest: %v", ErrInvalidBundle, err)
+	}
+	if manifest.FormatVersion != BundleFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidBundle, manifest.FormatVersion)
+	}
+	if manifest.ArtifactFile != bundleArtifactDir+artifactName || artifactName == "" {
+		return nil, fmt.Errorf("%w: artifact %q missing", ErrInvalidBundle, manifest.ArtifactFile)
+	}
+	if manifest.ArtifactSHA256 != "" && manifest.ArtifactSHA256 != actual[manifest.ArtifactFile] {
+		return nil, fmt.Errorf("%w: artifact digest does not match the manifest", ErrInvalidBundle)
+	}
+
+	var mf bundleMetricsFile
+	if data, ok := files[bundleMetrics]; ok {
+		if err := json.Unmarshal(data, &mf); err != nil {
+			return nil, fmt.Errorf("%w: metrics: %v", ErrInvalidBundle, err)
+		}
+	}
+	if mf.ConfusionMatrix != nil {
+		if err := mf.ConfusionMatrix.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+	}
+
+	var hyper map[string]any
+	if data, ok := files[bundleHyperparams]; ok {
+		if err := json.Unmarshal(data, &hyper); err != nil {
+			return nil, fmt.Errorf("%w: hyperparameters: %v", ErrInvalidBundle, err)
+		}
+	}
+
+	if name == "" {
+		name = manifest.Name
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%w: no model name", ErrInvalidBundle)
+	}
+
+	published, err := s.artifacts.Publish(ctx, stageDir, path.Join("imports", newID))
+	if err != nil {
+		return nil, fmt.Errorf("publish artifact: %w", err)
+	}
+	artifact := published[artifactName]
+
+	provenance := map[string]any{
+		"imported_at":             time.Now().UTC(),
+		"source_model_version_id": manifest.ModelVersionID,
+		"source_name":             manifest.Name,
+		"source_version":          manifest.Version,
+		"source_created_at":       manifest.CreatedAt,
+		"source_exported_at":      manifest.ExportedAt,
+		"source_artifact_sha256":  manifest.ArtifactSHA256,
+		"source_training_job_id":  manifest.TrainingJobID,
+		"bundle_format_version":   manifest.FormatVersion,
+	}
+	if manifest.Provenance != nil {
+		provenance["source_provenance"] = manifest.Provenance
+	}
+
+	m := &ModelVersion{
+		ID:            newID,
+		TrainingJobID: manifest.TrainingJobID,
+		Name:          name,
+		Metrics:       mf.Metrics,
+		Hyperparams:   hyper,
+		ArtifactPath:  artifact.URI,
+		Description:   manifest.Description,
+		Tags:          manifest.Tags,
+		Annotations:   manifest.Annotations,
+
+		ArtifactSHA256: artifact.SHA256,
+		ArtifactSize:   artifact.Size,
+
+		DatasetSource:      manifest.DatasetSource,
+		DatasetSummary:     manifest.DatasetSummary,
+		TrainingStartedAt:  manifest.TrainingStartedAt,
+		TrainingFinishedAt: manifest.TrainingFinishedAt,
+
+		ClassMetrics:    mf.ClassMetrics,
+		ConfusionMatrix: mf.ConfusionMatrix,
+
+		Provenance: provenance,
+	}
+
+	if err := s.create(ctx, m); err != nil {
+		if rmErr := s.artifacts.Remove(ctx, artifact.URI); rmErr != nil {
+			err = errors.Join(err, rmErr)
+		}
+		return nil, err
+	}
+
+	if err := s.repo.RecordAudit(ctx, m.Name, &m.ID, "import", provenance); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// verifyChecksums checks a sha256sum style listing against the hashes of
+// the entries read. Every entry but the listing itself must be covered.
+func verifyChecksums(listing []byte, actual map[string]string) error {
+	if listing == nil {
+		return fmt.Errorf("%w: %s missing", ErrInvalidBundle, bundleChecksums)
+	}
+
+	expected := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(listing))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			return fmt.Errorf("%w: malformed %s line %q", ErrInvalidBundle, bundleChecksums, line)
+		}
+		expected[name] = sum
+	}
+
+	var missing []string
+	for name, sum := range actual {
+		if name == bundleChecksums {
+			continue
+		}
+		want, ok := expected[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		if want != sum {
+			return fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidBundle, name)
+		}
+		delete(expected, name)
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: no checksum for %s", ErrInvalidBundle, strings.Join(missing, ", "))
+	}
+	if len(expected) > 0 {
+		return fmt.Errorf("%w: %s lists files missing from the bundle", ErrInvalidBundle, bundleChecksums)
+	}
+	if _, ok := actual[bundleManifest]; !ok {
+		return fmt.Errorf("%w: %s missing", ErrInvalidBundle, bundleManifest)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"audioml/internal/dataset"
+	"audioml/internal/metrics"
+)
+
+// ModelCard summarizes a model version for people who did not train it
+type ModelCard struct {
+	ModelVersionID string            `json:"model_version_id"`
+	Name           string            `json:"name"`
+	Version        int               `json:"version"`
+	CreatedAt      time.Time         `json:"created_at"`
+	IntendedUse    string            `json:"intended_use"`
+	TaskType       string            `json:"task_type,omitempty"`
+	Owner          string            `json:"owner,omitempty"`
+	ClassLabels    []string          `json:"class_labels"`
+	Tags           []string          `json:"tags"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+
+	Dataset         *dataset.Summary                `json:"dataset,omitempty"`
+	Metrics         map[string]float64              `json:"metrics"`
+	ClassMetrics    map[string]metrics.ClassMetrics `json:"class_metrics,omitempty"`
+	ConfusionMatrix *metrics.ConfusionMatrix        `json:"confusion_matrix,omitempty"`
+	Hyperparameters map[string]any                  `json:"hyperparameters"`
+	Lineage         CardLineage                     `json:"lineage"`
+	Training        CardTraining                    `json:"training"`
+}
+
+type CardLineage struct {
+	TrainingJobID  string `json:"training_job_id"`
+	DatasetSource  string `json:"dataset_source,omitempty"`
+	ArtifactPath   string `json:"artifact_path"`
+	ArtifactSHA256 string `json:"artifact_sha256,omitempty"`
+}
+
+type CardTraining struct {
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	DurationSeconds float64    `json:"duration_seconds,omitempty"`
+}
+
+// BuildCard assembles the card of a version. rm may be nil for models
+// that never had metadata recorded.
+func BuildCard(m *ModelVersion, rm *RegisteredModel) *ModelCard {
+	card := &ModelCard{
+		ModelVersionID:  m.ID,
+		Name:            m.Name,
+		Version:         m.Version,
+		CreatedAt:       m.CreatedAt,
+		Tags:            m.Tags,
+		Annotations:     m.Annotations,
+		Dataset:         m.DatasetSummary,
+		Metrics:         m.Metrics,
+		ClassMetrics:    m.ClassMetrics,
+		ConfusionMatrix: m.ConfusionMatrix,
+		Hyperparameters: m.Hyperparams,
+		Lineage: CardLineage{
+			TrainingJobID:  m.TrainingJobID,
+			DatasetSource:  m.DatasetSource,
+			ArtifactPath:   m.ArtifactPath,
+			ArtifactSHA256: m.ArtifactSHA256,
+		},
+		Training: CardTraining{
+			StartedAt:  m.TrainingStartedAt,
+			FinishedAt: m.TrainingFinishedAt,
+		},
+	}
+
+	if m.Description != "" {
+		card.IntendedUse = m.Description
+	}
+
+	if rm != nil {
+		if card.IntendedUse == "" {
+			card.IntendedUse = rm.Description
+		}
+		card.TaskType = rm.TaskType
+		card.Owner = rm.Owner
+		card.ClassLabels = rm.ClassLabels
+	}
+
+	// Fall back to the class folders seen in the training data
+	if len(card.ClassLabels) == 0 && m.DatasetSummary != nil {
+		for label := range m.DatasetSummary.Classes {
+			if label != dataset.UnlabeledClass {
+				card.ClassLabels = append(card.ClassLabels, label)
+			}
+		}
+		sort.Strings(card.ClassLabels)
+	}
+
+	if m.TrainingStartedAt != nil && m.TrainingFinishedAt != nil {
+		card.Training.DurationSeconds = m.TrainingFinishedAt.Sub(*m.TrainingStartedAt).Seconds()
+	}
+
+	return card
+}
+
+// Markdown renders the card as a Markdown document
+func (c *ModelCard) Markdown() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Model card: %s v%d\n\n", c.Name, c.Version)
+	fmt.Fprintf(&b, "- **Version id:** `%s`\n", c.ModelVersionID)
+	fmt.Fprintf(&b, "- **Created:** %s\n", c.CreatedAt.Format(time.RFC3339))
+	if c.TaskType != "" {
+		fmt.Fprintf(&b, "- **Task:** %s\n", c.TaskType)
+	}
+	if c.Owner != "" {
+		fmt.Fprintf(&b, "- **Owner:** %s\n", c.Owner)
+	}
+	if len(c.Tags) > 0 {
+		fmt.Fprintf(&b, "- **Tags:** %s\n", strings.Join(c.Tags, ", "))
+	}
+
+	b.WriteString("\n## Intended use\n\n")
+	if c.IntendedUse != "" {
+		b.WriteString(c.IntendedUse + "\n")
+	} else {
+		b.WriteString("_Not documented._\n")
+	}
+
+	b.WriteString("\n## Class labels\n\n")
+	if len(c.ClassLabels) > 0 {
+		for _, l := range c.ClassLabels {
+			fmt.Fprintf(&b, "- %s\n", l)
+		}
+	} else {
+		b.WriteString("_Not documented._\n")
+	}
+
+	b.WriteString("\n## Dataset\n\n")
+	if c.Dataset != nil {
+		// Durations are only known for audio the summary could read,
+		// none is better than a wrong one
+		if c.Dataset.TotalDurationSeconds > 0 {
+			fmt.Fprintf(&b, "%d files, %d bytes, %.1f s of audio.\n\n", c.Dataset.Files, c.Dataset.TotalBytes, c.Dataset.TotalDurationSeconds)
+			b.WriteString("| Class | Files | Duration (s) |\n|---|---:|---:|\n")
+			for _, label := range sortedKeys(c.Dataset.Classes) {
+				cs := c.Dataset.Classes[label]
+				fmt.Fprintf(&b, "| %s | %d | %.1f |\n", label, cs.Files, cs.DurationSeconds)
+			}
+		} else {
+			fmt.Fprintf(&b, "%d files, %d bytes.\n\n", c.Dataset.Files, c.Dataset.TotalBytes)
+			b.WriteString("| Class | Files |\n|---|---:|\n")
+			for _, label := range sortedKeys(c.Dataset.Classes) {
+				fmt.Fprintf(&b, "| %s | %d |\n", label, c.Dataset.Classes[label].Files)
+			}
+		}
+	} else {
+		b.WriteString("_No dataset summary recorded._\n")
+	}
+
+	b.WriteString("\n## Metrics\n\n")
+	writeKeyValueTable(&b, "Metric", c.Metrics)
+	if len(c.ClassMetrics) > 0 {
+		b.WriteString("\n### Per class\n\n")
+		b.WriteString(metrics.ClassTable(c.ClassMetrics))
+	}
+	if c.ConfusionMatrix != nil {
+		b.WriteString("\n### Confusion matrix\n\n")
+		b.WriteString(c.ConfusionMatrix.Table())
+	}
+
+	b.WriteString("\n## Hyperparameters\n\n")
+	writeKeyValueTable(&b, "Parameter", c.Hyperparameters)
+
+	b.WriteString("\n## Lineage\n\n")
+	fmt.Fprintf(&b, "- **Training job:** `%s`\n", c.Lineage.TrainingJobID)
+	if c.Lineage.DatasetSource != "" {
+		fmt.Fprintf(&b, "- **Dataset:** `%s`\n", c.Lineage.DatasetSource)
+	}
+	fmt.Fprintf(&b, "- **Artifact:** `%s`\n", c.Lineage.ArtifactPath)
+	if c.Lineage.ArtifactSHA256 != "" {
+		fmt.Fprintf(&b, "- **SHA-256:** `%s`\n", c.Lineage.ArtifactSHA256)
+	}
+
+	b.WriteString("\n## Training\n\n")
+	if c.Training.DurationSeconds > 0 {
+		fmt.Fprintf(&b, "Trained in %s", time.Duration(c.Training.DurationSeconds*float64(time.Second)).Round(time.Second))
+		fmt.Fprintf(&b, " (%s to %s).\n", c.Training.StartedAt.Format(time.RFC3339), c.Training.FinishedAt.Format(time.RFC3339))
+	} else {
+		b.WriteString("_Training duration not recorded._\n")
+	}
+
+	return b.String()
+}
+
+func writeKeyValueTable[V any](b *strings.Builder, header string, values map[string]V) {
+	if len(values) == 0 {
+		b.WriteString("_None recorded._\n")
+		return
+	}
+	fmt.Fprintf(b, "| %s | Value |\n|---|---|\n", header)
+	for _, k := range sortedKeys(values) {
+		fmt.Fprintf(b, "| %s | %v |\n", k, values[k])
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+type DeploymentMode string
+
+const (
+	// DeploymentCanary answers Percent% of predict requests with the candidate
+	DeploymentCanary DeploymentMode = "canary"
+	// DeploymentShadow also scores Percent% of requests with the candidate,
+	// but always answers with the active version
+	DeploymentShadow DeploymentMode = "shadow"
+)
+
+// Deployment routes part of the predict traffic of a model to a candidate
+// version before it is activated. A model has at most one deployment.
+type Deployment struct {
+	ModelName          string
+	CandidateVersionID string
+	Mode               DeploymentMode
+	Percent            int
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+func (d *Deployment) validate() error {
+	if d.CandidateVersionID == "" {
+		return fmt.Errorf("%w: candidate version is required", ErrInvalidDeployment)
+	}
+	if d.Mode != DeploymentCanary && d.Mode != DeploymentShadow {
+		return fmt.Errorf("%w: mode must be canary or shadow", ErrInvalidDeployment)
+	}
+	if d.Percent < 1 || d.Percent > 100 {
+		return fmt.Errorf("%w: percent must be between 1 and 100", ErrInvalidDeployment)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+package models
+
+import "errors"
+
+var (
+	ErrModelNotFound        = errors.New("model not found")
+	ErrNoPreviousActivation = errors.New("no previous activation to roll back to")
+	ErrActiveVersion        = errors.New("the active version cannot be archived")
+	ErrVersionArchived      = errors.New("model version is archived")
+	ErrNoDeployment         = errors.New("model has no deployment")
+	ErrInvalidDeployment    = errors.New("invalid deployment")
+)
@@ -1,6 +1,13 @@
 package models
 
-import "time"
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"audioml/internal/dataset"
+	"audioml/internal/metrics"
+)
 
 type ModelVersion struct {
 	ID            string
@@ -12,4 +19,155 @@ type ModelVersion struct {
 	ArtifactPath  string
 	IsActive      bool
 	CreatedAt     time.Time
+	Description   string
+	Tags          []string
+	Annotations   map[string]string
+	ArchivedAt    *time.Time
+	// ArtifactSHA256 and ArtifactSize are recorded at registration,
+	// versions registered before digests existed have them empty.
+	ArtifactSHA256 string
+	ArtifactSize   int64
+
+	// Inputs of the training run, used for model cards
+	DatasetSource      string
+	DatasetSummary     *dataset.Summary
+	TrainingStartedAt  *time.Time
+	TrainingFinishedAt *time.Time
+
+	ClassMetrics    map[string]metrics.ClassMetrics
+	ConfusionMatrix *metrics.ConfusionMatrix
+
+	// Provenance is set on imported versions and describes where they came from
+	Provenance map[string]any
+}
+
+// HasTags reports whether the version carries all of the given tags
+func (m ModelVersion) HasTags(tags ...string) bool {
+	for _, want := range tags {
+		found := false
+		for _, t := range m.Tags {
+			if t == normalizeTag(want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// RegisteredModel holds the metadata shared by every version of a model name
+type RegisteredModel struct {
+	Name        string
+	Description string
+	Owner       string
+	TaskType    string
+	ClassLabels []string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// VerifyResult is the outcome of re-hashing a version's artifact
+type VerifyResult struct {
+	ModelVersionID string
+	ArtifactPath   string
+	ExpectedSHA256 string
+	ActualSHA256   string
+	ExpectedSize   int64
+	ActualSize     int64
+	OK             bool
+	Backfilled     bool
+	Error          string
+}
+
+// ModelSummary is one entry of the registry listing
+type ModelSummary struct {
+	Name            string
+	Description     string
+	Owner           string
+	TaskType        string
+	VersionCount    int
+	LatestVersion   *int
+	LatestVersionID *string
+	LatestCreatedAt *time.Time
+	ActiveVersion   *int
+	ActiveVersionID *string
+}
+
+// RegisteredModelUpdate is a partial update, nil fields are left untouched
+type RegisteredModelUpdate struct {
+	Description *string
+	Owner       *string
+	TaskType    *string
+	ClassLabels *[]string
+}
+
+// VersionMetadataUpdate is a partial update of a version's metadata.
+// Tags replaces the whole tag set, AddTags/RemoveTags edit it in place.
+// Annotations are merged key by key, a nil value removes the key.
+type VersionMetadataUpdate struct {
+	Description *string
+	Tags        *[]string
+	AddTags     []string
+	RemoveTags  []string
+	Annotations map[string]*string
+}
+
+// apply merges the update into m
+func (u VersionMetadataUpdate) apply(m *ModelVersion) {
+	if u.Description != nil {
+		m.Description = *u.Description
+	}
+
+	tags := map[string]bool{}
+	if u.Tags != nil {
+		for _, t := range *u.Tags {
+			tags[normalizeTag(t)] = true
+		}
+	} else {
+		for _, t := range m.Tags {
+			tags[t] = true
+		}
+	}
+	for _, t := range u.AddTags {
+		tags[normalizeTag(t)] = true
+	}
+	for _, t := range u.RemoveTags {
+		delete(tags, normalizeTag(t))
+	}
+	delete(tags, "")
+
+	m.Tags = make([]string, 0, len(tags))
+	for t := range tags {
+		m.Tags = append(m.Tags, t)
+	}
+	sort.Strings(m.Tags)
+
+	if m.Annotations == nil {
+		m.Annotations = map[string]string{}
+	}
+	for k, v := range u.Annotations {
+		if v == nil {
+			delete(m.Annotations, k)
+			continue
+		}
+		m.Annotations[k] = *v
+	}
+}
+
+func normalizeTag(t string) string {
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// Activation is one entry of a model's activation history.
+// Entries undone by a rollback keep their row but get RevertedAt set.
+type Activation struct {
+	ID             int64
+	ModelName      string
+	ModelVersionID string
+	PreviousID     *string
+	CreatedAt      time.Time
+	RevertedAt     *time.Time
 }
@@ -2,10 +2,11 @@ package models
 
 import (
 	"context"
-	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,7 +18,85 @@ func NewPostgresRepository(db *pgxpool.Pool) PostgresRepository {
 	return PostgresRepository{db: db}
 }
 
-// Create inserts a new model version
+const modelVersionColumns = `
+	id,
+	training_job_id,
+	name,
+	version,
+	metrics,
+	hyperparameters,
+	artifact_path,
+	is_active,
+	created_at,
+	description,
+	tags,
+	annotations,
+	archived_at,
+	COALESCE(artifact_sha256, ''),
+	COALESCE(artifact_size, 0),
+	COALESCE(dataset_source, ''),
+	dataset_summary,
+	training_started_at,
+	training_finished_at,
+	class_metrics,
+	confusion_matrix,
+	provenance
+`
+
+func scanModelVersion(row pgx.Row) (*ModelVersion, error) {
+	var m ModelVersion
+	var metricsJSON, hyperJSON, annotationsJSON, summaryJSON []byte
+	var classJSON, confusionJSON, provenanceJSON []byte
+
+	err := row.Scan(
+		&m.ID,
+		&m.TrainingJobID,
+		&m.Name,
+		&m.Version,
+		&metricsJSON,
+		&hyperJSON,
+		&m.ArtifactPath,
+		&m.IsActive,
+		&m.CreatedAt,
+		&m.Description,
+		&m.Tags,
+		&annotationsJSON,
+		&m.ArchivedAt,
+		&m.ArtifactSHA256,
+		&m.ArtifactSize,
+		&m.DatasetSource,
+		&summaryJSON,
+		&m.TrainingStartedAt,
+		&m.TrainingFinishedAt,
+		&classJSON,
+		&confusionJSON,
+		&provenanceJSON,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(metricsJSON, &m.Metrics)
+	json.Unmarshal(hyperJSON, &m.Hyperparams)
+	json.Unmarshal(annotationsJSON, &m.Annotations)
+	if len(summaryJSON) > 0 {
+		json.Unmarshal(summaryJSON, &m.DatasetSummary)
+	}
+	if len(classJSON) > 0 {
+		json.Unmarshal(classJSON, &m.ClassMetrics)
+	}
+	if len(confusionJSON) > 0 {
+		json.Unmarshal(confusionJSON, &m.ConfusionMatrix)
+	}
+	if len(provenanceJSON) > 0 {
+		json.Unmarshal(provenanceJSON, &m.Provenance)
+	}
+
+	return &m, nil
+}
+
+// Create inserts a new model version, numbered after the highest version
+// ever used by its name, archived versions included
 func (r *PostgresRepository) Create(ctx context.Context, m *ModelVersion) error {
 	metricsJSON, err := json.Marshal(m.Metrics)
 	if err != nil {
@@ -29,6 +108,44 @@ func (r *PostgresRepository) Create(ctx context.Context, m *ModelVersion) error
 		return err
 	}
 
+	if m.Tags == nil {
+		m.Tags = []string{}
+	}
+	if m.Annotations == nil {
+		m.Annotations = map[string]string{}
+	}
+
+	annotationsJSON, err := json.Marshal(m.Annotations)
+	if err != nil {
+		return err
+	}
+
+	var summaryJSON, classJSON, confusionJSON, provenanceJSON []byte
+	if m.DatasetSummary != nil {
+		summaryJSON, err = json.Marshal(m.DatasetSummary)
+		if err != nil {
+			return err
+		}
+	}
+	if len(m.ClassMetrics) > 0 {
+		classJSON, err = json.Marshal(m.ClassMetrics)
+		if err != nil {
+			return err
+		}
+	}
+	if m.ConfusionMatrix != nil {
+		confusionJSON, err = json.Marshal(m.ConfusionMatrix)
+		if err != nil {
+			return err
+		}
+	}
+	if m.Provenance != nil {
+		provenanceJSON, err = json.Marshal(m.Provenance)
+		if err != nil {
+			return err
+		}
+	}
+
 	query := `
 		INSERT INTO model_versions (
 			id,
@@ -38,11 +155,43 @@ func (r *PostgresRepository) Create(ctx context.Context, m *ModelVersion) error
 			metrics,
 			hyperparameters,
 			artifact_path,
-			is_active
-		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+			is_active,
+			description,
+			tags,
+			annotations,
+			artifact_sha256,
+			artifact_size,
+			dataset_source,
+			dataset_summary,
+			training_started_at,
+			training_finished_at,
+			class_metrics,
+			confusion_matrix,
+			provenance
+		) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,NULLIF($12, ''),$13,NULLIF($14, ''),$15,$16,$17,$18,$19,$20)
+		RETURNING created_at
 	`
 
-	_, err = r.db.Exec(
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Trainings and imports finishing together must not take the same number
+	if err := lockModelName(ctx, tx, m.Name); err != nil {
+		return err
+	}
+	err = tx.QueryRow(
+		ctx,
+		`SELECT COALESCE(MAX(version), 0) + 1 FROM model_versions WHERE name = $1`,
+		m.Name,
+	).Scan(&m.Version)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
 		ctx,
 		query,
 		m.ID,
@@ -53,30 +202,74 @@ func (r *PostgresRepository) Create(ctx context.Context, m *ModelVersion) error
 		hyperJSON,
 		m.ArtifactPath,
 		m.IsActive,
-	)
+		m.Description,
+		m.Tags,
+		annotationsJSON,
+		m.ArtifactSHA256,
+		m.ArtifactSize,
+		m.DatasetSource,
+		summaryJSON,
+		m.TrainingStartedAt,
+		m.TrainingFinishedAt,
+		classJSON,
+		confusionJSON,
+		provenanceJSON,
+	).Scan(&m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
 
-	return err
+// GetByID returns a single model version
+func (r *PostgresRepository) GetByID(ctx context.Context, id string) (*ModelVersion, error) {
+	query := `SELECT ` + modelVersionColumns + ` FROM model_versions WHERE id = $1`
+
+	m, err := scanModelVersion(r.db.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrModelNotFound
+	}
+	return m, err
 }
 
-// ListByName returns all versions of a model
-func (r *PostgresRepository) ListByName(ctx context.Context, name string) ([]ModelVersion, error) {
+// ListByName returns all versions of a model, archived ones only on request
+func (r *PostgresRepository) ListByName(ctx context.Context, name string, includeArchived bool) ([]ModelVersion, error) {
 	query := `
-		SELECT
-			id,
-			training_job_id,
-			name,
-			version,
-			metrics,
-			hyperparameters,
-			artifact_path,
-			is_active,
-			created_at
+		SELECT ` + modelVersionColumns + `
 		FROM model_versions
-		WHERE name = $1
+		WHERE name = $1 AND ($2 OR archived_at IS NULL)
 		ORDER BY version DESC
 	`
 
-	rows, err := r.db.Query(ctx, query, name)
+	rows, err := r.db.Query(ctx, query, name, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var models []ModelVersion
+
+	for rows.Next() {
+		m, err := scanModelVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, *m)
+	}
+
+	return models, rows.Err()
+}
+
+// ListByTags returns every version carrying all of the given tags
+func (r *PostgresRepository) ListByTags(ctx context.Context, tags []string) ([]ModelVersion, error) {
+	query := `
+		SELECT ` + modelVersionColumns + `
+		FROM model_versions
+		WHERE tags @> $1 AND archived_at IS NULL
+		ORDER BY name, version DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, tags)
 	if err != nil {
 		return nil, err
 	}
@@ -85,80 +278,392 @@ func (r *PostgresRepository) ListByName(ctx context.Context, name string) ([]Mod
 	var models []ModelVersion
 
 	for rows.Next() {
-		var m ModelVersion
-		var metricsJSON, hyperJSON []byte
+		m, err := scanModelVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, *m)
+	}
+
+	return models, rows.Err()
+}
+
+// UpdateVersionMetadata applies a partial metadata update to one version
+func (r *PostgresRepository) UpdateVersionMetadata(ctx context.Context, id string, upd VersionMetadataUpdate) (*ModelVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	m, err := scanModelVersion(tx.QueryRow(
+		ctx,
+		`SELECT `+modelVersionColumns+` FROM model_versions WHERE id = $1 FOR UPDATE`,
+		id,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrModelNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	upd.apply(m)
+
+	annotationsJSON, err := json.Marshal(m.Annotations)
+	if err != nil {
+		return nil, err
+	}
 
+	_, err = tx.Exec(
+		ctx,
+		`UPDATE model_versions SET description = $1, tags = $2, annotations = $3 WHERE id = $4`,
+		m.Description,
+		m.Tags,
+		annotationsJSON,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertAudit(ctx, tx, m.Name, &m.ID, "update_metadata", map[string]any{
+		"description": m.Description,
+		"tags":        m.Tags,
+		"annotations": m.Annotations,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+const registeredModelColumns = `
+	name,
+	description,
+	owner,
+	task_type,
+	class_labels,
+	created_at,
+	updated_at
+`
+
+func scanRegisteredModel(row pgx.Row) (*RegisteredModel, error) {
+	var rm RegisteredModel
+	var labelsJSON []byte
+
+	err := row.Scan(
+		&rm.Name,
+		&rm.Description,
+		&rm.Owner,
+		&rm.TaskType,
+		&labelsJSON,
+		&rm.CreatedAt,
+		&rm.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(labelsJSON, &rm.ClassLabels)
+
+	return &rm, nil
+}
+
+// GetRegisteredModel returns the model-level metadata of a name
+func (r *PostgresRepository) GetRegisteredModel(ctx context.Context, name string) (*RegisteredModel, error) {
+	rm, err := scanRegisteredModel(r.db.QueryRow(
+		ctx,
+		`SELECT `+registeredModelColumns+` FROM registered_models WHERE name = $1`,
+		name,
+	))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrModelNotFound
+	}
+	return rm, err
+}
+
+// EnsureRegisteredModel creates an empty metadata record if none exists yet
+func (r *PostgresRepository) EnsureRegisteredModel(ctx context.Context, name string) error {
+	_, err := r.db.Exec(
+		ctx,
+		`INSERT INTO registered_models (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`,
+		name,
+	)
+	return err
+}
+
+// UpdateRegisteredModel upserts the model-level metadata of a name
+func (r *PostgresRepository) UpdateRegisteredModel(ctx context.Context, name string, upd RegisteredModelUpdate) (*RegisteredModel, error) {
+	var labelsJSON []byte
+	if upd.ClassLabels != nil {
+		labels := *upd.ClassLabels
+		if labels == nil {
+			labels = []string{}
+		}
+		b, err := json.Marshal(labels)
+		if err != nil {
+			return nil, err
+		}
+		labelsJSON = b
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rm, err := scanRegisteredModel(tx.QueryRow(
+		ctx,
+		`INSERT INTO registered_models (name, description, owner, task_type, class_labels)
+		 VALUES ($1, COALESCE($2, ''), COALESCE($3, ''), COALESCE($4, ''), COALESCE($5::jsonb, '[]'))
+		 ON CONFLICT (name) DO UPDATE SET
+			description  = COALESCE($2, registered_models.description),
+			owner        = COALESCE($3, registered_models.owner),
+			task_type    = COALESCE($4, registered_models.task_type),
+			class_labels = COALESCE($5::jsonb, registered_models.class_labels),
+			updated_at   = now()
+		 RETURNING `+registeredModelColumns,
+		name,
+		upd.Description,
+		upd.Owner,
+		upd.TaskType,
+		labelsJSON,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertAudit(ctx, tx, name, nil, "update_model", map[string]any{
+		"description":  rm.Description,
+		"owner":        rm.Owner,
+		"task_type":    rm.TaskType,
+		"class_labels": rm.ClassLabels,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return rm, nil
+}
+
+// ListModels returns one summary per model name, ordered by name
+func (r *PostgresRepository) ListModels(ctx context.Context, limit, offset int) ([]ModelSummary, int, error) {
+	var total int
+	err := r.db.QueryRow(ctx, `
+		SELECT count(*) FROM (
+			SELECT name FROM registered_models
+			UNION
+			SELECT name FROM model_versions
+		) names
+	`).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(ctx, `
+		WITH names AS (
+			SELECT name FROM registered_models
+			UNION
+			SELECT name FROM model_versions
+		)
+		SELECT
+			n.name,
+			COALESCE(rm.description, ''),
+			COALESCE(rm.owner, ''),
+			COALESCE(rm.task_type, ''),
+			(SELECT count(*) FROM model_versions c WHERE c.name = n.name AND c.archived_at IS NULL),
+			latest.version,
+			latest.id,
+			latest.created_at,
+			active.version,
+			active.id
+		FROM names n
+		LEFT JOIN registered_models rm ON rm.name = n.name
+		LEFT JOIN LATERAL (
+			SELECT id, version, created_at
+			FROM model_versions
+			WHERE name = n.name AND archived_at IS NULL
+			ORDER BY version DESC
+			LIMIT 1
+		) latest ON true
+		LEFT JOIN LATERAL (
+			SELECT id, version
+			FROM model_versions
+			WHERE name = n.name AND is_active = true
+			LIMIT 1
+		) active ON true
+		ORDER BY n.name
+		LIMIT $1 OFFSET $2
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	summaries := []ModelSummary{}
+
+	for rows.Next() {
+		var s ModelSummary
 		err := rows.Scan(
-			&m.ID,
-			&m.TrainingJobID,
-			&m.Name,
-			&m.Version,
-			&metricsJSON,
-			&hyperJSON,
-			&m.ArtifactPath,
-			&m.IsActive,
-			&m.CreatedAt,
+			&s.Name,
+			&s.Description,
+			&s.Owner,
+			&s.TaskType,
+			&s.VersionCount,
+			&s.LatestVersion,
+			&s.LatestVersionID,
+			&s.LatestCreatedAt,
+			&s.ActiveVersion,
+			&s.ActiveVersionID,
 		)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
+		summaries = append(summaries, s)
+	}
+
+	return summaries, total, rows.Err()
+}
 
-		json.Unmarshal(metricsJSON, &m.Metrics)
-		json.Unmarshal(hyperJSON, &m.Hyperparams)
+// Archive soft-deletes a version. The active version is refused so a
+// model never ends up serving an archived version.
+func (r *PostgresRepository) Archive(ctx context.Context, id string, purgeArtifact bool) (*ModelVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var name string
+	err = tx.QueryRow(ctx, `SELECT name FROM model_versions WHERE id = $1`, id).Scan(&name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrModelNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockModelName(ctx, tx, name); err != nil {
+		return nil, err
+	}
+
+	m, err := scanModelVersion(tx.QueryRow(
+		ctx,
+		`SELECT `+modelVersionColumns+` FROM model_versions WHERE id = $1 FOR UPDATE`,
+		id,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if m.IsActive {
+		return nil, ErrActiveVersion
+	}
+	if m.ArchivedAt != nil {
+		return m, tx.Commit(ctx)
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		`UPDATE model_versions SET archived_at = now() WHERE id = $1 RETURNING archived_at`,
+		id,
+	).Scan(&m.ArchivedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// An archived version cannot take traffic anymore
+	_, err = tx.Exec(ctx, `DELETE FROM model_deployments WHERE candidate_version_id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = insertAudit(ctx, tx, name, &m.ID, "archive", map[string]any{
+		"purge_artifact": purgeArtifact,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ArtifactInUse reports whether a live version other than exceptID
+// still points at the given artifact path
+func (r *PostgresRepository) ArtifactInUse(ctx context.Context, path, exceptID string) (bool, error) {
+	var inUse bool
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM model_versions
+			WHERE artifact_path = $1 AND id <> $2 AND archived_at IS NULL
+		)`,
+		path,
+		exceptID,
+	).Scan(&inUse)
+	return inUse, err
+}
+
+// SetArtifactDigest records the digest of a version registered without one
+func (r *PostgresRepository) SetArtifactDigest(ctx context.Context, id, sha string, size int64) error {
+	_, err := r.db.Exec(
+		ctx,
+		`UPDATE model_versions SET artifact_sha256 = $1, artifact_size = $2 WHERE id = $3`,
+		sha,
+		size,
+		id,
+	)
+	return err
+}
 
-		models = append(models, m)
+// RecordAudit appends an audit entry outside of any other write
+func (r *PostgresRepository) RecordAudit(ctx context.Context, name string, modelID *string, action string, details map[string]any) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
 
-	return models, nil
+	if err := insertAudit(ctx, tx, name, modelID, action, details); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 // GetActive returns the active model for a given name
 func (r *PostgresRepository) GetActive(ctx context.Context, name string) (*ModelVersion, error) {
 	query := `
-		SELECT
-			id,
-			training_job_id,
-			name,
-			version,
-			metrics,
-			hyperparameters,
-			artifact_path,
-			is_active,
-			created_at
+		SELECT ` + modelVersionColumns + `
 		FROM model_versions
 		WHERE name = $1 AND is_active = true
 		LIMIT 1
 	`
 
-	var m ModelVersion
-	var metricsJSON, hyperJSON []byte
-
-	err := r.db.QueryRow(ctx, query, name).Scan(
-		&m.ID,
-		&m.TrainingJobID,
-		&m.Name,
-		&m.Version,
-		&metricsJSON,
-		&hyperJSON,
-		&m.ArtifactPath,
-		&m.IsActive,
-		&m.CreatedAt,
-	)
-
-	if err == sql.ErrNoRows {
+	m, err := scanModelVersion(r.db.QueryRow(ctx, query, name))
+	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
 
-	json.Unmarshal(metricsJSON, &m.Metrics)
-	json.Unmarshal(hyperJSON, &m.Hyperparams)
-
-	return &m, nil
+	return m, nil
 }
 
-// SetActive marks a model active and deactivates others
+// SetActive marks a model active and deactivates others.
+// The switch is appended to the model's activation history.
 func (r *PostgresRepository) SetActive(ctx context.Context, modelID string) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
@@ -168,18 +673,245 @@ func (r *PostgresRepository) SetActive(ctx context.Context, modelID string) erro
 
 	// Find model name
 	var name string
+	var archived bool
 	err = tx.QueryRow(
 		ctx,
-		`SELECT name FROM model_versions WHERE id = $1`,
+		`SELECT name, archived_at IS NOT NULL FROM model_versions WHERE id = $1`,
 		modelID,
-	).Scan(&name)
+	).Scan(&name, &archived)
 
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrModelNotFound
+	}
 	if err != nil {
 		return err
 	}
+	if archived {
+		return ErrVersionArchived
+	}
+
+	if err := lockModelName(ctx, tx, name); err != nil {
+		return err
+	}
+
+	previousID, err := activeVersionID(ctx, tx, name)
+	if err != nil {
+		return err
+	}
+
+	// Re-activating the active version must not grow the history
+	if previousID != nil && *previousID == modelID {
+		return tx.Commit(ctx)
+	}
+
+	if err := switchActive(ctx, tx, name, modelID); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO model_activations (model_name, model_version_id, previous_version_id)
+		 VALUES ($1, $2, $3)`,
+		name,
+		modelID,
+		previousID,
+	)
+	if err != nil {
+		return err
+	}
+
+	err = insertAudit(ctx, tx, name, &modelID, "activate", map[string]any{
+		"previous_version_id": previousID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Rollback re-activates the version that was active `steps` activations ago.
+// The undone history entries are marked reverted so that a later rollback
+// keeps walking backwards instead of bouncing between two versions.
+func (r *PostgresRepository) Rollback(ctx context.Context, name string, steps int) (*ModelVersion, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockModelName(ctx, tx, name); err != nil {
+		return nil, err
+	}
+
+	var exists bool
+	err = tx.QueryRow(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM model_versions WHERE name = $1)`,
+		name,
+	).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrModelNotFound
+	}
+
+	rows, err := tx.Query(
+		ctx,
+		`SELECT id, model_version_id
+		 FROM model_activations
+		 WHERE model_name = $1 AND reverted_at IS NULL
+		 ORDER BY id DESC
+		 LIMIT $2`,
+		name,
+		steps+1,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var entryIDs []int64
+	var versionIDs []string
+
+	for rows.Next() {
+		var entryID int64
+		var versionID string
+		if err := rows.Scan(&entryID, &versionID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		entryIDs = append(entryIDs, entryID)
+		versionIDs = append(versionIDs, versionID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(entryIDs) <= steps {
+		return nil, ErrNoPreviousActivation
+	}
+
+	targetID := versionIDs[steps]
+
+	var archived bool
+	err = tx.QueryRow(
+		ctx,
+		`SELECT archived_at IS NOT NULL FROM model_versions WHERE id = $1`,
+		targetID,
+	).Scan(&archived)
+	if err != nil {
+		return nil, err
+	}
+	if archived {
+		return nil, ErrVersionArchived
+	}
+
+	previousID, err := activeVersionID(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
 
-	// Deactivate all models with same name
 	_, err = tx.Exec(
+		ctx,
+		`UPDATE model_activations SET reverted_at = now() WHERE id = ANY($1)`,
+		entryIDs[:steps],
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := switchActive(ctx, tx, name, targetID); err != nil {
+		return nil, err
+	}
+
+	err = insertAudit(ctx, tx, name, &targetID, "rollback", map[string]any{
+		"previous_version_id": previousID,
+		"steps":               steps,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := scanModelVersion(tx.QueryRow(
+		ctx,
+		`SELECT `+modelVersionColumns+` FROM model_versions WHERE id = $1`,
+		targetID,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// ListActivations returns the activation history of a model, newest first
+func (r *PostgresRepository) ListActivations(ctx context.Context, name string) ([]Activation, error) {
+	rows, err := r.db.Query(
+		ctx,
+		`SELECT id, model_name, model_version_id, previous_version_id, created_at, reverted_at
+		 FROM model_activations
+		 WHERE model_name = $1
+		 ORDER BY id DESC`,
+		name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []Activation
+
+	for rows.Next() {
+		var a Activation
+		err := rows.Scan(
+			&a.ID,
+			&a.ModelName,
+			&a.ModelVersionID,
+			&a.PreviousID,
+			&a.CreatedAt,
+			&a.RevertedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, a)
+	}
+
+	return history, rows.Err()
+}
+
+// lockModelName serializes activation changes of one model name
+func lockModelName(ctx context.Context, tx pgx.Tx, name string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, name)
+	return err
+}
+
+func activeVersionID(ctx context.Context, tx pgx.Tx, name string) (*string, error) {
+	var id string
+	err := tx.QueryRow(
+		ctx,
+		`SELECT id FROM model_versions WHERE name = $1 AND is_active = true LIMIT 1`,
+		name,
+	).Scan(&id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func switchActive(ctx context.Context, tx pgx.Tx, name, modelID string) error {
+	// Deactivate all models with same name
+	_, err := tx.Exec(
 		ctx,
 		`UPDATE model_versions SET is_active = false WHERE name = $1`,
 		name,
@@ -198,9 +930,154 @@ func (r *PostgresRepository) SetActive(ctx context.Context, modelID string) erro
 		return err
 	}
 
-	rows := res.RowsAffected()
-	if rows == 0 {
-		return errors.New("model not found")
+	if res.RowsAffected() == 0 {
+		return ErrModelNotFound
+	}
+
+	// A candidate that becomes active is fully rolled out
+	_, err = tx.Exec(
+		ctx,
+		`DELETE FROM model_deployments WHERE model_name = $1 AND candidate_version_id = $2`,
+		name,
+		modelID,
+	)
+	return err
+}
+
+func insertAudit(ctx context.Context, tx pgx.Tx, name string, modelID *string, action string, details map[string]any) error {
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO model_audit_log (model_name, model_version_id, action, details)
+		 VALUES ($1, $2, $3, $4)`,
+		name,
+		modelID,
+		action,
+		detailsJSON,
+	)
+	return err
+}
+
+// GetDeployment returns the deployment of a model name
+func (r *PostgresRepository) GetDeployment(ctx context.Context, name string) (*Deployment, error) {
+	var d Deployment
+	err := r.db.QueryRow(
+		ctx,
+		`SELECT model_name, candidate_version_id, mode, percent, created_at, updated_at
+		 FROM model_deployments WHERE model_name = $1`,
+		name,
+	).Scan(&d.ModelName, &d.CandidateVersionID, &d.Mode, &d.Percent, &d.CreatedAt, &d.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNoDeployment
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// SetDeployment creates or replaces the deployment of d.ModelName. The
+// candidate must be a live, inactive version of that model.
+func (r *PostgresRepository) SetDeployment(ctx context.Context, d *Deployment) error {
+	if err := d.validate(); err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := lockModelName(ctx, tx, d.ModelName); err != nil {
+		return err
+	}
+
+	var (
+		name     string
+		active   bool
+		archived bool
+	)
+	err = tx.QueryRow(
+		ctx,
+		`SELECT name, is_active, archived_at IS NOT NULL FROM model_versions WHERE id = $1`,
+		d.CandidateVersionID,
+	).Scan(&name, &active, &archived)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrModelNotFound
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case name != d.ModelName:
+		return fmt.Errorf("%w: candidate is a version of %s", ErrInvalidDeployment, name)
+	case archived:
+		return ErrVersionArchived
+	case active:
+		return fmt.Errorf("%w: candidate is already the active version", ErrInvalidDeployment)
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO model_deployments (model_name, candidate_version_id, mode, percent)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (model_name) DO UPDATE SET
+		   candidate_version_id = EXCLUDED.candidate_version_id,
+		   mode = EXCLUDED.mode,
+		   percent = EXCLUDED.percent,
+		   created_at = CASE
+		     WHEN model_deployments.candidate_version_id = EXCLUDED.candidate_version_id
+		     THEN model_deployments.created_at ELSE now() END,
+		   updated_at = now()
+		 RETURNING created_at, updated_at`,
+		d.ModelName,
+		d.CandidateVersionID,
+		d.Mode,
+		d.Percent,
+	).Scan(&d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	err = insertAudit(ctx, tx, d.ModelName, &d.CandidateVersionID, "deploy", map[string]any{
+		"mode":    d.Mode,
+		"percent": d.Percent,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DeleteDeployment stops routing traffic to the candidate of a model
+func (r *PostgresRepository) DeleteDeployment(ctx context.Context, name string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var candidateID string
+	err = tx.QueryRow(
+		ctx,
+		`DELETE FROM model_deployments WHERE model_name = $1 RETURNING candidate_version_id`,
+		name,
+	).Scan(&candidateID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoDeployment
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := insertAudit(ctx, tx, name, &candidateID, "undeploy", nil); err != nil {
+		return err
 	}
 
 	return tx.Commit(ctx)
@@ -1,8 +1,23 @@
 package models
 
+import "context"
+
 type Repository interface {
-	Create(model *ModelVersion) error
-	ListByName(name string) ([]ModelVersion, error)
-	SetActive(modelID string) error
-	GetActive(name string) (*ModelVersion, error)
+	Create(ctx context.Context, model *ModelVersion) error
+	GetByID(ctx context.Context, id string) (*ModelVersion, error)
+	ListByName(ctx context.Context, name string, includeArchived bool) ([]ModelVersion, error)
+	ListModels(ctx context.Context, limit, offset int) ([]ModelSummary, int, error)
+	Archive(ctx context.Context, id string, purgeArtifact bool) (*ModelVersion, error)
+	ArtifactInUse(ctx context.Context, path, exceptID string) (bool, error)
+	SetArtifactDigest(ctx context.Context, id, sha string, size int64) error
+	RecordAudit(ctx context.Context, name string, modelID *string, action string, details map[string]any) error
+	SetActive(ctx context.Context, modelID string) error
+	GetActive(ctx context.Context, name string) (*ModelVersion, error)
+	Rollback(ctx context.Context, name string, steps int) (*ModelVersion, error)
+	ListActivations(ctx context.Context, name string) ([]Activation, error)
+	ListByTags(ctx context.Context, tags []string) ([]ModelVersion, error)
+	UpdateVersionMetadata(ctx context.Context, id string, upd VersionMetadataUpdate) (*ModelVersion, error)
+	GetRegisteredModel(ctx context.Context, name string) (*RegisteredModel, error)
+	EnsureRegisteredModel(ctx context.Context, name string) error
+	UpdateRegisteredModel(ctx context.Context, name string, upd RegisteredModelUpdate) (*RegisteredModel, error)
 }
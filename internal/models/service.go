@@ -2,50 +2,92 @@ package models
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"audioml/internal/artifacts"
+	"audioml/internal/dataset"
+	"audioml/internal/metrics"
 
 	"github.com/google/uuid"
 )
 
 type Service struct {
-	repo PostgresRepository
+	repo      PostgresRepository
+	artifacts *artifacts.Store
 }
 
-func NewService(repo PostgresRepository) *Service {
-	return &Service{repo: repo}
+func NewService(repo PostgresRepository, store *artifacts.Store) *Service {
+	return &Service{repo: repo, artifacts: store}
 }
 
-// RegisterFromTraining creates a new model version from a completed training job
-func (s *Service) RegisterFromTraining(
-	ctx context.Context,
-	trainingJobID string,
-	modelName string,
-	metrics map[string]float64,
-	hyperparams map[string]any,
-	artifactPath string,
-) error {
-
-	versions, err := s.repo.ListByName(ctx, modelName)
-	if err != nil {
-		return err
-	}
+// Registration describes a completed training run to register
+type Registration struct {
+	TrainingJobID string
+	ModelName     string
+	Metrics       map[string]float64
+	Hyperparams   map[string]any
+	ArtifactPath  string
+
+	// ArtifactSHA256 and ArtifactSize are hashed from ArtifactPath when empty
+	ArtifactSHA256 string
+	ArtifactSize   int64
 
-	nextVersion := 1
-	if len(versions) > 0 {
-		nextVersion = versions[0].Version + 1
+	DatasetSource      string
+	DatasetSummary     *dataset.Summary
+	TrainingStartedAt  *time.Time
+	TrainingFinishedAt *time.Time
+
+	ClassMetrics    map[string]metrics.ClassMetrics
+	ConfusionMatrix *metrics.ConfusionMatrix
+}
+
+// RegisterFromTraining creates a new model version from a completed training job
+func (s *Service) RegisterFromTraining(ctx context.Context, reg Registration) (*ModelVersion, error) {
+	sha, size := reg.ArtifactSHA256, reg.ArtifactSize
+	if sha == "" {
+		var err error
+		sha, size, err = s.artifacts.Digest(ctx, reg.ArtifactPath)
+		if err != nil {
+			return nil, fmt.Errorf("hash artifact: %w", err)
+		}
 	}
 
 	model := &ModelVersion{
 		ID:            uuid.NewString(),
-		TrainingJobID: trainingJobID,
-		Name:          modelName,
-		Version:       nextVersion,
-		Metrics:       metrics,
-		Hyperparams:   hyperparams,
-		ArtifactPath:  artifactPath,
+		TrainingJobID: reg.TrainingJobID,
+		Name:          reg.ModelName,
+		Metrics:       reg.Metrics,
+		Hyperparams:   reg.Hyperparams,
+		ArtifactPath:  reg.ArtifactPath,
 		IsActive:      false,
+
+		ArtifactSHA256: sha,
+		ArtifactSize:   size,
+
+		DatasetSource:      reg.DatasetSource,
+		DatasetSummary:     reg.DatasetSummary,
+		TrainingStartedAt:  reg.TrainingStartedAt,
+		TrainingFinishedAt: reg.TrainingFinishedAt,
+
+		ClassMetrics:    reg.ClassMetrics,
+		ConfusionMatrix: reg.ConfusionMatrix,
+	}
+
+	if err := s.create(ctx, model); err != nil {
+		return nil, err
 	}
+	return model, nil
+}
 
-	return s.repo.Create(ctx, model)
+// create stores m under the next version number of the model name
+func (s *Service) create(ctx context.Context, m *ModelVersion) error {
+	if err := s.repo.EnsureRegisteredModel(ctx, m.Name); err != nil {
+		return err
+	}
+	return s.repo.Create(ctx, m)
 }
 
 // Activate sets a model version as active
@@ -53,12 +95,210 @@ func (s *Service) Activate(ctx context.Context, modelID string) error {
 	return s.repo.SetActive(ctx, modelID)
 }
 
-// ListVersions lists all versions of a model
-func (s *Service) ListVersions(ctx context.Context, name string) ([]ModelVersion, error) {
-	return s.repo.ListByName(ctx, name)
+// ListVersions lists the versions of a model
+func (s *Service) ListVersions(ctx context.Context, name string, includeArchived bool) ([]ModelVersion, error) {
+	return s.repo.ListByName(ctx, name, includeArchived)
+}
+
+// ListModels returns a page of registry summaries and the total count
+func (s *Service) ListModels(ctx context.Context, limit, offset int) ([]ModelSummary, int, error) {
+	return s.repo.ListModels(ctx, limit, offset)
+}
+
+// Archive soft-deletes a version. With purgeArtifact the artifact file is
+// removed too, unless another live version still references it.
+func (s *Service) Archive(ctx context.Context, id string, purgeArtifact bool) (*ModelVersion, error) {
+	m, err := s.repo.Archive(ctx, id, purgeArtifact)
+	if err != nil {
+		return nil, err
+	}
+
+	if !purgeArtifact || m.ArtifactPath == "" {
+		return m, nil
+	}
+
+	inUse, err := s.repo.ArtifactInUse(ctx, m.ArtifactPath, m.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return m, nil
+	}
+
+	if err := s.artifacts.Remove(ctx, m.ArtifactPath); err != nil {
+		return nil, err
+	}
+
+	return m, nil
 }
 
 // GetActive returns active model version
 func (s *Service) GetActive(ctx context.Context, name string) (*ModelVersion, error) {
 	return s.repo.GetActive(ctx, name)
 }
+
+// Get returns a single model version
+func (s *Service) Get(ctx context.Context, id string) (*ModelVersion, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// Rollback restores the version that was active `steps` activations ago
+func (s *Service) Rollback(ctx context.Context, name string, steps int) (*ModelVersion, error) {
+	if steps < 1 {
+		steps = 1
+	}
+	return s.repo.Rollback(ctx, name, steps)
+}
+
+// Deploy routes part of the traffic of a model to a candidate version
+func (s *Service) Deploy(ctx context.Context, d *Deployment) error {
+	return s.repo.SetDeployment(ctx, d)
+}
+
+// GetDeployment returns the current deployment of a model
+func (s *Service) GetDeployment(ctx context.Context, name string) (*Deployment, error) {
+	return s.repo.GetDeployment(ctx, name)
+}
+
+// Undeploy sends all traffic back to the active version
+func (s *Service) Undeploy(ctx context.Context, name string) error {
+	return s.repo.DeleteDeployment(ctx, name)
+}
+
+// ActivationHistory lists the activations of a model, newest first
+func (s *Service) ActivationHistory(ctx context.Context, name string) ([]Activation, error) {
+	return s.repo.ListActivations(ctx, name)
+}
+
+// FindByTags returns the versions of any model carrying all given tags
+func (s *Service) FindByTags(ctx context.Context, tags []string) ([]ModelVersion, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if t = normalizeTag(t); t != "" {
+			normalized = append(normalized, t)
+		}
+	}
+	return s.repo.ListByTags(ctx, normalized)
+}
+
+// UpdateVersionMetadata edits the description, tags and annotations of a version
+func (s *Service) UpdateVersionMetadata(ctx context.Context, id string, upd VersionMetadataUpdate) (*ModelVersion, error) {
+	return s.repo.UpdateVersionMetadata(ctx, id, upd)
+}
+
+// GetRegisteredModel returns the model-level metadata of a name
+func (s *Service) GetRegisteredModel(ctx context.Context, name string) (*RegisteredModel, error) {
+	return s.repo.GetRegisteredModel(ctx, name)
+}
+
+// UpdateRegisteredModel edits the model-level metadata of a name
+func (s *Service) UpdateRegisteredModel(ctx context.Context, name string, upd RegisteredModelUpdate) (*RegisteredModel, error) {
+	return s.repo.UpdateRegisteredModel(ctx, name, upd)
+}
+
+// OpenArtifact opens the artifact of a version for streaming
+func (s *Service) OpenArtifact(ctx context.Context, m *ModelVersion) (*artifacts.Object, error) {
+	return s.artifacts.Open(ctx, m.ArtifactPath)
+}
+
+// Verify re-hashes the artifact of a version and compares it with the
+// digest recorded at registration. Versions without a recorded digest are
+// reported as not verified, or get one recorded when backfill is set.
+func (s *Service) Verify(ctx context.Context, id string, backfill bool) (*VerifyResult, error) {
+	m, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &VerifyResult{
+		ModelVersionID: m.ID,
+		ArtifactPath:   m.ArtifactPath,
+		ExpectedSHA256: m.ArtifactSHA256,
+		ExpectedSize:   m.ArtifactSize,
+	}
+
+	sha, size, err := s.artifacts.Digest(ctx, m.ArtifactPath)
+	if err != nil {
+		res.Error = "artifact unreadable: " + err.Error()
+	} else {
+		res.ActualSHA256 = sha
+		res.ActualSize = size
+
+		switch {
+		case m.ArtifactSHA256 == "" && backfill:
+			if err := s.repo.SetArtifactDigest(ctx, m.ID, sha, size); err != nil {
+				return nil, err
+			}
+			res.ExpectedSHA256, res.ExpectedSize = sha, size
+			res.Backfilled = true
+			res.OK = true
+		case m.ArtifactSHA256 == "":
+			res.Error = "no digest recorded for this version"
+		case sha != m.ArtifactSHA256 || size != m.ArtifactSize:
+			res.Error = "artifact digest mismatch"
+		default:
+			res.OK = true
+		}
+	}
+
+	err = s.repo.RecordAudit(ctx, m.Name, &m.ID, "verify", map[string]any{
+		"ok":         res.OK,
+		"backfilled": res.Backfilled,
+		"error":      res.Error,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ClassLabels returns the class labels of a version, preferring the ones
+// the trainer evaluated with over the registry metadata
+func (s *Service) ClassLabels(ctx context.Context, m *ModelVersion) ([]string, error) {
+	if m.ConfusionMatrix != nil && len(m.ConfusionMatrix.Labels) > 0 {
+		return m.ConfusionMatrix.Labels, nil
+	}
+
+	card, err := s.card(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	return card.ClassLabels, nil
+}
+
+// Card builds the model card of a version from the registry
+func (s *Service) Card(ctx context.Context, id string) (*ModelCard, error) {
+	m, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.card(ctx, m)
+}
+
+func (s *Service) card(ctx context.Context, m *ModelVersion) (*ModelCard, error) {
+	rm, err := s.repo.GetRegisteredModel(ctx, m.Name)
+	if err != nil && !errors.Is(err, ErrModelNotFound) {
+		return nil, err
+	}
+	return BuildCard(m, rm), nil
+}
+
+// PublishCard stores the Markdown and JSON card next to the artifact
+func (s *Service) PublishCard(ctx context.Context, m *ModelVersion) error {
+	card, err := s.card(ctx, m)
+	if err != nil {
+		return err
+	}
+
+	cardJSON, err := json.MarshalIndent(card, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.artifacts.WriteAlongside(ctx, m.ArtifactPath, "model_card.json", cardJSON); err != nil {
+		return err
+	}
+	_, err = s.artifacts.WriteAlongside(ctx, m.ArtifactPath, "model_card.md", []byte(card.Markdown()))
+	return err
+}
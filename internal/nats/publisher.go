@@ -2,6 +2,7 @@ package nats
 
 import (
 	"encoding/json"
+	"time"
 
 	nats "github.com/nats-io/nats.go"
 )
@@ -20,3 +21,24 @@ func PublishIngestEvent(js nats.JetStreamContext, subject string, ev IngestEvent
 	_, err = js.Publish(subject, data)
 	return err
 }
+
+// DriftAlert is published when live traffic of a model drifted away from
+// its training data
+type DriftAlert struct {
+	ModelName      string    `json:"model"`
+	ModelVersionID string    `json:"model_version_id"`
+	ReportID       int64     `json:"report_id"`
+	Drifted        []string  `json:"drifted"`
+	Threshold      float64   `json:"threshold"`
+	WindowStart    time.Time `json:"window_start"`
+	WindowEnd      time.Time `json:"window_end"`
+}
+
+// PublishDriftAlert publishes on core NATS, alerts are fire and forget
+func PublishDriftAlert(nc *nats.Conn, subject string, ev DriftAlert) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return nc.Publish(subject, data)
+}
@@ -53,3 +53,30 @@ func (m *MinioClient) UploadFromReader(ctx context.Context, objectName string, r
 	})
 	return err
 }
+
+func (m *MinioClient) OpenObject(ctx context.Context, bucket, objectName string) (*minio.Object, minio.ObjectInfo, error) {
+	obj, err := m.Client.GetObject(ctx, bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, minio.ObjectInfo{}, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, minio.ObjectInfo{}, err
+	}
+	return obj, info, nil
+}
+
+func (m *MinioClient) RemoveObject(ctx context.Context, bucket, objectName string) error {
+	return m.Client.RemoveObject(ctx, bucket, objectName, minio.RemoveObjectOptions{})
+}
+
+// UploadMultipart streams reader into bucket, split into parts of partSize.
+// Pass size -1 when the length is not known up front.
+func (m *MinioClient) UploadMultipart(ctx context.Context, bucket, objectName string, reader io.Reader, size int64, partSize uint64, contentType string) error {
+	_, err := m.Client.PutObject(ctx, bucket, objectName, reader, size, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    partSize,
+	})
+	return err
+}
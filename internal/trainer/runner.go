@@ -4,27 +4,48 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 )
 
-func NewPythonRunner(pythonBin, script, workDir string) *PythonRunner {
+func NewPythonRunner(pythonBin, script, evaluatorScript, workDir string) *PythonRunner {
 	return &PythonRunner{
-		PythonBin:     pythonBin,
-		TrainerScript: script,
-		WorkDir:       workDir,
+		PythonBin:       pythonBin,
+		TrainerScript:   script,
+		EvaluatorScript: evaluatorScript,
+		WorkDir:         workDir,
 	}
 }
 
+// OutputDir is the directory the trainer writes the outputs of a job to
+func (r *PythonRunner) OutputDir(jobID string) string {
+	return filepath.Join(r.WorkDir, jobID)
+}
+
+// InputDir holds the inputs fetched for a job, outside of its published
+// output directory
+func (r *PythonRunner) InputDir(jobID string) string {
+	return filepath.Join(r.WorkDir, jobID+".inputs")
+}
+
 func (r *PythonRunner) Run(ctx context.Context, req Request) (*Result, error) {
-	cmd := exec.CommandContext(
-		ctx,
-		r.PythonBin,
+	args := []string{
 		r.TrainerScript,
 		"--job-id", req.JobID,
 		"--dataset", req.Dataset,
 		"--model", req.Model,
-		"--out", "artifacts",
-	)
+		"--out", r.WorkDir,
+	}
+	if req.BaseModel != "" {
+		args = append(args, "--base-model", req.BaseModel)
+	}
+	if req.Splits != "" {
+		args = append(args, "--splits", req.Splits)
+	}
+
+	cmd := exec.CommandContext(ctx, r.PythonBin, args...)
 
 	out, err := cmd.Output()
 	if err != nil {
@@ -38,5 +59,50 @@ func (r *PythonRunner) Run(ctx context.Context, req Request) (*Result, error) {
 		return nil, err
 	}
 
+	if cm := result.ConfusionMatrix; cm != nil {
+		if err := cm.Validate(); err != nil {
+			return nil, fmt.Errorf("trainer result: %w", err)
+		}
+		if len(result.ClassMetrics) == 0 {
+			result.ClassMetrics = cm.ClassMetrics()
+		}
+	}
+
+	return &result, nil
+}
+
+// Evaluate runs the evaluator script on a held-out dataset
+func (r *PythonRunner) Evaluate(ctx context.Context, req EvalRequest) (*EvalResult, error) {
+	args := []string{
+		r.EvaluatorScript,
+		"--job-id", req.JobID,
+		"--artifact", req.ArtifactPath,
+		"--model-version-id", req.ModelVersionID,
+		"--dataset", req.Dataset,
+		"--labels", strings.Join(req.Labels, ","),
+	}
+
+	cmd := exec.CommandContext(ctx, r.PythonBin, args...)
+	cmd.Stderr = os.Stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var result EvalResult
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, err
+	}
+
+	if cm := result.ConfusionMatrix; cm != nil {
+		if err := cm.Validate(); err != nil {
+			return nil, fmt.Errorf("evaluator result: %w", err)
+		}
+		if len(result.ClassMetrics) == 0 {
+			result.ClassMetrics = cm.ClassMetrics()
+		}
+	}
+
 	return &result, nil
 }
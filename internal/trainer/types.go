@@ -1,19 +1,55 @@
 package trainer
 
+import (
+	"audioml/internal/dataset"
+	"audioml/internal/metrics"
+)
+
 type Request struct {
 	JobID   string
 	Dataset string
 	Model   string
+	// BaseModel is the artifact URI of the version to start from, if any
+	BaseModel string
+	// Splits is the CSV manifest assigning the files of Dataset to train,
+	// validation and test, if the dataset has one
+	Splits string
 }
 
 type Result struct {
 	Metrics      map[string]float64 `json:"metrics"`
 	ArtifactPath string             `json:"artifact_path"`
 	Params       map[string]any     `json:"params"`
+
+	// Optional breakdown for classifiers. When only the confusion matrix
+	// is reported the per-class scores are derived from it.
+	ClassMetrics    map[string]metrics.ClassMetrics `json:"class_metrics,omitempty"`
+	ConfusionMatrix *metrics.ConfusionMatrix        `json:"confusion_matrix,omitempty"`
+
+	// Audio feature statistics of the training data, if the trainer reports them
+	FeatureStats dataset.FeatureStats `json:"feature_stats,omitempty"`
+}
+
+// EvalRequest scores a trained artifact on a labeled dataset
+type EvalRequest struct {
+	JobID          string
+	Dataset        string
+	ModelVersionID string
+	// ArtifactPath must be a local file
+	ArtifactPath string
+	Labels       []string
+}
+
+type EvalResult struct {
+	Metrics         map[string]float64              `json:"metrics"`
+	Samples         int                             `json:"samples"`
+	ClassMetrics    map[string]metrics.ClassMetrics `json:"class_metrics,omitempty"`
+	ConfusionMatrix *metrics.ConfusionMatrix        `json:"confusion_matrix,omitempty"`
 }
 
 type PythonRunner struct {
-	PythonBin     string
-	TrainerScript string
-	WorkDir       string // where artifacts/metrics go
+	PythonBin       string
+	TrainerScript   string
+	EvaluatorScript string
+	WorkDir         string // where artifacts/metrics go
 }
@@ -19,9 +19,14 @@ type Job struct {
 	ID            uuid.UUID
 	Status        Status
 	DatasetSource string
-	ModelName     string
-	CreatedAt     time.Time
-	StartedAt     *time.Time
-	FinishedAt    *time.Time
-	Error         *string
+	// DatasetVersion and DatasetDigest pin the snapshot the job trained
+	// on, nil for datasets that were never versioned
+	DatasetVersion *int
+	DatasetDigest  *string
+	ModelName      string
+	BaseModelID    *string
+	CreatedAt      time.Time
+	StartedAt      *time.Time
+	FinishedAt     *time.Time
+	Error          *string
 }
@@ -16,15 +16,18 @@ func NewPostgresRepo() *PostgresRepo {
 func (r *PostgresRepo) Create(ctx context.Context, job *Job) error {
 	q := `
 INSERT INTO training_jobs
-(id, status, dataset_source, model_name, created_at)
-VALUES ($1, $2, $3, $4, $5)
+(id, status, dataset_source, dataset_version, dataset_digest, model_name, base_model_id, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 `
 	_, err := db.Pool.Exec(
 		ctx, q,
 		job.ID,
 		job.Status,
 		job.DatasetSource,
+		job.DatasetVersion,
+		job.DatasetDigest,
 		job.ModelName,
+		job.BaseModelID,
 		job.CreatedAt,
 	)
 	return err
@@ -51,7 +54,7 @@ func (r *PostgresRepo) UpdateStatus(ctx context.Context, id string, status Statu
 
 func (r *PostgresRepo) GetByID(ctx context.Context, id string) (*Job, error) {
 	row := db.Pool.QueryRow(ctx, `
-SELECT id, status, dataset_source, model_name,
+SELECT id, status, dataset_source, dataset_version, dataset_digest, model_name, base_model_id,
        created_at, started_at, finished_at, error
 FROM training_jobs WHERE id=$1
 `, id)
@@ -61,7 +64,10 @@ FROM training_jobs WHERE id=$1
 		&job.ID,
 		&job.Status,
 		&job.DatasetSource,
+		&job.DatasetVersion,
+		&job.DatasetDigest,
 		&job.ModelName,
+		&job.BaseModelID,
 		&job.CreatedAt,
 		&job.StartedAt,
 		&job.FinishedAt,
@@ -2,12 +2,20 @@ package training
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"audioml/internal/artifacts"
+	"audioml/internal/dataset"
+	"audioml/internal/lineage"
+	ilog "audioml/internal/logger"
 	"audioml/internal/models"
 	"audioml/internal/trainer"
 
@@ -18,52 +26,114 @@ type Service struct {
 	repo          Repository
 	trainerRunner *trainer.PythonRunner
 	modelService  *models.Service
+	artifacts     *artifacts.Store
+	lineage       *lineage.Service
+	datasets      *dataset.Service
 }
 
 func NewService(
 	repo Repository,
 	trainerRunner *trainer.PythonRunner,
 	modelService *models.Service,
+	artifactStore *artifacts.Store,
+	lineageService *lineage.Service,
+	datasetService *dataset.Service,
 ) *Service {
 	return &Service{
 		repo:          repo,
 		trainerRunner: trainerRunner,
 		modelService:  modelService,
+		artifacts:     artifactStore,
+		lineage:       lineageService,
+		datasets:      datasetService,
 	}
 }
 
-func (s *Service) StartJob(
-	ctx context.Context,
-	datasetSource string,
-	modelName string,
-) (*Job, error) {
+// StartRequest describes a training job to start
+type StartRequest struct {
+	// DatasetSource is "local-audio/<name>", optionally pinned to a version
+	// with "@<version>". Unpinned requests train on the latest version.
+	DatasetSource string
+	ModelName     string
+	// BaseModelID optionally names the model version to fine-tune from
+	BaseModelID string
+}
+
+func (s *Service) StartJob(ctx context.Context, req StartRequest) (*Job, error) {
 
 	// DEMO CONTRACT
-	if !strings.HasPrefix(datasetSource, "local-audio/") {
+	if !strings.HasPrefix(req.DatasetSource, "local-audio/") {
 		return nil, errors.New("only local-audio datasets are supported")
 	}
 
+	snap, err := s.datasets.Resolve(ctx, req.DatasetSource)
+	if err != nil {
+		return nil, err
+	}
+
+	var base *models.ModelVersion
+	if req.BaseModelID != "" {
+		m, err := s.modelService.Get(ctx, req.BaseModelID)
+		if err != nil {
+			return nil, fmt.Errorf("base model: %w", err)
+		}
+		if m.ArchivedAt != nil {
+			return nil, fmt.Errorf("base model: %w", models.ErrVersionArchived)
+		}
+		base = m
+	}
+
 	job := &Job{
 		ID:            uuid.New(),
 		Status:        StatusQueued,
-		DatasetSource: datasetSource,
-		ModelName:     modelName,
+		DatasetSource: dataset.Source(snap.Name),
+		ModelName:     req.ModelName,
 		CreatedAt:     time.Now(),
 	}
+	if snap.Version > 0 {
+		job.DatasetVersion = &snap.Version
+		job.DatasetDigest = &snap.Digest
+	}
+	if base != nil {
+		job.BaseModelID = &base.ID
+	}
 
 	if err := s.repo.Create(ctx, job); err != nil {
 		return nil, err
 	}
 
-	go s.run(context.Background(), job)
+	jobNode := lineage.TrainingJobNode(job.ID.String(), job.ModelName)
+	if snap.Version > 0 {
+		s.link(ctx, lineage.DatasetSnapshotNode(snap.Source, snap.Digest), jobNode, lineage.RelInputOf)
+	} else {
+		s.link(ctx, lineage.DatasetNode(job.DatasetSource), jobNode, lineage.RelInputOf)
+	}
+	if base != nil {
+		s.link(ctx, lineage.ModelVersionNode(base.ID, base.Name, base.Version), jobNode, lineage.RelBaseOf)
+	}
+
+	go s.run(context.Background(), job, snap, base)
 
 	return job, nil
 }
 
-func (s *Service) run(ctx context.Context, job *Job) {
+// link records lineage, a missing link must not fail the job itself
+func (s *Service) link(ctx context.Context, from, to lineage.Node, relation string) {
+	if err := s.lineage.Link(ctx, from, to, relation); err != nil {
+		ilog.L.Printf("lineage %s -[%s]-> %s: %v", from.Key(), relation, to.Key(), err)
+	}
+}
+
+func (s *Service) run(ctx context.Context, job *Job, snap *dataset.Snapshot, base *models.ModelVersion) {
 	_ = s.repo.UpdateStatus(ctx, job.ID.String(), StatusRunning, nil)
+	defer os.RemoveAll(s.trainerRunner.InputDir(job.ID.String()))
 
-	datasetPath := filepath.Join("datasets", job.DatasetSource)
+	datasetPath, err := s.datasets.Checkout(ctx, snap)
+	if err != nil {
+		msg := "dataset checkout failed: " + err.Error()
+		_ = s.repo.UpdateStatus(ctx, job.ID.String(), StatusFailed, &msg)
+		return
+	}
 
 	if _, err := os.Stat(datasetPath); err != nil {
 		msg := "dataset not found: " + datasetPath
@@ -71,11 +141,39 @@ func (s *Service) run(ctx context.Context, job *Job) {
 		return
 	}
 
-	result, err := s.trainerRunner.Run(ctx, trainer.Request{
+	summary, err := dataset.Summarize(datasetPath)
+	if err != nil {
+		msg := "dataset summary failed: " + err.Error()
+		_ = s.repo.UpdateStatus(ctx, job.ID.String(), StatusFailed, &msg)
+		return
+	}
+
+	startedAt := time.Now()
+
+	req := trainer.Request{
 		JobID:   job.ID.String(),
 		Dataset: datasetPath,
 		Model:   job.ModelName,
-	})
+	}
+	if base != nil {
+		baseModel, err := s.fetchBaseModel(ctx, job, base)
+		if err != nil {
+			msg := "base model download failed: " + err.Error()
+			_ = s.repo.UpdateStatus(ctx, job.ID.String(), StatusFailed, &msg)
+			return
+		}
+		req.BaseModel = baseModel
+	}
+	// Versions carry their splits so that every model trained on them is
+	// tested on the same files
+	if snap.Version > 0 {
+		splits := filepath.Join(datasetPath, dataset.SplitsFile)
+		if _, err := os.Stat(splits); err == nil {
+			req.Splits = splits
+		}
+	}
+
+	result, err := s.trainerRunner.Run(ctx, req)
 
 	if err != nil {
 		msg := err.Error()
@@ -83,14 +181,44 @@ func (s *Service) run(ctx context.Context, job *Job) {
 		return
 	}
 
-	err = s.modelService.RegisterFromTraining(
-		ctx,
-		job.ID.String(),
-		job.ModelName,
-		result.Metrics,
-		result.Params,
-		result.ArtifactPath,
-	)
+	finishedAt := time.Now()
+
+	summary.Features = result.FeatureStats
+
+	// Publish the whole output directory, the artifact is one of its files
+	outDir := s.trainerRunner.OutputDir(job.ID.String())
+
+	published, err := s.artifacts.Publish(ctx, outDir, job.ID.String())
+	if err != nil {
+		msg := "artifact upload failed: " + err.Error()
+		_ = s.repo.UpdateStatus(ctx, job.ID.String(), StatusFailed, &msg)
+		return
+	}
+
+	artifact, err := findArtifact(outDir, result.ArtifactPath, published)
+	if err != nil {
+		msg := err.Error()
+		_ = s.repo.UpdateStatus(ctx, job.ID.String(), StatusFailed, &msg)
+		return
+	}
+
+	version, err := s.modelService.RegisterFromTraining(ctx, models.Registration{
+		TrainingJobID:  job.ID.String(),
+		ModelName:      job.ModelName,
+		Metrics:        result.Metrics,
+		Hyperparams:    result.Params,
+		ArtifactPath:   artifact.URI,
+		ArtifactSHA256: artifact.SHA256,
+		ArtifactSize:   artifact.Size,
+
+		DatasetSource:      snap.Source,
+		DatasetSummary:     summary,
+		TrainingStartedAt:  &startedAt,
+		TrainingFinishedAt: &finishedAt,
+
+		ClassMetrics:    result.ClassMetrics,
+		ConfusionMatrix: result.ConfusionMatrix,
+	})
 
 	if err != nil {
 		msg := "model registration failed: " + err.Error()
@@ -98,5 +226,86 @@ func (s *Service) run(ctx context.Context, job *Job) {
 		return
 	}
 
+	s.link(
+		ctx,
+		lineage.TrainingJobNode(job.ID.String(), job.ModelName),
+		lineage.ModelVersionNode(version.ID, version.Name, version.Version),
+		lineage.RelProduced,
+	)
+
+	// The card can be rebuilt from the registry, a failed copy is not fatal
+	if err := s.modelService.PublishCard(ctx, version); err != nil {
+		ilog.L.Printf("publish model card for %s: %v", version.ID, err)
+	}
+
+	// Object storage is now the source of truth, the local copy would only
+	// exist on this instance
+	if s.artifacts.Remote() {
+		_ = os.RemoveAll(outDir)
+	}
+
 	_ = s.repo.UpdateStatus(ctx, job.ID.String(), StatusCompleted, nil)
 }
+
+// fetchBaseModel returns a local path to the artifact of base for the
+// trainer, downloading remote artifacts into the job input directory
+func (s *Service) fetchBaseModel(ctx context.Context, job *Job, base *models.ModelVersion) (string, error) {
+	if _, _, remote := artifacts.ParseS3URI(base.ArtifactPath); !remote {
+		return base.ArtifactPath, nil
+	}
+
+	obj, err := s.artifacts.Open(ctx, base.ArtifactPath)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Close()
+
+	dir := s.trainerRunner.InputDir(job.ID.String())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(dir, filepath.Base(obj.Name))
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), obj)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if base.ArtifactSHA256 != "" && hex.EncodeToString(h.Sum(nil)) != base.ArtifactSHA256 {
+		return "", fmt.Errorf("artifact of %s does not match its recorded digest", base.ID)
+	}
+	return dst, nil
+}
+
+// findArtifact maps the artifact path reported by the trainer to its
+// published copy. The artifact has to live inside the job output directory.
+func findArtifact(outDir, artifactPath string, published map[string]artifacts.PublishedFile) (artifacts.PublishedFile, error) {
+	absDir, err := filepath.Abs(outDir)
+	if err != nil {
+		return artifacts.PublishedFile{}, err
+	}
+	absArtifact, err := filepath.Abs(artifactPath)
+	if err != nil {
+		return artifacts.PublishedFile{}, err
+	}
+
+	rel, err := filepath.Rel(absDir, absArtifact)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return artifacts.PublishedFile{}, fmt.Errorf("artifact %s is outside of the job output directory", artifactPath)
+	}
+
+	f, ok := published[filepath.ToSlash(rel)]
+	if !ok {
+		return artifacts.PublishedFile{}, fmt.Errorf("artifact %s not found in job output", artifactPath)
+	}
+	return f, nil
+}
@@ -0,0 +1,71 @@
+package uploads
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound       = errors.New("upload not found")
+	ErrInvalidUpload  = errors.New("invalid upload")
+	ErrTooLarge       = errors.New("upload too large")
+	ErrOffsetMismatch = errors.New("upload offset does not match")
+	ErrLocked         = errors.New("upload is being written by another request")
+	ErrNotWritable    = errors.New("upload is no longer accepting data")
+)
+
+type Status string
+
+const (
+	StatusUploading  Status = "uploading"
+	StatusFinalizing Status = "finalizing"
+	StatusCompleted  Status = "completed"
+	StatusFailed     Status = "failed"
+)
+
+// Target is where a finished upload goes
+type Target string
+
+const (
+	// TargetAudio stores the file in object storage as an audio_files row
+	TargetAudio Target = "audio"
+	// TargetDataset adds the file to a dataset like a regular upload
+	TargetDataset Target = "dataset"
+)
+
+// Upload is a resumable upload. Offset is the number of bytes received
+// so far, the upload is complete when it reaches Length.
+type Upload struct {
+	ID       string `json:"id"`
+	Target   Target `json:"target"`
+	Filename string `json:"filename"`
+	Dataset  string `json:"dataset,omitempty"`
+	Label    string `json:"label,omitempty"`
+	Split    string `json:"split,omitempty"`
+	Length   int64  `json:"length"`
+	Offset   int64  `json:"offset"`
+	Status   Status `json:"status"`
+	// Result is the outcome of the finalization: the audio file id or the
+	// dataset upload report
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     *string         `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+// Complete reports whether every byte was received
+func (u *Upload) Complete() bool {
+	return u.Offset == u.Length
+}
+
+// CreateRequest describes an upload to create
+type CreateRequest struct {
+	Target   Target
+	Filename string
+	Dataset  string
+	Label    string
+	Split    string
+	Length   int64
+}
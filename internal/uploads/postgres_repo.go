@@ -0,0 +1,134 @@
+package uploads
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresRepository(db *pgxpool.Pool) PostgresRepository {
+	return PostgresRepository{db: db}
+}
+
+const uploadColumns = `
+	id::text, target, filename, COALESCE(dataset, ''), COALESCE(label, ''), COALESCE(split, ''),
+	length, "offset", status, result, error, created_at, updated_at, expires_at
+`
+
+func scanUpload(row pgx.Row) (*Upload, error) {
+	var u Upload
+	var result []byte
+	err := row.Scan(
+		&u.ID, &u.Target, &u.Filename, &u.Dataset, &u.Label, &u.Split,
+		&u.Length, &u.Offset, &u.Status, &result, &u.Error, &u.CreatedAt, &u.UpdatedAt, &u.ExpiresAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > 0 {
+		u.Result = result
+	}
+	return &u, nil
+}
+
+func (r PostgresRepository) Create(ctx context.Context, u *Upload) error {
+	return r.db.QueryRow(ctx, `
+		INSERT INTO uploads (id, target, filename, dataset, label, split, length, status, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7, $8, $9)
+		RETURNING created_at, updated_at
+	`, u.ID, u.Target, u.Filename, u.Dataset, u.Label, u.Split, u.Length, u.Status, u.ExpiresAt).
+		Scan(&u.CreatedAt, &u.UpdatedAt)
+}
+
+func (r PostgresRepository) Get(ctx context.Context, id string) (*Upload, error) {
+	return scanUpload(r.db.QueryRow(ctx, `SELECT `+uploadColumns+` FROM uploads WHERE id = $1`, id))
+}
+
+// SetOffset records the bytes received and pushes the expiry back
+func (r PostgresRepository) SetOffset(ctx context.Context, id string, offset int64, expiresAt time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE uploads SET "offset" = $2, expires_at = $3, updated_at = now()
+		WHERE id = $1
+	`, id, offset, expiresAt)
+	return err
+}
+
+// SetStatus moves an upload from one status to another, reporting
+// whether it was still in the expected one
+func (r PostgresRepository) SetStatus(ctx context.Context, id string, from, to Status, result []byte, errMsg *string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE uploads SET status = $3, result = $4, error = $5, updated_at = now()
+		WHERE id = $1 AND status = $2
+	`, id, from, to, result, errMsg)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (r PostgresRepository) Delete(ctx context.Context, id string) error {
+	tag, err := r.db.Exec(ctx, `DELETE FROM uploads WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Expired lists the ids of unfinished uploads past their expiry
+func (r PostgresRepository) Expired(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id::text FROM uploads
+		WHERE status = $1 AND expires_at < $2
+	`, StatusUploading, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// FailStale fails the uploads left finalizing since before, whose
+// finalization was cut short, and returns their ids
+func (r PostgresRepository) FailStale(ctx context.Context, before time.Time, errMsg string) ([]string, error) {
+	rows, err := r.db.Query(ctx, `
+		UPDATE uploads SET status = $3, error = $4, updated_at = now()
+		WHERE status = $1 AND updated_at < $2
+		RETURNING id::text
+	`, StatusFinalizing, before, StatusFailed, errMsg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,16 @@
+package uploads
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	Create(ctx context.Context, u *Upload) error
+	Get(ctx context.Context, id string) (*Upload, error)
+	SetOffset(ctx context.Context, id string, offset int64, expiresAt time.Time) error
+	SetStatus(ctx context.Context, id string, from, to Status, result []byte, errMsg *string) (bool, error)
+	Delete(ctx context.Context, id string) error
+	Expired(ctx context.Context, now time.Time) ([]string, error)
+	FailStale(ctx context.Context, before time.Time, errMsg string) ([]string, error)
+}
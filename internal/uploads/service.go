@@ -0,0 +1,311 @@
+package uploads
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"audioml/internal/dataset"
+	ilog "audioml/internal/logger"
+)
+
+// Service keeps the received bytes of every resumable upload in
+// <dir>/<id>.part and its progress in Postgres, so an interrupted client
+// can ask for the offset and carry on, even after a restart of the API.
+type Service struct {
+	repo     Repository
+	dir      string
+	maxBytes int64
+	ttl      time.Duration
+	started  time.Time
+
+	mu      sync.Mutex
+	writing map[string]bool
+}
+
+// NewService stores partial uploads in dir. Uploads larger than maxBytes
+// are refused, 0 meaning no limit, and unfinished ones are dropped once
+// they see no data for ttl.
+func NewService(repo Repository, dir string, maxBytes int64, ttl time.Duration) *Service {
+	return &Service{
+		repo:     repo,
+		dir:      dir,
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		started:  time.Now(),
+		writing:  map[string]bool{},
+	}
+}
+
+// MaxBytes is the largest upload accepted, 0 for no limit
+func (s *Service) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// Path is the file holding the bytes received for an upload
+func (s *Service) Path(id string) string {
+	return filepath.Join(s.dir, id+".part")
+}
+
+func (s *Service) Create(ctx context.Context, req CreateRequest) (*Upload, error) {
+	if req.Length <= 0 {
+		return nil, fmt.Errorf("%w: length must be positive", ErrInvalidUpload)
+	}
+	if s.maxBytes > 0 && req.Length > s.maxBytes {
+		return nil, fmt.Errorf("%w: uploads are limited to %d bytes", ErrTooLarge, s.maxBytes)
+	}
+	if req.Filename == "" {
+		return nil, fmt.Errorf("%w: filename required", ErrInvalidUpload)
+	}
+
+	switch req.Target {
+	case TargetAudio:
+		if req.Dataset != "" || req.Label != "" || req.Split != "" {
+			return nil, fmt.Errorf("%w: label and split only apply to dataset uploads", ErrInvalidUpload)
+		}
+	case TargetDataset:
+		if err := dataset.ValidateName(req.Dataset); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+		}
+		if req.Label != "" {
+			if err := dataset.ValidateLabel(req.Label); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidUpload, err)
+			}
+		}
+		if !dataset.Split(req.Split).Valid() {
+			return nil, fmt.Errorf("%w: split must be train, validation or test", ErrInvalidUpload)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown target %q", ErrInvalidUpload, req.Target)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	u := &Upload{
+		ID:        uuid.New().String(),
+		Target:    req.Target,
+		Filename:  req.Filename,
+		Dataset:   req.Dataset,
+		Label:     req.Label,
+		Split:     req.Split,
+		Length:    req.Length,
+		Status:    StatusUploading,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+
+	f, err := os.OpenFile(s.Path(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	if err := s.repo.Create(ctx, u); err != nil {
+		os.Remove(s.Path(u.ID))
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *Service) Get(ctx context.Context, id string) (*Upload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrNotFound
+	}
+	return s.repo.Get(ctx, id)
+}
+
+// Append writes body at offset, which must be the number of bytes
+// received so far. Whatever was written is kept and recorded even when
+// body fails half way, the client resumes from the new offset.
+func (s *Service) Append(ctx context.Context, id string, offset int64, body io.Reader) (*Upload, error) {
+	if !s.lock(id) {
+		return nil, ErrLocked
+	}
+	defer s.unlock(id)
+
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Status != StatusUploading {
+		return nil, ErrNotWritable
+	}
+	if offset != u.Offset {
+		return nil, fmt.Errorf("%w: expected %d, got %d", ErrOffsetMismatch, u.Offset, offset)
+	}
+
+	f, err := os.OpenFile(s.Path(id), os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	// Drop anything written past the recorded offset by a request that
+	// died before it could record it
+	if err := f.Truncate(u.Offset); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(u.Offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	n, copyErr := io.Copy(f, io.LimitReader(body, u.Length-u.Offset))
+	if err := f.Sync(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+	if n == 0 {
+		return u, copyErr
+	}
+
+	u.Offset += n
+	u.ExpiresAt = time.Now().Add(s.ttl)
+	// The request context may be gone with the client, the bytes on disk
+	// must be recorded anyway
+	if err := s.repo.SetOffset(context.WithoutCancel(ctx), id, u.Offset, u.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return u, copyErr
+}
+
+// Finalize claims a complete upload for finalization. It reports false
+// when another request already did.
+func (s *Service) Finalize(ctx context.Context, u *Upload) (bool, error) {
+	if !u.Complete() {
+		return false, ErrInvalidUpload
+	}
+	ok, err := s.repo.SetStatus(ctx, u.ID, StatusUploading, StatusFinalizing, nil, nil)
+	if err == nil && ok {
+		u.Status = StatusFinalizing
+	}
+	return ok, err
+}
+
+// Finish records the outcome of a finalization, failed when err is not
+// nil, and removes the received file
+func (s *Service) Finish(ctx context.Context, id string, result any, err error) error {
+	var raw []byte
+	if result != nil {
+		b, merr := json.Marshal(result)
+		if merr != nil {
+			return merr
+		}
+		raw = b
+	}
+
+	status := StatusCompleted
+	var errMsg *string
+	if err != nil {
+		status = StatusFailed
+		msg := err.Error()
+		errMsg = &msg
+	}
+
+	if _, err := s.repo.SetStatus(ctx, id, StatusFinalizing, status, raw, errMsg); err != nil {
+		return err
+	}
+	s.removeFile(id)
+	return nil
+}
+
+// Terminate drops an upload and the bytes received. Uploads being
+// written or finalized cannot be terminated.
+func (s *Service) Terminate(ctx context.Context, id string) error {
+	if !s.lock(id) {
+		return ErrLocked
+	}
+	defer s.unlock(id)
+
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.Status == StatusFinalizing {
+		return ErrLocked
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.removeFile(id)
+	return nil
+}
+
+// Run drops expired uploads every interval until ctx is done, and once
+// right away for those left behind by a restart
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	// Finalizations are only tracked in memory: whatever was finalizing
+	// before this instance started was cut short
+	s.failStale(ctx, s.started)
+	s.expire(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.failStale(ctx, time.Now().Add(-s.ttl))
+			s.expire(ctx)
+		}
+	}
+}
+
+// failStale fails the uploads left finalizing since before, an upload is
+// left finalizing when the API stops half way
+func (s *Service) failStale(ctx context.Context, before time.Time) {
+	stale, err := s.repo.FailStale(ctx, before, "finalization was interrupted, upload again")
+	if err != nil {
+		ilog.L.Printf("fail stale uploads: %v", err)
+	}
+	for _, id := range stale {
+		s.removeFile(id)
+	}
+}
+
+func (s *Service) expire(ctx context.Context) {
+	ids, err := s.repo.Expired(ctx, time.Now())
+	if err != nil {
+		ilog.L.Printf("expire uploads: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := s.Terminate(ctx, id); err != nil && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrLocked) {
+			ilog.L.Printf("expire upload %s: %v", id, err)
+		}
+	}
+}
+
+func (s *Service) removeFile(id string) {
+	if err := os.Remove(s.Path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		ilog.L.Printf("remove upload %s: %v", id, err)
+	}
+}
+
+func (s *Service) lock(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writing[id] {
+		return false
+	}
+	s.writing[id] = true
+	return true
+}
+
+func (s *Service) unlock(id string) {
+	s.mu.Lock()
+	delete(s.writing, id)
+	s.mu.Unlock()
+}
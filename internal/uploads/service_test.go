@@ -0,0 +1,118 @@
+package uploads
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRunFailsFinalizationsCutShortByRestart(t *testing.T) {
+	repo := &memRepository{uploads: map[string]*Upload{
+		// Interrupted a moment ago, well within the TTL
+		"interrupted": {ID: "interrupted", Status: StatusFinalizing, UpdatedAt: time.Now().Add(-time.Minute)},
+		"uploading":   {ID: "uploading", Status: StatusUploading, UpdatedAt: time.Now().Add(-time.Minute)},
+	}}
+	s := NewService(repo, t.TempDir(), 0, 24*time.Hour)
+	for id := range repo.uploads {
+		if err := os.WriteFile(s.Path(id), []byte("audio"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.Run(ctx, time.Hour)
+
+	if st := repo.uploads["interrupted"].Status; st != StatusFailed {
+		t.Errorf("interrupted upload is %s, want %s", st, StatusFailed)
+	}
+	if _, err := os.Stat(s.Path("interrupted")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("bytes of the interrupted upload were kept: %v", err)
+	}
+
+	if st := repo.uploads["uploading"].Status; st != StatusUploading {
+		t.Errorf("upload in progress is %s, want %s", st, StatusUploading)
+	}
+	if _, err := os.Stat(s.Path("uploading")); err != nil {
+		t.Errorf("bytes of the upload in progress were removed: %v", err)
+	}
+}
+
+func TestFailStaleKeepsRecentFinalizations(t *testing.T) {
+	repo := &memRepository{uploads: map[string]*Upload{
+		"finalizing": {ID: "finalizing", Status: StatusFinalizing, UpdatedAt: time.Now()},
+	}}
+	s := NewService(repo, t.TempDir(), 0, 24*time.Hour)
+
+	// The periodic sweep only fails finalizations older than the TTL
+	s.failStale(context.Background(), time.Now().Add(-s.ttl))
+
+	if st := repo.uploads["finalizing"].Status; st != StatusFinalizing {
+		t.Errorf("recent finalization is %s, want %s", st, StatusFinalizing)
+	}
+}
+
+// memRepository keeps uploads in memory
+type memRepository struct {
+	uploads map[string]*Upload
+}
+
+func (r *memRepository) Create(ctx context.Context, u *Upload) error {
+	u.CreatedAt, u.UpdatedAt = time.Now(), time.Now()
+	r.uploads[u.ID] = u
+	return nil
+}
+
+func (r *memRepository) Get(ctx context.Context, id string) (*Upload, error) {
+	u, ok := r.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return u, nil
+}
+
+func (r *memRepository) SetOffset(ctx context.Context, id string, offset int64, expiresAt time.Time) error {
+	u, err := r.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	u.Offset, u.ExpiresAt, u.UpdatedAt = offset, expiresAt, time.Now()
+	return nil
+}
+
+func (r *memRepository) SetStatus(ctx context.Context, id string, from, to Status, result []byte, errMsg *string) (bool, error) {
+	u, ok := r.uploads[id]
+	if !ok || u.Status != from {
+		return false, nil
+	}
+	u.Status, u.Result, u.Error, u.UpdatedAt = to, result, errMsg, time.Now()
+	return true, nil
+}
+
+func (r *memRepository) Delete(ctx context.Context, id string) error {
+	delete(r.uploads, id)
+	return nil
+}
+
+func (r *memRepository) Expired(ctx context.Context, now time.Time) ([]string, error) {
+	var ids []string
+	for id, u := range r.uploads {
+		if u.Status == StatusUploading && !u.ExpiresAt.IsZero() && u.ExpiresAt.Before(now) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memRepository) FailStale(ctx context.Context, before time.Time, errMsg string) ([]string, error) {
+	var ids []string
+	for id, u := range r.uploads {
+		if u.Status == StatusFinalizing && u.UpdatedAt.Before(before) {
+			u.Status, u.Error, u.UpdatedAt = StatusFailed, &errMsg, time.Now()
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS model_activations (
+  id BIGSERIAL PRIMARY KEY,
+  model_name TEXT NOT NULL,
+  model_version_id UUID NOT NULL REFERENCES model_versions(id),
+  previous_version_id UUID REFERENCES model_versions(id),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  reverted_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS model_activations_name_idx
+ON model_activations(model_name, id DESC);
+
+CREATE TABLE IF NOT EXISTS model_audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  model_name TEXT NOT NULL,
+  model_version_id UUID,
+  action VARCHAR(32) NOT NULL,
+  details JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS model_audit_log_name_idx
+ON model_audit_log(model_name, created_at DESC);
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS registered_models (
+  name TEXT PRIMARY KEY,
+  description TEXT NOT NULL DEFAULT '',
+  owner TEXT NOT NULL DEFAULT '',
+  task_type TEXT NOT NULL DEFAULT '',
+  class_labels JSONB NOT NULL DEFAULT '[]',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE model_versions
+  ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}',
+  ADD COLUMN IF NOT EXISTS annotations JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS model_versions_tags_idx
+ON model_versions USING GIN (tags);
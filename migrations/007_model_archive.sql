@@ -0,0 +1,5 @@
+ALTER TABLE model_versions
+  ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS model_versions_name_live_idx
+ON model_versions(name, version DESC) WHERE archived_at IS NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE model_versions
+  ADD COLUMN IF NOT EXISTS artifact_sha256 TEXT,
+  ADD COLUMN IF NOT EXISTS artifact_size BIGINT;
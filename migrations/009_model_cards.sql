@@ -0,0 +1,5 @@
+ALTER TABLE model_versions
+  ADD COLUMN IF NOT EXISTS dataset_source TEXT,
+  ADD COLUMN IF NOT EXISTS dataset_summary JSONB,
+  ADD COLUMN IF NOT EXISTS training_started_at TIMESTAMPTZ,
+  ADD COLUMN IF NOT EXISTS training_finished_at TIMESTAMPTZ;
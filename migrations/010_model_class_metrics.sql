@@ -0,0 +1,3 @@
+ALTER TABLE model_versions
+  ADD COLUMN IF NOT EXISTS class_metrics JSONB,
+  ADD COLUMN IF NOT EXISTS confusion_matrix JSONB;
@@ -0,0 +1,46 @@
+CREATE TABLE IF NOT EXISTS lineage_nodes (
+  node_type VARCHAR(32) NOT NULL,
+  node_id TEXT NOT NULL,
+  label TEXT NOT NULL DEFAULT '',
+  attributes JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (node_type, node_id)
+);
+
+CREATE TABLE IF NOT EXISTS lineage_edges (
+  id BIGSERIAL PRIMARY KEY,
+  src_type VARCHAR(32) NOT NULL,
+  src_id TEXT NOT NULL,
+  dst_type VARCHAR(32) NOT NULL,
+  dst_id TEXT NOT NULL,
+  relation VARCHAR(32) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  UNIQUE (src_type, src_id, dst_type, dst_id, relation)
+);
+
+CREATE INDEX IF NOT EXISTS lineage_edges_dst_idx ON lineage_edges(dst_type, dst_id);
+CREATE INDEX IF NOT EXISTS lineage_edges_src_idx ON lineage_edges(src_type, src_id);
+
+ALTER TABLE training_jobs
+  ADD COLUMN IF NOT EXISTS base_model_id UUID;
+
+-- Backfill the links that can be derived from existing rows
+INSERT INTO lineage_nodes (node_type, node_id, label)
+SELECT 'model_version', id::text, name || ' v' || version FROM model_versions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO lineage_nodes (node_type, node_id, label)
+SELECT 'training_job', id::text, model_name FROM training_jobs
+ON CONFLICT DO NOTHING;
+
+INSERT INTO lineage_nodes (node_type, node_id, label)
+SELECT DISTINCT 'dataset', dataset_source, dataset_source FROM training_jobs
+ON CONFLICT DO NOTHING;
+
+INSERT INTO lineage_edges (src_type, src_id, dst_type, dst_id, relation)
+SELECT 'training_job', training_job_id::text, 'model_version', id::text, 'produced' FROM model_versions
+ON CONFLICT DO NOTHING;
+
+INSERT INTO lineage_edges (src_type, src_id, dst_type, dst_id, relation)
+SELECT 'dataset', dataset_source, 'training_job', id::text, 'input_of' FROM training_jobs
+ON CONFLICT DO NOTHING;
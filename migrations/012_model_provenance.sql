@@ -0,0 +1,2 @@
+ALTER TABLE model_versions
+  ADD COLUMN IF NOT EXISTS provenance JSONB;
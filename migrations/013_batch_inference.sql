@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS inference_jobs (
+  id UUID PRIMARY KEY,
+  status VARCHAR(32) NOT NULL,
+  model_version_id UUID NOT NULL REFERENCES model_versions(id),
+  dataset_source TEXT,
+  audio_ids BIGINT[],
+  labels TEXT[] NOT NULL DEFAULT '{}',
+  total INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  started_at TIMESTAMPTZ,
+  finished_at TIMESTAMPTZ,
+  error TEXT
+);
+
+-- One row per scored clip. Exactly one of audio_file_id and source names
+-- the input: uploaded audio or a file relative to the dataset.
+CREATE TABLE IF NOT EXISTS predictions (
+  id BIGSERIAL PRIMARY KEY,
+  inference_job_id UUID REFERENCES inference_jobs(id) ON DELETE CASCADE,
+  model_version_id UUID NOT NULL,
+  audio_file_id BIGINT,
+  source TEXT NOT NULL DEFAULT '',
+  label TEXT,
+  probabilities JSONB,
+  latency_ms DOUBLE PRECISION,
+  error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS predictions_job_idx ON predictions(inference_job_id, id);
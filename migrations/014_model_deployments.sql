@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS model_deployments (
+  model_name TEXT PRIMARY KEY,
+  candidate_version_id UUID NOT NULL REFERENCES model_versions(id),
+  mode VARCHAR(16) NOT NULL,
+  percent INTEGER NOT NULL CHECK (percent BETWEEN 1 AND 100),
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Online predictions of one request share a request_id, role tells which
+-- answer was served: primary or canary, or shadow for the mirrored one
+ALTER TABLE predictions
+  ADD COLUMN IF NOT EXISTS request_id UUID,
+  ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'batch';
+
+CREATE INDEX IF NOT EXISTS predictions_request_idx ON predictions(request_id);
+CREATE INDEX IF NOT EXISTS predictions_version_created_idx ON predictions(model_version_id, created_at);
@@ -0,0 +1,19 @@
+ALTER TABLE predictions
+  ADD COLUMN IF NOT EXISTS confidence DOUBLE PRECISION,
+  ADD COLUMN IF NOT EXISTS duration_seconds DOUBLE PRECISION,
+  ADD COLUMN IF NOT EXISTS sample_rate INTEGER,
+  ADD COLUMN IF NOT EXISTS features JSONB;
+
+CREATE TABLE IF NOT EXISTS drift_reports (
+  id BIGSERIAL PRIMARY KEY,
+  model_name TEXT NOT NULL,
+  model_version_id UUID NOT NULL,
+  window_start TIMESTAMPTZ NOT NULL,
+  window_end TIMESTAMPTZ NOT NULL,
+  predictions INTEGER NOT NULL,
+  drifted BOOLEAN NOT NULL,
+  report JSONB NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS drift_reports_model_idx ON drift_reports(model_name, created_at DESC);
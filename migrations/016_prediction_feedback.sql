@@ -0,0 +1,10 @@
+ALTER TABLE predictions
+  ADD COLUMN IF NOT EXISTS input_path TEXT;
+
+CREATE TABLE IF NOT EXISTS prediction_feedback (
+  prediction_id BIGINT PRIMARY KEY REFERENCES predictions(id) ON DELETE CASCADE,
+  true_label TEXT NOT NULL,
+  comment TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS evaluations (
+  id UUID PRIMARY KEY,
+  status VARCHAR(32) NOT NULL,
+  model_version_id UUID NOT NULL REFERENCES model_versions(id),
+  dataset_source TEXT NOT NULL,
+  dataset_fingerprint TEXT,
+  dataset_summary JSONB,
+  samples INTEGER NOT NULL DEFAULT 0,
+  metrics JSONB,
+  class_metrics JSONB,
+  confusion_matrix JSONB,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  started_at TIMESTAMPTZ,
+  finished_at TIMESTAMPTZ,
+  error TEXT
+);
+
+CREATE INDEX IF NOT EXISTS evaluations_version_idx ON evaluations(model_version_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS evaluations_dataset_idx ON evaluations(dataset_source, model_version_id, finished_at DESC);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS dataset_rules (
+  dataset TEXT PRIMARY KEY,
+  rules JSONB NOT NULL,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS datasets (
+  name TEXT PRIMARY KEY,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS dataset_files (
+  dataset TEXT NOT NULL REFERENCES datasets(name) ON DELETE CASCADE,
+  path TEXT NOT NULL,
+  label TEXT NOT NULL,
+  split TEXT,
+  metadata JSONB,
+  sha256 TEXT NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  format TEXT,
+  encoding TEXT,
+  sample_rate INT,
+  channels INT,
+  bit_depth INT,
+  duration_seconds DOUBLE PRECISION,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (dataset, path)
+);
+
+CREATE INDEX IF NOT EXISTS dataset_files_label_idx ON dataset_files (dataset, label);
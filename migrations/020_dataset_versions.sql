@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS dataset_versions (
+  dataset TEXT NOT NULL REFERENCES datasets(name) ON DELETE CASCADE,
+  version INT NOT NULL,
+  digest TEXT NOT NULL,
+  files INT NOT NULL,
+  total_bytes BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (dataset, version)
+);
+
+CREATE TABLE IF NOT EXISTS dataset_version_files (
+  dataset TEXT NOT NULL,
+  version INT NOT NULL,
+  path TEXT NOT NULL,
+  label TEXT NOT NULL,
+  split TEXT,
+  metadata JSONB,
+  sha256 TEXT NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  PRIMARY KEY (dataset, version, path),
+  FOREIGN KEY (dataset, version) REFERENCES dataset_versions(dataset, version) ON DELETE CASCADE
+);
+
+ALTER TABLE training_jobs
+  ADD COLUMN IF NOT EXISTS dataset_version INT,
+  ADD COLUMN IF NOT EXISTS dataset_digest TEXT;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS uploads (
+  id UUID PRIMARY KEY,
+  target TEXT NOT NULL,
+  filename TEXT NOT NULL,
+  dataset TEXT,
+  label TEXT,
+  split TEXT,
+  length BIGINT NOT NULL,
+  "offset" BIGINT NOT NULL DEFAULT 0,
+  status TEXT NOT NULL,
+  result JSONB,
+  error TEXT,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+  expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS uploads_expires_idx ON uploads (expires_at) WHERE status = 'uploading';
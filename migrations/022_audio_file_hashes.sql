@@ -0,0 +1,8 @@
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS sha256 TEXT;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS size_bytes BIGINT;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS fingerprint BYTEA;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS fingerprint_frames INTEGER;
+
+-- Files registered by URL have no hash
+CREATE UNIQUE INDEX IF NOT EXISTS audio_files_sha256_idx ON audio_files (sha256) WHERE sha256 IS NOT NULL;
+CREATE INDEX IF NOT EXISTS audio_files_fingerprint_idx ON audio_files (fingerprint_frames) WHERE fingerprint IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Assigns splits to the files of a dataset that have none, see dataset.SplitPolicy
+ALTER TABLE datasets ADD COLUMN IF NOT EXISTS split_policy JSONB;
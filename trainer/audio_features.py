@@ -0,0 +1,109 @@
+"""Audio features shared by the trainer and the predictor.
+
+Drift monitoring compares the features of live predictions with the
+statistics the trainer reports for its dataset, so both sides must
+extract them the same way.
+"""
+import array
+import math
+import sys
+import wave
+
+# RMS is computed over the first seconds only, long files would dominate
+RMS_MAX_SECONDS = 30
+
+HISTOGRAM_BINS = 10
+
+
+def extract(path):
+    """Returns {name: value} for the audio file, {} when it cannot be read.
+
+    Only WAV files are decoded for now.
+    """
+    try:
+        with wave.open(path, "rb") as w:
+            rate = w.getframerate()
+            channels = w.getnchannels()
+            width = w.getsampwidth()
+            frames = w.getnframes()
+            data = w.readframes(min(frames, rate * RMS_MAX_SECONDS))
+    except (wave.Error, EOFError, OSError):
+        return {}
+
+    features = {
+        "duration_seconds": frames / rate if rate else 0.0,
+        "sample_rate": float(rate),
+        "channels": float(channels),
+    }
+    rms = _rms(data, width)
+    if rms is not None:
+        features["rms"] = rms
+    return features
+
+
+def _rms(data, width):
+    if width == 2:
+        samples = array.array("h", data)
+        if sys.byteorder == "big":
+            samples.byteswap()
+        scale = 32768.0
+    elif width == 1:
+        # 8-bit WAV is unsigned
+        samples = [b - 128 for b in data]
+        scale = 128.0
+    elif width == 4:
+        samples = array.array("i", data)
+        if sys.byteorder == "big":
+            samples.byteswap()
+        scale = 2147483648.0
+    else:
+        return None
+
+    if len(samples) == 0:
+        return 0.0
+    return math.sqrt(sum(s * s for s in samples) / len(samples)) / scale
+
+
+def histogram(values, bins=HISTOGRAM_BINS):
+    """Summarizes values with quantile bin edges and the share of each bin.
+
+    The outer edges are open ended when live values are binned.
+    """
+    values = sorted(values)
+    n = len(values)
+    mean = sum(values) / n
+    std = math.sqrt(sum((v - mean) ** 2 for v in values) / n)
+
+    edges = [values[min(n - 1, (i * n) // bins)] for i in range(bins)] + [values[-1]]
+    # Repeated values collapse bins, keep edges strictly increasing
+    edges = sorted(set(edges))
+    if len(edges) == 1:
+        edges = [edges[0], edges[0]]
+
+    counts = [0] * (len(edges) - 1)
+    for v in values:
+        counts[_bin(edges, v)] += 1
+
+    return {
+        "count": n,
+        "mean": mean,
+        "std": std,
+        "edges": edges,
+        "proportions": [c / n for c in counts],
+    }
+
+
+def _bin(edges, v):
+    for i in range(1, len(edges) - 1):
+        if v < edges[i]:
+            return i - 1
+    return len(edges) - 2
+
+
+def dataset_stats(paths):
+    """Histograms of every feature over the given audio files."""
+    values = {}
+    for path in paths:
+        for name, v in extract(path).items():
+            values.setdefault(name, []).append(v)
+    return {name: histogram(vs) for name, vs in values.items()}
@@ -0,0 +1,73 @@
+"""Evaluates a trained model on a held-out dataset.
+
+Class folders of the dataset are the true labels. Every file is scored
+with the same code as the prediction worker and the result is printed as
+one JSON object on stdout:
+
+    {"metrics": {"accuracy": 0.9, ...}, "samples": 120,
+     "confusion_matrix": {"labels": [...], "matrix": [[...]]}}
+"""
+import argparse
+import json
+import os
+import sys
+
+from predictor import predict
+
+parser = argparse.ArgumentParser()
+parser.add_argument("--job-id", required=True)
+parser.add_argument("--artifact", required=True)
+parser.add_argument("--model-version-id", required=True)
+parser.add_argument("--dataset", required=True)
+parser.add_argument("--labels", default="", help="comma separated labels known to the model")
+args = parser.parse_args()
+
+print(f"Evaluation job {args.job_id} on dataset {args.dataset}", file=sys.stderr)
+
+dataset_labels = sorted(
+    d for d in os.listdir(args.dataset)
+    if os.path.isdir(os.path.join(args.dataset, d)) and not d.startswith(".")
+)
+if not dataset_labels:
+    print("evaluation dataset has no class folders", file=sys.stderr)
+    sys.exit(1)
+
+model_labels = [l for l in args.labels.split(",") if l] or dataset_labels
+labels = sorted(set(model_labels) | set(dataset_labels))
+index = {label: i for i, label in enumerate(labels)}
+matrix = [[0] * len(labels) for _ in labels]
+
+samples = 0
+failures = 0
+for true_label in dataset_labels:
+    class_dir = os.path.join(args.dataset, true_label)
+    for root, dirs, files in os.walk(class_dir):
+        dirs[:] = [d for d in dirs if not d.startswith(".")]
+        for name in sorted(files):
+            if name.startswith("."):
+                continue
+            try:
+                pred = predict({
+                    "model_version_id": args.model_version_id,
+                    "artifact_path": args.artifact,
+                    "audio_path": os.path.join(root, name),
+                    "labels": model_labels,
+                })
+            except Exception as e:
+                print(f"skipping {name}: {e}", file=sys.stderr)
+                failures += 1
+                continue
+            matrix[index[true_label]][index[pred["label"]]] += 1
+            samples += 1
+
+correct = sum(matrix[i][i] for i in range(len(labels)))
+metrics = {
+    "accuracy": correct / samples if samples else 0.0,
+    "failures": failures,
+}
+
+print(json.dumps({
+    "metrics": metrics,
+    "samples": samples,
+    "confusion_matrix": {"labels": labels, "matrix": matrix},
+}))
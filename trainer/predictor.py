@@ -0,0 +1,78 @@
+"""Long-lived prediction worker.
+
+Reads one JSON request per line on stdin and answers with one JSON line
+on stdout, in the same order:
+
+    {"id": "1", "model_version_id": "...", "artifact_path": "...",
+     "audio_path": "...", "labels": ["cat", "dog"]}
+    {"id": "1", "label": "dog", "probabilities": {"cat": 0.2, "dog": 0.8},
+     "features": {"duration_seconds": 2.5, "sample_rate": 16000, ...}}
+
+Failures are reported as {"id": "1", "error": "..."} and the worker keeps
+serving. Logs go to stderr, stdout is reserved for the protocol.
+"""
+import hashlib
+import json
+import math
+import sys
+
+import audio_features
+
+models = {}
+
+
+def load_model(model_version_id, artifact_path):
+    # Loaded models stay in memory for the lifetime of the worker
+    if model_version_id not in models:
+        with open(artifact_path, "rb") as f:
+            models[model_version_id] = f.read()
+        print(f"Loaded model {model_version_id} from {artifact_path}", file=sys.stderr)
+    return models[model_version_id]
+
+
+def predict(req):
+    model = load_model(req["model_version_id"], req["artifact_path"])
+    labels = req.get("labels") or ["unknown"]
+
+    # Fake scores, deterministic for a given model and audio file
+    h = hashlib.sha256(model)
+    with open(req["audio_path"], "rb") as f:
+        for chunk in iter(lambda: f.read(1 << 16), b""):
+            h.update(chunk)
+    digest = h.digest()
+
+    logits = [digest[i % len(digest)] / 32.0 for i in range(len(labels))]
+    top = max(logits)
+    exps = [math.exp(x - top) for x in logits]
+    total = sum(exps)
+    probabilities = {label: e / total for label, e in zip(labels, exps)}
+    label = max(probabilities, key=probabilities.get)
+
+    return {
+        "label": label,
+        "probabilities": probabilities,
+        "features": audio_features.extract(req["audio_path"]),
+    }
+
+
+def main():
+    for line in sys.stdin:
+        line = line.strip()
+        if not line:
+            continue
+
+        req_id = None
+        try:
+            req = json.loads(line)
+            req_id = req.get("id")
+            resp = predict(req)
+        except Exception as e:
+            resp = {"error": str(e)}
+
+        resp["id"] = req_id
+        sys.stdout.write(json.dumps(resp) + "\n")
+        sys.stdout.flush()
+
+
+if __name__ == "__main__":
+    main()
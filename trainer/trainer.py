@@ -1,14 +1,19 @@
 import argparse
+import csv
 import json
 import os
 import time
 import sys
 
+import audio_features
+
 parser = argparse.ArgumentParser()
 parser.add_argument("--job-id", required=True)
 parser.add_argument("--dataset", required=True)
 parser.add_argument("--model", required=True)
 parser.add_argument("--out", default="artifacts")
+parser.add_argument("--base-model", default=None)
+parser.add_argument("--splits", default=None, help="CSV assigning each file to train, validation or test")
 args = parser.parse_args()
 
 job_id = args.job_id
@@ -17,6 +22,8 @@ os.makedirs(out_dir, exist_ok=True)
 
 # Simulate training
 print(f"Training job {job_id} on dataset {args.dataset}", file=sys.stderr)
+if args.base_model:
+    print(f"Starting from base model {args.base_model}", file=sys.stderr)
 time.sleep(5)
 
 # Fake model artifact
@@ -30,15 +37,57 @@ metrics = {
     "loss": 0.08
 }
 
+# Class folders of the dataset are the labels
+labels = sorted(
+    d for d in os.listdir(args.dataset)
+    if os.path.isdir(os.path.join(args.dataset, d)) and not d.startswith(".")
+) or ["unlabeled"]
+
+# Fake confusion matrix: mostly correct, a little confusion with the next class
+confusion = []
+for i, _ in enumerate(labels):
+    row = [0] * len(labels)
+    row[i] = 9
+    row[(i + 1) % len(labels)] += 1
+    confusion.append(row)
+
+audio_paths = [
+    os.path.join(root, name)
+    for root, dirs, files in os.walk(args.dataset)
+    for name in files
+    if not name.startswith(".")
+]
+
+# Splits come with the dataset version so that every model trained on it
+# is tested on the same files. Unassigned files are trained on.
+splits = {"train": [], "validation": [], "test": []}
+if args.splits:
+    with open(args.splits, newline="") as f:
+        for row in csv.DictReader(f):
+            path = os.path.join(args.dataset, *row["path"].split("/"))
+            splits.get(row["split"] or "train", splits["train"]).append(path)
+else:
+    splits["train"] = audio_paths
+print(
+    "Splits: " + ", ".join(f"{name} {len(paths)}" for name, paths in splits.items()),
+    file=sys.stderr,
+)
+
+# Feature statistics of the training audio, the baseline for drift monitoring
+feature_stats = audio_features.dataset_stats(splits["train"] or audio_paths)
+
 params = {
     "epochs": 10,
-    "lr": 0.001
+    "lr": 0.001,
+    "split_sizes": {name: len(paths) for name, paths in splits.items()},
 }
 
 result = {
     "metrics": metrics,
     "params": params,
-    "artifact_path": model_path
+    "artifact_path": model_path,
+    "confusion_matrix": {"labels": labels, "matrix": confusion},
+    "feature_stats": feature_stats
 }
 
 print(json.dumps(result))